@@ -39,6 +39,7 @@ class TranscribeConfig:
 class FilesConfig:
     api_url: str
     temp_dir: str
+    access_token: str = ""
     max_file_size: int = 500 * 1024 * 1024
     chunk_size: int = 2 ** 13
 
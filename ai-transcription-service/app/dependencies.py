@@ -19,9 +19,14 @@ rabbit_mq_config = RabbitMqConfig(url=rabbitmq_url)
 
 files_api_url = os.getenv(
     "FILE_SERVICE_URL",
-    default="http://localhost/files/api/v1"
+    default="http://localhost:8006/api/v1"
+)
+files_access_token = os.getenv("FILE_SERVICE_ACCESS_TOKEN", default="")
+files_config = FilesConfig(
+    api_url=files_api_url,
+    temp_dir=tempfile.mkdtemp(),
+    access_token=files_access_token
 )
-files_config = FilesConfig(api_url=files_api_url, temp_dir=tempfile.mkdtemp())
 
 num_workers = 4 if model_config.device == "gpu" else 1
 config = Config(
@@ -9,10 +9,18 @@ class FileServiceClient:
         self._base_url = files_config.api_url
         self._max_size = files_config.max_file_size
         self._chunk_size = files_config.chunk_size
+        self._headers = {"X-Access-Token": files_config.access_token} if files_config.access_token else {}
 
     async def download_audio_file(self, file_id: str, tmp_path: str):
-        url = f"{self._base_url}/download-url?type=audio&is_internal=true&file_id={file_id}"
+        url = f"{self._base_url}/download-url?type=audio&file_id={file_id}"
         print(url)
+        # The token is only sent to the file service, not along the redirect to storage
+        async with httpx.AsyncClient() as client:
+            response = await client.get(url, headers=self._headers)
+            if response.status_code not in (301, 302, 303, 307, 308):
+                response.raise_for_status()
+            url = response.headers["Location"]
+
         async with httpx.AsyncClient() as client:
             async with client.stream(method="GET", url=url, follow_redirects=True) as response:
                 response.raise_for_status()
@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -12,12 +15,18 @@ import (
 
 	"file-service/internal/config"
 	"file-service/internal/consumer"
+	"file-service/internal/domain"
 	"file-service/internal/handler"
+	"file-service/internal/publisher"
 	"file-service/internal/service"
+	"file-service/internal/storage"
 	"file-service/internal/storage/cache"
-	"file-service/internal/storage/minio"
+	"file-service/internal/worker"
+	"file-service/pkg/auth"
+	"file-service/pkg/diskcache"
 	"file-service/pkg/logger"
 	"file-service/pkg/middleware"
+	"file-service/pkg/ratelimit"
 
 	"github.com/gin-gonic/gin"
 )
@@ -32,37 +41,241 @@ func main() {
 	l := logger.New("file-service")
 
 	// Initialize dependencies
-	urlCache, err := cache.NewURLCache(&cfg.Cache, l)
+	redisClient, err := cache.NewRedisClient(&cfg.Cache, l)
 	if err != nil {
 		l.Fatal().Err(err).Msg("Failed to initialize cache")
 	}
+	urlCache := cache.NewURLCache(&cfg.Cache, redisClient, l)
+	uploadStore := cache.NewUploadStore(&cfg.Cache, redisClient, l)
+	usageStore := cache.NewUsageStore(&cfg.Cache, redisClient, l)
+	fingerprintStore := cache.NewFingerprintStore(&cfg.Cache, redisClient, l)
+	processingStore := cache.NewProcessingStore(&cfg.Cache, redisClient, l)
+	multipartStore := cache.NewMultipartStore(&cfg.Cache, redisClient, l)
 
-	minioClient, err := minio.NewMinioClient(&cfg.Minio, urlCache, l)
+	storageBackend, err := storage.NewBackend(cfg, urlCache, l)
 	if err != nil {
-		l.Fatal().Err(err).Msg("Failed to initialize Minio client")
+		l.Fatal().Err(err).Msg("Failed to initialize storage backend")
 	}
 
-	fileService := service.NewFileService(minioClient, l)
+	quotaService := service.NewQuotaService(usageStore, &cfg.Quota, l)
+	quotaHandler := handler.NewQuotaHandler(quotaService, l)
+
+	eventPublisher := publisher.NewRabbitMQPublisher(&cfg.RabbitMQ, l)
+	defer eventPublisher.Close()
+
+	quarantineService := service.NewQuarantineService(storageBackend, eventPublisher, eventPublisher, l)
+	contentValidator := service.NewContentValidator(quarantineService, l)
+	malwareScanner, err := service.NewMalwareScanner(storageBackend, quarantineService, cfg, l)
+	if err != nil {
+		l.Fatal().Err(err).Msg("Failed to initialize malware scanner")
+	}
+	imageVariantService := service.NewImageVariantService(storageBackend, cfg, l)
+	audioMetadataService := service.NewAudioMetadataService(storageBackend, eventPublisher, l)
+	audioMetadataHandler := handler.NewAudioMetadataHandler(audioMetadataService, l)
+	waveformService := service.NewWaveformService(storageBackend, cfg, l)
+	waveformHandler := handler.NewWaveformHandler(waveformService, l)
+	duplicateService := service.NewDuplicateService(storageBackend, fingerprintStore, cfg, l)
+	duplicateHandler := handler.NewDuplicateHandler(duplicateService, l)
+	previewService := service.NewPreviewService(storageBackend, cfg, l)
+	audioAnalysisService := service.NewAudioAnalysisService(
+		storageBackend,
+		waveformService,
+		audioMetadataService,
+		duplicateService,
+		previewService,
+		cfg,
+		l,
+	)
+
+	// Processors run on uploads in the order of the chain of their file type
+	uploadPipeline := service.NewUploadPipeline(storageBackend, processingStore, eventPublisher, cfg, l)
+	uploadPipeline.Register(service.ProcessorValidation, service.NewValidationProcessor(contentValidator))
+	uploadPipeline.Register(service.ProcessorMalwareScan, service.NewMalwareScanProcessor(malwareScanner, l))
+	uploadPipeline.Register(service.ProcessorImageVariants, service.NewImageVariantProcessor(imageVariantService, l))
+	uploadPipeline.Register(service.ProcessorRecord, service.NewRecordProcessor(storageBackend, quotaService))
+	uploadPipeline.Register(service.ProcessorAudioMetadata, service.NewAudioMetadataProcessor(
+		storageBackend,
+		audioMetadataService,
+		quotaService,
+		uploadPipeline,
+		l,
+	))
+	uploadPipeline.Register(service.ProcessorAudioAnalysis, service.NewAudioAnalysisProcessor(audioAnalysisService))
+
+	chains := map[domain.FileType][]string{
+		domain.FileTypeImage: {
+			service.ProcessorValidation,
+			service.ProcessorMalwareScan,
+			service.ProcessorImageVariants,
+			service.ProcessorRecord,
+		},
+		domain.FileTypeAudio: {
+			service.ProcessorValidation,
+			service.ProcessorMalwareScan,
+			service.ProcessorRecord,
+			service.ProcessorAudioMetadata,
+			service.ProcessorAudioAnalysis,
+		},
+	}
+	for fileType, chain := range chains {
+		if err := uploadPipeline.SetChain(fileType, chain...); err != nil {
+			l.Fatal().Err(err).Msg("Failed to configure upload pipeline")
+		}
+	}
+	processingHandler := handler.NewProcessingHandler(uploadPipeline, l)
+
+	fileService := service.NewFileService(
+		storageBackend,
+		multipartStore,
+		quotaService,
+		imageVariantService,
+		audioMetadataService,
+		waveformService,
+		uploadPipeline,
+		duplicateService,
+		previewService,
+		cfg,
+		l,
+	)
 	fileHandler := handler.NewFileHandler(fileService, l)
 
+	imageCache, err := diskcache.New(cfg.Image.Transform.CacheDir, cfg.Image.Transform.CacheMaxSize)
+	if err != nil {
+		l.Fatal().Err(err).Msg("Failed to initialize image cache")
+	}
+	imageTransformService := service.NewImageTransformService(storageBackend, imageCache, cfg, l)
+	imageHandler := handler.NewImageHandler(imageTransformService, l)
+
+	// Resumable uploads are built on multipart uploads
+	multipartBackend, supportsMultipart := storageBackend.(storage.MultipartBackend)
+
+	var tusHandler *handler.TusHandler
+	if supportsMultipart {
+		tusService := service.NewTusService(multipartBackend, uploadStore, quotaService, uploadPipeline, cfg, l)
+		tusHandler = handler.NewTusHandler(tusService, l)
+	} else {
+		l.Info().Str("storage", cfg.Storage.Type).Msg("Storage backend has no multipart support, resumable uploads disabled")
+	}
+
 	// Initialize and start RabbitMQ delete file consumer
-	ctx, stopConsumer := context.WithCancel(context.Background())
-	deleteFileConsumer := consumer.NewDeleteFileConsumer(&cfg.RabbitMQ, fileService, l)
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	deadLetters := consumer.NewDeadLetters(&cfg.RabbitMQ, l)
+	deleteFileSupervisor := consumer.NewSupervisor("delete_file", &cfg.RabbitMQ, l)
+	supervisors := []*consumer.Supervisor{deleteFileSupervisor}
+	deleteFileConsumer := consumer.NewDeleteFileConsumer(&cfg.RabbitMQ, deleteFileSupervisor, deadLetters, fileService, eventPublisher, l)
 	go func() {
 		if err := deleteFileConsumer.Run(ctx); err != nil {
 			l.Error().Err(err).Msg("Delete file consumer exited with error")
 		}
 	}()
 
+	// Uploads are processed as MinIO reports them, not only when clients complete them
+	if cfg.Minio.Notifications.Enabled {
+		objectCreatedSupervisor := consumer.NewSupervisor("object_created", &cfg.RabbitMQ, l)
+		supervisors = append(supervisors, objectCreatedSupervisor)
+		objectCreatedConsumer := consumer.NewObjectCreatedConsumer(cfg, objectCreatedSupervisor, deadLetters, uploadPipeline, l)
+		go func() {
+			if err := objectCreatedConsumer.Run(ctx); err != nil {
+				l.Error().Err(err).Msg("Object created consumer exited with error")
+			}
+		}()
+	}
+
+	go func() {
+		if err := audioAnalysisService.Run(ctx); err != nil {
+			l.Error().Err(err).Msg("Audio analyzer exited with error")
+		}
+	}()
+
+	// Start background sweeper for abandoned multipart uploads
+	if supportsMultipart {
+		multipartSweeper := worker.NewMultipartSweeper(&cfg.Minio.Multipart, multipartBackend, uploadStore, l)
+		go func() {
+			if err := multipartSweeper.Run(ctx); err != nil {
+				l.Error().Err(err).Msg("Multipart sweeper exited with error")
+			}
+		}()
+	}
+
+	// Upload endpoints require the backend's access tokens unless disabled
+	requireAuth := func(c *gin.Context) { c.Next() }
+	if cfg.Security.JWT.Enabled {
+		verifier, err := auth.NewVerifier(auth.VerifierOptions{
+			Issuer:           cfg.Security.JWT.Issuer,
+			Audience:         cfg.Security.JWT.Audience,
+			HMACSecret:       cfg.Security.JWT.HMACSecret,
+			RSAPublicKeyFile: cfg.Security.JWT.RSAPublicKeyFile,
+			JWKSFile:         cfg.Security.JWT.JWKSFile,
+			Leeway:           cfg.Security.JWT.Leeway,
+		})
+		if err != nil {
+			l.Fatal().Err(err).Msg("Failed to initialize access token verifier")
+		}
+		requireAuth = middleware.AuthMiddleware(verifier, l)
+	} else {
+		l.Warn().Msg("Access token validation is disabled, upload endpoints are unauthenticated")
+	}
+
+	// Rate limits are shared between replicas through Redis when it is available
+	var limiter ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		if redisClient != nil {
+			limiter = ratelimit.NewRedisLimiter(redisClient, cfg.Cache.Redis.RateLimitPrefix)
+		} else {
+			limiter = ratelimit.NewMemoryLimiter()
+		}
+	}
+
 	// Create server
+	health := healthCheck(supervisors)
+
+	router := setupRouter(
+		health,
+		fileHandler,
+		quotaHandler,
+		imageHandler,
+		audioMetadataHandler,
+		waveformHandler,
+		tusHandler,
+		storageBackend,
+		requireAuth,
+		limiter,
+		cfg,
+		l,
+	)
 	srv := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
-		Handler:      setupRouter(fileHandler, cfg, l),
+		Handler:      router,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	// Internal-only operations get a listener of their own, so they can't be
+	// reached through the public ingress
+	internalTLS, err := internalTLSConfig(&cfg.Server.Internal)
+	if err != nil {
+		l.Fatal().Err(err).Msg("Failed to configure internal server TLS")
+	}
+
+	internalRouter := setupInternalRouter(
+		health,
+		fileHandler,
+		quotaHandler,
+		audioMetadataHandler,
+		duplicateHandler,
+		processingHandler,
+		cfg,
+		l,
+	)
+	internalSrv := &http.Server{
+		Addr:         ":" + cfg.Server.Internal.Port,
+		Handler:      internalRouter,
+		TLSConfig:    internalTLS,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
 
-	// Start server in a goroutine
+	// Start servers in goroutines
 	go func() {
 		l.Info().Str("port", cfg.Server.Port).Msg("Starting server")
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -70,6 +283,20 @@ func main() {
 		}
 	}()
 
+	go func() {
+		l.Info().Str("port", cfg.Server.Internal.Port).Msg("Starting internal server")
+
+		var err error
+		if internalTLS != nil {
+			err = internalSrv.ListenAndServeTLS(cfg.Server.Internal.TLSCertFile, cfg.Server.Internal.TLSKeyFile)
+		} else {
+			err = internalSrv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Fatal().Err(err).Msg("Failed to start internal server")
+		}
+	}()
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -77,12 +304,16 @@ func main() {
 
 	l.Info().Msg("Initiating graceful shutdown...")
 
-	// Stop consumers first to stop processing new messages
-	stopConsumer()
+	// Stop consumers and background workers first to stop processing new messages
+	stopWorkers()
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer shutdownCancel()
 
+	if err := internalSrv.Shutdown(shutdownCtx); err != nil {
+		l.Error().Err(err).Msg("Internal server forced to shutdown")
+	}
+
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		l.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
@@ -90,8 +321,38 @@ func main() {
 	l.Info().Msg("Server exited")
 }
 
-func setupRouter(fileHandler *handler.FileHandler, cfg *config.Config, log *logger.Logger) *gin.Engine {
+func setupRouter(
+	health gin.HandlerFunc,
+	fileHandler *handler.FileHandler,
+	quotaHandler *handler.QuotaHandler,
+	imageHandler *handler.ImageHandler,
+	audioMetadataHandler *handler.AudioMetadataHandler,
+	waveformHandler *handler.WaveformHandler,
+	tusHandler *handler.TusHandler,
+	storageBackend storage.Backend,
+	requireAuth gin.HandlerFunc,
+	limiter ratelimit.Limiter,
+	cfg *config.Config,
+	log *logger.Logger,
+) *gin.Engine {
 	router := gin.New()
+	// Gin trusts forwarding headers of any peer by default, letting clients
+	// choose the address they are rate limited by
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal().Err(err).Msg("Invalid SERVER_TRUSTED_PROXIES")
+	}
+
+	// rateLimit returns the limiting middleware of a route, a no-op when limiting is disabled
+	rateLimit := func(route string) gin.HandlerFunc {
+		if limiter == nil {
+			return func(c *gin.Context) { c.Next() }
+		}
+		rule := cfg.RateLimit.ForRoute(route)
+		return middleware.RateLimitMiddleware(limiter, route, ratelimit.Rule{
+			Requests: rule.Requests,
+			Window:   rule.Window,
+		}, log)
+	}
 
 	// Global middleware
 	router.Use(
@@ -99,22 +360,149 @@ func setupRouter(fileHandler *handler.FileHandler, cfg *config.Config, log *logg
 		middleware.LoggerMiddleware(log),
 		middleware.PrometheusMiddleware(),
 		middleware.CORSMiddleware(cfg.Security.CORSAllowedOrigins),
+		middleware.RequestHostMiddleware(),
 	)
 
+	// Routes streaming file contents, or processing them before responding,
+	// outlast the timeouts of the server
+	transfer := middleware.TransferDeadlineMiddleware(cfg.Server.TransferTimeout)
+
 	// System routes
-	router.GET("/health", healthCheck)
+	router.GET("/health", health)
 	router.GET("/metrics", handler.PrometheusHandler())
 
 	// API routes
 	api := router.Group("/api/v1")
 	{
-		api.POST("/upload-url", fileHandler.GenerateUploadURL)
-		api.GET("/download-url", fileHandler.GenerateDownloadURL)
+		api.POST("/upload-url", requireAuth, rateLimit("upload-url"), fileHandler.GenerateUploadURL)
+		api.GET("/download-url", rateLimit("download-url"), fileHandler.GenerateDownloadURL)
+		api.POST("/files/:file_id/complete", transfer, requireAuth, rateLimit("complete"), fileHandler.CompleteUpload)
+		api.GET("/files/:file_id/metadata", rateLimit("metadata"), audioMetadataHandler.GetMetadata)
+		api.GET("/files/:file_id/waveform", rateLimit("waveform"), waveformHandler.GetWaveform)
+		api.GET("/usage", requireAuth, rateLimit("usage"), quotaHandler.GetUsage)
+		api.GET("/images/:file_id", rateLimit("images"), imageHandler.GetImage)
+
+		multipart := api.Group("/multipart-uploads", requireAuth, rateLimit("multipart-uploads"))
+		{
+			multipart.POST("", fileHandler.InitiateMultipartUpload)
+			multipart.POST("/:upload_id/parts", fileHandler.GeneratePartURLs)
+			multipart.POST("/:upload_id/complete", transfer, fileHandler.CompleteMultipartUpload)
+			multipart.DELETE("/:upload_id", fileHandler.AbortMultipartUpload)
+		}
+
+		if tusHandler != nil {
+			tus := api.Group("/tus", tusHandler.TusResumable)
+			{
+				tus.OPTIONS("", tusHandler.Options)
+				// Discovery via OPTIONS stays public. Only creation is rate limited,
+				// chunk requests are bounded by the upload size.
+				tus.POST("", requireAuth, rateLimit("tus"), tusHandler.CreateUpload)
+				tus.HEAD("/:file_id", requireAuth, tusHandler.GetOffset)
+				tus.PATCH("/:file_id", transfer, requireAuth, tusHandler.WriteChunk)
+				tus.DELETE("/:file_id", requireAuth, tusHandler.TerminateUpload)
+			}
+		}
+	}
+
+	// Backends without a storage server of their own serve their signed URLs
+	if signedURLHandler, ok := storageBackend.(http.Handler); ok {
+		storageRoutes := gin.WrapH(http.StripPrefix("/storage", signedURLHandler))
+		router.GET("/storage/*path", transfer, storageRoutes)
+		router.HEAD("/storage/*path", storageRoutes)
+		router.POST("/storage/*path", transfer, storageRoutes)
 	}
 
 	return router
 }
 
-func healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "healthy", "service": "file-service"})
+func setupInternalRouter(
+	health gin.HandlerFunc,
+	fileHandler *handler.FileHandler,
+	quotaHandler *handler.QuotaHandler,
+	audioMetadataHandler *handler.AudioMetadataHandler,
+	duplicateHandler *handler.DuplicateHandler,
+	processingHandler *handler.ProcessingHandler,
+	cfg *config.Config,
+	log *logger.Logger,
+) *gin.Engine {
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal().Err(err).Msg("Invalid SERVER_TRUSTED_PROXIES")
+	}
+
+	router.Use(
+		gin.Recovery(),
+		middleware.LoggerMiddleware(log),
+		middleware.PrometheusMiddleware(),
+	)
+
+	// Health checks stay unauthenticated for orchestration probes
+	router.GET("/health", health)
+
+	api := router.Group("/api/v1")
+	if cfg.Server.Internal.AccessToken != "" {
+		api.Use(middleware.AccessTokenMiddleware(cfg.Server.Internal.AccessToken))
+	}
+	{
+		api.GET("/download-url", fileHandler.GenerateInternalDownloadURL)
+		api.GET("/users/:user_id/usage", quotaHandler.GetUserUsage)
+		api.GET("/files/:file_id/metadata", audioMetadataHandler.GetMetadata)
+		api.GET("/files/:file_id/duplicates", duplicateHandler.GetDuplicates)
+		api.GET("/files/:file_id/processing", processingHandler.GetStatus)
+	}
+
+	return router
+}
+
+// internalTLSConfig returns the TLS config of the internal listener, requiring
+// client certificates signed by the client CA when one is set. It returns nil
+// when TLS is not configured.
+func internalTLSConfig(cfg *config.InternalServerConfig) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.ClientCAFile != "" {
+			return nil, errors.New("client CA requires a server certificate and key")
+		}
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, errors.New("both a server certificate and key are required")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	caPEM, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("client CA file contains no certificates")
+	}
+
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+// healthCheck reports the RabbitMQ connections of the consumers as well. The
+// service is degraded without them, but the HTTP API keeps working, so the
+// check doesn't fail.
+func healthCheck(supervisors []*consumer.Supervisor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := "healthy"
+		connections := make(map[string]consumer.ConnectionStatus, len(supervisors))
+		for _, supervisor := range supervisors {
+			connection := supervisor.Status()
+			if connection.State != consumer.StateConnected {
+				status = "degraded"
+			}
+			connections[supervisor.Name()] = connection
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": status, "service": "file-service", "rabbitmq": connections})
+	}
 }
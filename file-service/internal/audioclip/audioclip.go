@@ -0,0 +1,52 @@
+// Package audioclip cuts short clips out of audio files without re-encoding
+// them. MP3 files are cut at frame boundaries, WAV files at sample frames.
+package audioclip
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	// ErrUnsupportedFormat is returned for formats that can't be cut
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	// ErrMalformed is returned when no audio could be cut out of the file
+	ErrMalformed = errors.New("malformed audio file")
+)
+
+// Clip is a part cut out of an audio file
+type Clip struct {
+	Data        []byte
+	ContentType string
+	Start       time.Duration
+	Duration    time.Duration
+}
+
+// Cut returns the part of the audio starting at offset with the given
+// length. Audio ending before the offset yields its last part instead, and
+// audio shorter than the length yields all of it.
+func Cut(r io.Reader, offset time.Duration, length time.Duration) (*Clip, error) {
+	br := bufio.NewReaderSize(r, 64<<10)
+
+	header, err := br.Peek(12)
+	if err != nil && len(header) < 4 {
+		return nil, ErrUnsupportedFormat
+	}
+
+	switch {
+	case len(header) == 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return cutWAV(br, offset, length)
+	case bytes.HasPrefix(header, []byte("ID3")) || (header[0] == 0xFF && header[1]&0xE0 == 0xE0):
+		return cutMP3(br, offset, length)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// durationOf converts a number of samples per channel to their duration
+func durationOf(samples int64, sampleRate int) time.Duration {
+	return time.Duration(samples * int64(time.Second) / int64(sampleRate))
+}
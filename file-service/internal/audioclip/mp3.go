@@ -0,0 +1,129 @@
+package audioclip
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"file-service/internal/audiometa"
+)
+
+// maxResync bounds the bytes skipped in search of the next frame header
+const maxResync = 64 << 10
+
+type mp3Frame struct {
+	data    []byte
+	start   int64 // first sample
+	samples int64
+}
+
+// cutMP3 copies whole frames. Layer III frames may borrow bits from the
+// frames before them, decoders mute the first frame of a clip if it does.
+func cutMP3(br *bufio.Reader, offset time.Duration, length time.Duration) (*Clip, error) {
+	if err := skipID3(br); err != nil {
+		return nil, err
+	}
+
+	var (
+		window     []mp3Frame
+		windowSize int64 // samples
+		elapsed    int64
+		sampleRate int
+		resync     int
+	)
+
+	for {
+		header, err := br.Peek(4)
+		if err != nil {
+			break
+		}
+
+		frame, ok := audiometa.ParseMPEGFrame(header)
+		if !ok || (sampleRate != 0 && frame.SampleRate != sampleRate) {
+			// Trailing tags and garbage between frames are skipped
+			if resync++; resync > maxResync {
+				return nil, ErrMalformed
+			}
+			if _, err := br.Discard(1); err != nil {
+				break
+			}
+			continue
+		}
+
+		data := make([]byte, frame.Size)
+		if _, err := io.ReadFull(br, data); err != nil {
+			// A truncated last frame is left out
+			break
+		}
+		resync = 0
+
+		if sampleRate == 0 {
+			sampleRate = frame.SampleRate
+			// The VBR header frame describes the whole file, not the clip
+			if isVBRHeader(data) {
+				continue
+			}
+		}
+
+		window = append(window, mp3Frame{data: data, start: elapsed, samples: int64(frame.Samples)})
+		windowSize += int64(frame.Samples)
+		elapsed += int64(frame.Samples)
+
+		// Until the offset is reached the window holds the last frames of
+		// the clip length, in case the audio ends before
+		offsetSamples := int64(offset.Seconds() * float64(sampleRate))
+		lengthSamples := int64(length.Seconds() * float64(sampleRate))
+		for len(window) > 1 && window[0].start < offsetSamples && windowSize-window[0].samples >= lengthSamples {
+			windowSize -= window[0].samples
+			window = window[1:]
+		}
+		if window[0].start >= offsetSamples && windowSize >= lengthSamples {
+			break
+		}
+	}
+
+	if len(window) == 0 {
+		return nil, ErrMalformed
+	}
+
+	var data bytes.Buffer
+	for _, frame := range window {
+		data.Write(frame.data)
+	}
+
+	return &Clip{
+		Data:        data.Bytes(),
+		ContentType: "audio/mpeg",
+		Start:       durationOf(window[0].start, sampleRate),
+		Duration:    durationOf(windowSize, sampleRate),
+	}, nil
+}
+
+// skipID3 discards ID3v2 tags, the clip goes without tags
+func skipID3(br *bufio.Reader) error {
+	for {
+		header, err := br.Peek(10)
+		if err != nil || !bytes.HasPrefix(header, []byte("ID3")) {
+			return nil
+		}
+
+		size := int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 | int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F)
+		if header[5]&0x10 != 0 {
+			size += 10 // footer
+		}
+		if _, err := io.CopyN(io.Discard, br, 10+size); err != nil {
+			return fmt.Errorf("failed to skip ID3 tag: %w", err)
+		}
+	}
+}
+
+// isVBRHeader reports whether the frame holds a Xing, Info or VBRI header
+// instead of audio. They follow the side information, which is at most 32
+// bytes long.
+func isVBRHeader(frame []byte) bool {
+	head := frame[:min(len(frame), 4+32+8)]
+	return bytes.Contains(head, []byte("Xing")) || bytes.Contains(head, []byte("Info")) ||
+		bytes.Contains(head, []byte("VBRI"))
+}
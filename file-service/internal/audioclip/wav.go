@@ -0,0 +1,145 @@
+package audioclip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+// maxFormatSize bounds the fmt chunk, extensible formats take 40 bytes
+const maxFormatSize = 1 << 10
+
+const (
+	// maxSampleRate is the highest rate of PCM audio in practice
+	maxSampleRate = 768000
+	// maxChannels is the most channels a WAVE_FORMAT_EXTENSIBLE mask assigns
+	maxChannels = 18
+	// maxClipSize bounds the sample frames copied into a clip, whatever the
+	// header of the file claims
+	maxClipSize = 32 << 20
+)
+
+// Format tags of sample frames that can be cut at any frame
+const (
+	formatPCM        = 0x0001
+	formatIEEEFloat  = 0x0003
+	formatExtensible = 0xFFFE
+)
+
+// cutWAV copies the format and the sample frames of the clip into a new file
+func cutWAV(br *bufio.Reader, offset time.Duration, length time.Duration) (*Clip, error) {
+	if _, err := br.Discard(12); err != nil {
+		return nil, ErrMalformed
+	}
+
+	var format []byte
+	var dataSize int64
+chunks:
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(br, header); err != nil {
+			return nil, ErrMalformed
+		}
+		size := int64(binary.LittleEndian.Uint32(header[4:]))
+
+		switch string(header[:4]) {
+		case "data":
+			dataSize = size
+			break chunks
+		case "fmt ":
+			if size < 16 || size > maxFormatSize {
+				return nil, ErrMalformed
+			}
+			format = make([]byte, size)
+			if _, err := io.ReadFull(br, format); err != nil {
+				return nil, ErrMalformed
+			}
+		default:
+			if _, err := io.CopyN(io.Discard, br, size); err != nil {
+				return nil, ErrMalformed
+			}
+		}
+
+		// Chunks are padded to an even length
+		if size&1 == 1 {
+			if _, err := br.Discard(1); err != nil {
+				return nil, ErrMalformed
+			}
+		}
+	}
+	if format == nil {
+		return nil, ErrMalformed
+	}
+
+	formatTag := binary.LittleEndian.Uint16(format[0:])
+	channels := int64(binary.LittleEndian.Uint16(format[2:]))
+	sampleRate := int(binary.LittleEndian.Uint32(format[4:]))
+	blockAlign := int64(binary.LittleEndian.Uint16(format[12:]))
+	bitsPerSample := int64(binary.LittleEndian.Uint16(format[14:]))
+
+	if formatTag != formatPCM && formatTag != formatIEEEFloat && formatTag != formatExtensible {
+		// Compressed formats are cut at blocks of their own
+		return nil, ErrUnsupportedFormat
+	}
+	if channels == 0 || channels > maxChannels || sampleRate == 0 || sampleRate > maxSampleRate ||
+		bitsPerSample == 0 || bitsPerSample%8 != 0 || blockAlign != channels*bitsPerSample/8 {
+		return nil, ErrMalformed
+	}
+
+	lengthFrames := min(int64(length.Seconds()*float64(sampleRate)), maxClipSize/blockAlign)
+	startFrame := int64(offset.Seconds() * float64(sampleRate))
+	// Streamed files may declare a placeholder length, which is trusted only
+	// when it is a whole number of sample frames
+	if dataSize > 0 && dataSize != 0xFFFFFFFF && dataSize%blockAlign == 0 {
+		startFrame = min(startFrame, max(dataSize/blockAlign-lengthFrames, 0))
+	}
+
+	if _, err := io.CopyN(io.Discard, br, startFrame*blockAlign); err != nil {
+		return nil, ErrMalformed
+	}
+
+	// The buffer grows with the frames actually read, truncated files don't
+	// allocate the length their header claims
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, br, lengthFrames*blockAlign)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, ErrMalformed
+	}
+	data := buf.Bytes()[:n/blockAlign*blockAlign]
+	if len(data) == 0 {
+		return nil, ErrMalformed
+	}
+
+	return &Clip{
+		Data:        riff(format, data),
+		ContentType: "audio/wav",
+		Start:       durationOf(startFrame, sampleRate),
+		Duration:    durationOf(int64(len(data))/blockAlign, sampleRate),
+	}, nil
+}
+
+// riff builds a WAV file of the fmt and data chunks
+func riff(format []byte, data []byte) []byte {
+	var out bytes.Buffer
+	chunk := func(id string, body []byte) {
+		out.WriteString(id)
+		out.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(body))))
+		out.Write(body)
+		if len(body)%2 == 1 {
+			out.WriteByte(0)
+		}
+	}
+
+	out.WriteString("RIFF")
+	out.Write(make([]byte, 4)) // size, set below
+	out.WriteString("WAVE")
+	chunk("fmt ", format)
+	chunk("data", data)
+
+	file := out.Bytes()
+	binary.LittleEndian.PutUint32(file[4:], uint32(len(file)-8))
+	return file
+}
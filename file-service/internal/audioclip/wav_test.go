@@ -0,0 +1,103 @@
+package audioclip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+// pcmFormat returns the fmt chunk of PCM audio
+func pcmFormat(channels uint16, sampleRate uint32, bitsPerSample uint16) []byte {
+	blockAlign := channels * bitsPerSample / 8
+
+	format := binary.LittleEndian.AppendUint16(nil, formatPCM)
+	format = binary.LittleEndian.AppendUint16(format, channels)
+	format = binary.LittleEndian.AppendUint32(format, sampleRate)
+	format = binary.LittleEndian.AppendUint32(format, sampleRate*uint32(blockAlign))
+	format = binary.LittleEndian.AppendUint16(format, blockAlign)
+	return binary.LittleEndian.AppendUint16(format, bitsPerSample)
+}
+
+func TestCutWAV(t *testing.T) {
+	// 10 seconds of 8 kHz mono 16-bit audio, each frame holds its index
+	frames := make([]byte, 0, 10*8000*2)
+	for i := range 10 * 8000 {
+		frames = binary.LittleEndian.AppendUint16(frames, uint16(i))
+	}
+	file := riff(pcmFormat(1, 8000, 16), frames)
+
+	tests := []struct {
+		name         string
+		offset       time.Duration
+		length       time.Duration
+		wantStart    time.Duration
+		wantDuration time.Duration
+	}{
+		{"within the audio", 2 * time.Second, 3 * time.Second, 2 * time.Second, 3 * time.Second},
+		{"offset past the end", 30 * time.Second, 3 * time.Second, 7 * time.Second, 3 * time.Second},
+		{"longer than the audio", 0, 30 * time.Second, 0, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clip, err := Cut(bytes.NewReader(file), tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("Cut() error = %v", err)
+			}
+			if clip.Start != tt.wantStart || clip.Duration != tt.wantDuration {
+				t.Fatalf("Cut() = %v+%v, want %v+%v", clip.Start, clip.Duration, tt.wantStart, tt.wantDuration)
+			}
+
+			firstFrame := binary.LittleEndian.Uint16(clip.Data[44:])
+			if want := uint16(tt.wantStart.Seconds() * 8000); firstFrame != want {
+				t.Errorf("first frame = %d, want %d", firstFrame, want)
+			}
+		})
+	}
+}
+
+func TestCutWAVRejectsMalformedFormat(t *testing.T) {
+	// The format the clip buffer was once sized by, which must not panic
+	crafted := pcmFormat(1, 8000, 16)
+	binary.LittleEndian.PutUint32(crafted[4:], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint16(crafted[12:], 0xFFFF)
+
+	inconsistent := pcmFormat(2, 44100, 16)
+	binary.LittleEndian.PutUint16(inconsistent[12:], 2)
+
+	tests := []struct {
+		name   string
+		format []byte
+		want   error
+	}{
+		{"crafted sample rate and block align", crafted, ErrMalformed},
+		{"sample rate out of range", pcmFormat(1, 1_000_000, 16), ErrMalformed},
+		{"no channels", pcmFormat(0, 8000, 16), ErrMalformed},
+		{"block align inconsistent with the channels", inconsistent, ErrMalformed},
+		{"compressed format", append([]byte{0x11, 0x00}, pcmFormat(1, 8000, 4)[2:]...), ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := riff(tt.format, make([]byte, 1024))
+			if _, err := Cut(bytes.NewReader(file), 0, time.Hour); !errors.Is(err, tt.want) {
+				t.Fatalf("Cut() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCutWAVCapsClipSize(t *testing.T) {
+	// 768 kHz 8 channel 32-bit audio, an hour of it would take 88 GB
+	file := riff(pcmFormat(8, maxSampleRate, 32), make([]byte, maxClipSize+1<<20))
+
+	clip, err := Cut(bytes.NewReader(file), 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Cut() error = %v", err)
+	}
+	if len(clip.Data) > maxClipSize+44 {
+		t.Fatalf("clip size = %d, want at most %d", len(clip.Data), maxClipSize+44)
+	}
+}
@@ -0,0 +1,265 @@
+// Package audiodecode decodes MP3, FLAC and WAV files as a stream and hands
+// the samples to sinks, so several analyses share a single decoding pass
+package audiodecode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/hajimehoshi/go-mp3"
+	"github.com/mewkiz/flac"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported audio format for decoding")
+
+// bufferFrames is the number of frames handed to sinks at once
+const bufferFrames = 4096
+
+// Sink receives decoded audio
+type Sink interface {
+	// Format is called once before any samples are written
+	Format(sampleRate int, channels int)
+	// Write receives interleaved samples in the range [-1, 1]. The slice is
+	// reused after the call returns.
+	Write(samples []float32)
+}
+
+// Decode decodes the audio and writes it to all sinks
+func Decode(r io.Reader, sinks ...Sink) error {
+	br := bufio.NewReaderSize(r, 64<<10)
+	header, err := br.Peek(12)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	out := &output{sinks: sinks}
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return decodeFLAC(br, out)
+	case len(header) == 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return decodeWAV(br, out)
+	case bytes.HasPrefix(header, []byte("ID3")) || (len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0):
+		return decodeMP3(br, out)
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+// output buffers frames and passes them on to the sinks
+type output struct {
+	sinks    []Sink
+	channels int
+	buf      []float32
+}
+
+func (o *output) format(sampleRate int, channels int) {
+	o.channels = channels
+	o.buf = make([]float32, 0, bufferFrames*channels)
+	for _, sink := range o.sinks {
+		sink.Format(sampleRate, channels)
+	}
+}
+
+// add appends a sample, frames are complete after one sample per channel
+func (o *output) add(sample float32) {
+	o.buf = append(o.buf, sample)
+	if len(o.buf) == cap(o.buf) {
+		o.flush()
+	}
+}
+
+func (o *output) flush() {
+	if len(o.buf) == 0 {
+		return
+	}
+	for _, sink := range o.sinks {
+		sink.Write(o.buf)
+	}
+	o.buf = o.buf[:0]
+}
+
+func decodeMP3(br *bufio.Reader, out *output) error {
+	// The decoder buffers tags whole, skipping them here keeps memory bounded
+	for {
+		header, err := br.Peek(10)
+		if err != nil || !bytes.HasPrefix(header, []byte("ID3")) {
+			break
+		}
+		size := int64(header[6]&0x7F)<<21 | int64(header[7]&0x7F)<<14 | int64(header[8]&0x7F)<<7 | int64(header[9]&0x7F)
+		if header[5]&0x10 != 0 {
+			size += 10 // footer
+		}
+		if _, err := br.Discard(10); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(io.Discard, br, size); err != nil {
+			return fmt.Errorf("failed to skip ID3 tag: %w", err)
+		}
+	}
+
+	// The decoder always outputs stereo, mono files are reduced to one
+	// channel again so they aren't measured twice
+	channels := 2
+	if header, err := br.Peek(4); err == nil && header[0] == 0xFF && header[3]>>6 == 3 {
+		channels = 1
+	}
+
+	// Hiding the seeker keeps the decoder from scanning the whole file upfront
+	decoder, err := mp3.NewDecoder(struct{ io.Reader }{br})
+	if err != nil {
+		return fmt.Errorf("failed to decode mp3: %w", err)
+	}
+	out.format(decoder.SampleRate(), channels)
+
+	// Output is always 16 bit little endian stereo
+	buf := make([]byte, 16<<10)
+	for {
+		n, err := io.ReadFull(decoder, buf)
+		for i := 0; i+4 <= n; i += 4 {
+			out.add(float32(int16(binary.LittleEndian.Uint16(buf[i:]))) / 32768)
+			if channels == 2 {
+				out.add(float32(int16(binary.LittleEndian.Uint16(buf[i+2:]))) / 32768)
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			out.flush()
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode mp3: %w", err)
+		}
+	}
+}
+
+func decodeFLAC(br *bufio.Reader, out *output) error {
+	stream, err := flac.New(br)
+	if err != nil {
+		return fmt.Errorf("failed to decode flac: %w", err)
+	}
+
+	channels := int(stream.Info.NChannels)
+	scale := float32(int64(1) << (stream.Info.BitsPerSample - 1))
+	out.format(int(stream.Info.SampleRate), channels)
+
+	for {
+		frame, err := stream.ParseNext()
+		if errors.Is(err, io.EOF) {
+			out.flush()
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode flac: %w", err)
+		}
+		if len(frame.Subframes) != channels {
+			return fmt.Errorf("failed to decode flac: frame has %d channels instead of %d", len(frame.Subframes), channels)
+		}
+
+		for i := range int(frame.BlockSize) {
+			for _, subframe := range frame.Subframes {
+				out.add(float32(subframe.Samples[i]) / scale)
+			}
+		}
+	}
+}
+
+// WAVE format codes
+const (
+	wavePCM        = 1
+	waveFloat      = 3
+	waveExtensible = 0xFFFE
+)
+
+func decodeWAV(br *bufio.Reader, out *output) error {
+	if _, err := br.Discard(12); err != nil {
+		return err
+	}
+
+	var format, channels, bitsPerSample int
+	var sampleRate int
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return fmt.Errorf("failed to find wav data: %w", err)
+		}
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+
+		switch string(header[:4]) {
+		case "fmt ":
+			if length < 16 {
+				return fmt.Errorf("invalid wav format chunk")
+			}
+			var chunk [16]byte
+			if _, err := io.ReadFull(br, chunk[:]); err != nil {
+				return err
+			}
+			format = int(binary.LittleEndian.Uint16(chunk[0:]))
+			channels = int(binary.LittleEndian.Uint16(chunk[2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(chunk[4:]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(chunk[14:]))
+			length -= 16
+		case "data":
+			if channels == 0 || sampleRate == 0 {
+				return fmt.Errorf("wav data precedes its format")
+			}
+			out.format(sampleRate, channels)
+			return decodePCM(io.LimitReader(br, length), format, channels, bitsPerSample, out)
+		}
+
+		// Chunks are padded to an even length
+		if _, err := io.CopyN(io.Discard, br, length+length&1); err != nil {
+			return fmt.Errorf("failed to find wav data: %w", err)
+		}
+	}
+}
+
+func decodePCM(r io.Reader, format int, channels int, bitsPerSample int, out *output) error {
+	// Extensible files carry the actual format in a sub format GUID, integer
+	// and float samples are told apart by their size here
+	if format == waveExtensible {
+		format = wavePCM
+	}
+
+	var sample func(b []byte) float32
+	switch {
+	case format == wavePCM && bitsPerSample == 8:
+		sample = func(b []byte) float32 { return float32(int(b[0])-128) / 128 }
+	case format == wavePCM && bitsPerSample == 16:
+		sample = func(b []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }
+	case format == wavePCM && bitsPerSample == 24:
+		sample = func(b []byte) float32 {
+			return float32(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		}
+	case format == wavePCM && bitsPerSample == 32:
+		sample = func(b []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }
+	case format == waveFloat && bitsPerSample == 32:
+		sample = func(b []byte) float32 {
+			return max(-1, min(math.Float32frombits(binary.LittleEndian.Uint32(b)), 1))
+		}
+	default:
+		return ErrUnsupportedFormat
+	}
+
+	sampleSize := bitsPerSample / 8
+	frameSize := sampleSize * channels
+	buf := make([]byte, frameSize*bufferFrames)
+	for {
+		n, err := io.ReadFull(r, buf)
+		for i := 0; i+frameSize <= n; i += frameSize {
+			for ch := range channels {
+				out.add(sample(buf[i+ch*sampleSize:]))
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			out.flush()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
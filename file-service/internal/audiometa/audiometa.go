@@ -0,0 +1,146 @@
+// Package audiometa reads tags and stream properties of audio files. Files
+// are accessed through io.ReaderAt and only the parts holding metadata are
+// read, every read is bounded by the limits below.
+package audiometa
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	ErrMalformed         = errors.New("malformed audio file")
+)
+
+const (
+	// maxTagSize bounds tag blocks read into memory, larger ones are skipped
+	maxTagSize = 16 << 20
+	// frameSearchWindow bounds the search for the first audio frame
+	frameSearchWindow = 64 << 10
+)
+
+// Formats of parsed files
+const (
+	FormatMP3  = "mp3"
+	FormatFLAC = "flac"
+	FormatOgg  = "ogg"
+	FormatOpus = "opus"
+	FormatWAV  = "wav"
+)
+
+// Tags holds the textual metadata of a file, missing tags are empty
+type Tags struct {
+	Title  string
+	Artist string
+	Album  string
+	Genre  string
+	Year   string
+	Track  string
+}
+
+// Picture is an image embedded in the tags
+type Picture struct {
+	MIMEType string // as declared by the file, may be empty
+	Type     byte   // ID3v2 picture type, shared by FLAC
+	Data     []byte
+}
+
+// pictureFrontCover is the picture type of the front cover
+const pictureFrontCover = 3
+
+// Info describes an audio file
+type Info struct {
+	Format     string
+	Tags       Tags
+	Picture    *Picture // the front cover if there is one, otherwise the first picture
+	Duration   time.Duration
+	Bitrate    int // bits per second, averaged over the file
+	SampleRate int
+	Channels   int
+}
+
+// Parse detects the format of the file and reads its metadata
+func Parse(r io.ReaderAt, size int64) (*Info, error) {
+	header := make([]byte, 12)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return parseFLAC(r, size)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return parseOgg(r, size)
+	case len(header) == 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return parseWAV(r, size)
+	case bytes.HasPrefix(header, []byte("ID3")) || (len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0):
+		return parseMP3(r, size)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// readAt reads exactly n bytes at the offset
+func readAt(r io.ReaderAt, offset int64, n int) ([]byte, error) {
+	if n < 0 || offset < 0 {
+		return nil, ErrMalformed
+	}
+
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, offset)
+	if read == n {
+		return buf, nil
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return nil, err
+}
+
+// readUpTo reads at most n bytes at the offset, fewer at the end of the file
+func readUpTo(r io.ReaderAt, offset int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+// durationOf converts a sample count at the sample rate to a duration
+func durationOf(samples int64, sampleRate int) time.Duration {
+	if sampleRate <= 0 || samples <= 0 {
+		return 0
+	}
+	return time.Duration(float64(samples) / float64(sampleRate) * float64(time.Second))
+}
+
+// bitrateOf returns the average bitrate of audio data lasting the duration
+func bitrateOf(size int64, duration time.Duration) int {
+	if duration <= 0 || size <= 0 {
+		return 0
+	}
+	return int(float64(size*8) / duration.Seconds())
+}
+
+// addPicture keeps the picture if it is preferred over the current one
+func (i *Info) addPicture(picture *Picture) {
+	if len(picture.Data) == 0 {
+		return
+	}
+	if i.Picture == nil || (i.Picture.Type != pictureFrontCover && picture.Type == pictureFrontCover) {
+		i.Picture = picture
+	}
+}
+
+// setIfEmpty fills a tag that is still missing, earlier sources take precedence
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
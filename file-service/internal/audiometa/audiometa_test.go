@@ -0,0 +1,171 @@
+package audiometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func le16(v uint16) []byte { return binary.LittleEndian.AppendUint16(nil, v) }
+func le32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+func concat(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+// vorbisComment returns a comment block without the framing bit
+func vorbisComment(comments ...string) []byte {
+	data := concat(le32(4), []byte("test"), le32(uint32(len(comments))))
+	for _, comment := range comments {
+		data = concat(data, le32(uint32(len(comment))), []byte(comment))
+	}
+	return data
+}
+
+// wavFile returns one second of 16 bit stereo PCM at 44.1 kHz with a title
+func wavFile(byteRate uint32) []byte {
+	format := concat(le16(1), le16(2), le32(44100), le32(byteRate), le16(4), le16(16))
+	info := concat([]byte("INFO"), []byte("INAM"), le32(6), []byte("Title\x00"))
+	body := concat(
+		[]byte("WAVE"),
+		[]byte("fmt "), le32(uint32(len(format))), format,
+		[]byte("LIST"), le32(uint32(len(info))), info,
+		[]byte("data"), le32(176400), make([]byte, 176400),
+	)
+	return concat([]byte("RIFF"), le32(uint32(len(body))), body)
+}
+
+// flacFile returns the metadata of one second of stereo audio at 44.1 kHz
+// followed by 1000 bytes of frames
+func flacFile() []byte {
+	streamInfo := make([]byte, 34)
+	binary.BigEndian.PutUint64(streamInfo[10:], 44100<<44|(2-1)<<41|(16-1)<<36|44100)
+	comment := vorbisComment("TITLE=Title")
+
+	return concat(
+		[]byte("fLaC"),
+		[]byte{flacStreamInfo, 0, 0, byte(len(streamInfo))}, streamInfo,
+		[]byte{0x80 | flacVorbisComment, 0, 0, byte(len(comment))}, comment,
+		make([]byte, 1000),
+	)
+}
+
+// mp3File returns one second of 128 kbit/s MPEG-1 layer III frames at 44.1 kHz
+// after an ID3v2.3 tag
+func mp3File() []byte {
+	title := concat([]byte{0}, []byte("Title"))
+	frame := concat([]byte("TIT2"), binary.BigEndian.AppendUint32(nil, uint32(len(title))), []byte{0, 0}, title)
+	tag := concat([]byte("ID3\x03\x00\x00"), []byte{0, 0, 0, byte(len(frame))}, frame)
+
+	// Each frame of 417 bytes starts with the header
+	audio := make([]byte, 16000)
+	for i := 0; i < len(audio); i += 417 {
+		copy(audio[i:], []byte{0xFF, 0xFB, 0x90, 0x00})
+	}
+	return concat(tag, audio)
+}
+
+// oggPageOf returns a page holding a single packet shorter than 255 bytes
+func oggPageOf(granule int64, packet []byte) []byte {
+	header := concat(
+		[]byte("OggS\x00\x00"),
+		binary.LittleEndian.AppendUint64(nil, uint64(granule)),
+		le32(1), le32(0), le32(0),
+		[]byte{1, byte(len(packet))},
+	)
+	return concat(header, packet)
+}
+
+// oggFile returns one second of Vorbis stereo audio at 44.1 kHz
+func oggFile(codec string) []byte {
+	ident := concat([]byte("\x01"+codec), le32(0), []byte{2}, le32(44100), make([]byte, 14))
+	comment := concat([]byte("\x03"+codec), vorbisComment("TITLE=Title"), []byte{1})
+
+	return concat(
+		oggPageOf(0, ident),
+		oggPageOf(0, comment),
+		oggPageOf(44100, make([]byte, 200)),
+	)
+}
+
+func TestParse(t *testing.T) {
+	ogg := oggFile("vorbis")
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantErr    error
+		format     string
+		sampleRate int
+		channels   int
+		bitrate    int
+	}{
+		{"wav", wavFile(176400), nil, FormatWAV, 44100, 2, 1411200},
+		{"flac", flacFile(), nil, FormatFLAC, 44100, 2, 8000},
+		{"mp3", mp3File(), nil, FormatMP3, 44100, 2, 128000},
+		{"ogg", ogg, nil, FormatOgg, 44100, 2, len(ogg) * 8},
+
+		{"empty", nil, ErrUnsupportedFormat, "", 0, 0, 0},
+		{"text", []byte("hello world, this is not audio"), ErrUnsupportedFormat, "", 0, 0, 0},
+		{"flac marker only", []byte("fLaC"), ErrMalformed, "", 0, 0, 0},
+		{"flac with short stream info", concat([]byte("fLaC"), []byte{0x80, 0, 0, 10}, make([]byte, 10)), ErrMalformed, "", 0, 0, 0},
+		{"flac without stream info", concat([]byte("fLaC"), []byte{0x80 | flacVorbisComment, 0, 0, 0}), ErrMalformed, "", 0, 0, 0},
+		{"wav header only", []byte("RIFF\x04\x00\x00\x00WAVE"), ErrMalformed, "", 0, 0, 0},
+		{"wav without byte rate", wavFile(0), ErrMalformed, "", 0, 0, 0},
+		{"id3 tag larger than the file", []byte("ID3\x03\x00\x00\x00\x00\x10\x00TIT2"), ErrMalformed, "", 0, 0, 0},
+		{"id3 tag without frames", concat([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), []byte("hello world")), ErrMalformed, "", 0, 0, 0},
+		{"lone frame sync", []byte{0xFF, 0xFB}, ErrMalformed, "", 0, 0, 0},
+		{"truncated ogg page", []byte("OggS\x00\x02"), ErrMalformed, "", 0, 0, 0},
+		{"ogg of another codec", oggFile("theora"), ErrUnsupportedFormat, "", 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Parse(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if info.Format != tt.format {
+				t.Errorf("Format = %q, want %q", info.Format, tt.format)
+			}
+			if info.Tags.Title != "Title" {
+				t.Errorf("Title = %q, want %q", info.Tags.Title, "Title")
+			}
+			if info.Duration != time.Second {
+				t.Errorf("Duration = %v, want %v", info.Duration, time.Second)
+			}
+			if info.SampleRate != tt.sampleRate || info.Channels != tt.channels {
+				t.Errorf("SampleRate, Channels = %d, %d, want %d, %d", info.SampleRate, info.Channels, tt.sampleRate, tt.channels)
+			}
+			if info.Bitrate != tt.bitrate {
+				t.Errorf("Bitrate = %d, want %d", info.Bitrate, tt.bitrate)
+			}
+		})
+	}
+}
+
+// TestParseTruncated parses every prefix of valid files, which must either
+// succeed or fail with a parse error
+func TestParseTruncated(t *testing.T) {
+	files := map[string][]byte{
+		"wav":  wavFile(176400)[:1024],
+		"flac": flacFile(),
+		"mp3":  mp3File()[:1024],
+		"ogg":  oggFile("vorbis"),
+	}
+
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			for n := range len(data) {
+				_, err := Parse(bytes.NewReader(data[:n]), int64(n))
+				if err != nil && !errors.Is(err, ErrMalformed) && !errors.Is(err, ErrUnsupportedFormat) {
+					t.Fatalf("Parse() of %d bytes error = %v", n, err)
+				}
+			}
+		})
+	}
+}
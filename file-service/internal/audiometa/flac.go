@@ -0,0 +1,121 @@
+package audiometa
+
+import (
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// FLAC metadata block types
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+	flacPicture       = 6
+)
+
+func parseFLAC(r io.ReaderAt, size int64) (*Info, error) {
+	info := &Info{Format: FormatFLAC}
+
+	var totalSamples int64
+	offset := int64(4)
+	for {
+		header, err := readAt(r, offset, 4)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		offset += 4
+
+		switch blockType {
+		case flacStreamInfo:
+			block, err := readAt(r, offset, length)
+			if err != nil || length < 18 {
+				return nil, ErrMalformed
+			}
+			// 20 bits sample rate, 3 bits channels - 1, 5 bits bits per sample - 1, 36 bits total samples
+			packed := binary.BigEndian.Uint64(block[10:18])
+			info.SampleRate = int(packed >> 44)
+			info.Channels = int(packed>>41&0x07) + 1
+			totalSamples = int64(packed & 0xFFFFFFFFF)
+		case flacVorbisComment:
+			if length <= maxTagSize {
+				block, err := readAt(r, offset, length)
+				if err != nil {
+					return nil, ErrMalformed
+				}
+				parseVorbisComment(block, info)
+			}
+		case flacPicture:
+			if length <= maxTagSize {
+				block, err := readAt(r, offset, length)
+				if err != nil {
+					return nil, ErrMalformed
+				}
+				if picture, ok := parseFLACPicture(block); ok {
+					info.addPicture(picture)
+				}
+			}
+		}
+
+		offset += int64(length)
+		if last {
+			break
+		}
+	}
+
+	if info.SampleRate == 0 {
+		return nil, ErrMalformed
+	}
+
+	info.Duration = durationOf(totalSamples, info.SampleRate)
+	info.Bitrate = bitrateOf(size-offset, info.Duration)
+
+	return info, nil
+}
+
+// parseFLACPicture reads a PICTURE block, which Vorbis comments also embed
+// base64 encoded
+func parseFLACPicture(block []byte) (*Picture, bool) {
+	if len(block) < 4 {
+		return nil, false
+	}
+	pictureType := binary.BigEndian.Uint32(block)
+
+	// field reads a length prefixed field
+	pos := 4
+	field := func() ([]byte, bool) {
+		if pos+4 > len(block) {
+			return nil, false
+		}
+		length := int(binary.BigEndian.Uint32(block[pos:]))
+		pos += 4
+		if length < 0 || length > len(block)-pos {
+			return nil, false
+		}
+		value := block[pos : pos+length]
+		pos += length
+		return value, true
+	}
+
+	mimeType, ok := field()
+	if !ok {
+		return nil, false
+	}
+	if _, ok := field(); !ok { // description
+		return nil, false
+	}
+	pos += 16 // width, height, color depth and palette size
+
+	data, ok := field()
+	if !ok {
+		return nil, false
+	}
+
+	return &Picture{
+		MIMEType: strings.ToLower(string(mimeType)),
+		Type:     byte(min(pictureType, 255)),
+		Data:     data,
+	}, true
+}
@@ -0,0 +1,312 @@
+package audiometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// id3v2Header is the size of the ID3v2 tag header and footer
+const id3v2Header = 10
+
+// syncsafe decodes an integer stored in 7 bits per byte
+func syncsafe(b []byte) int {
+	value := 0
+	for _, c := range b {
+		value = value<<7 | int(c&0x7F)
+	}
+	return value
+}
+
+// removeUnsync reverses unsynchronisation, which inserts a zero byte after each 0xFF
+func removeUnsync(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{0xFF, 0x00}, []byte{0xFF})
+}
+
+// parseID3v2 reads the text and picture frames of an ID3v2.2, v2.3 or v2.4 tag body
+func parseID3v2(header []byte, body []byte, info *Info) {
+	tags := &info.Tags
+	version := header[3]
+	flags := header[5]
+
+	if flags&0x80 != 0 && version < 4 {
+		body = removeUnsync(body)
+	}
+
+	pos := 0
+	if flags&0x40 != 0 && len(body) >= 4 {
+		switch version {
+		case 3:
+			pos = 4 + int(binary.BigEndian.Uint32(body))
+		case 4:
+			pos = syncsafe(body[:4])
+		}
+	}
+
+	for pos < len(body) {
+		var id string
+		var size int
+		var frameFlags uint16
+
+		if version == 2 {
+			if pos+6 > len(body) {
+				return
+			}
+			id = string(body[pos : pos+3])
+			size = int(body[pos+3])<<16 | int(body[pos+4])<<8 | int(body[pos+5])
+			pos += 6
+		} else {
+			if pos+10 > len(body) {
+				return
+			}
+			id = string(body[pos : pos+4])
+			if version == 4 {
+				size = syncsafe(body[pos+4 : pos+8])
+			} else {
+				size = int(binary.BigEndian.Uint32(body[pos+4 : pos+8]))
+			}
+			frameFlags = binary.BigEndian.Uint16(body[pos+8 : pos+10])
+			pos += 10
+		}
+
+		// Padding follows the last frame
+		if id[0] == 0 || size <= 0 || size > len(body)-pos {
+			return
+		}
+		data := body[pos : pos+size]
+		pos += size
+
+		data, ok := frameData(version, frameFlags, data)
+		if !ok {
+			continue
+		}
+
+		switch id {
+		case "TIT2", "TT2":
+			setIfEmpty(&tags.Title, decodeText(data))
+		case "TPE1", "TP1":
+			setIfEmpty(&tags.Artist, decodeText(data))
+		case "TALB", "TAL":
+			setIfEmpty(&tags.Album, decodeText(data))
+		case "TCON", "TCO":
+			setIfEmpty(&tags.Genre, resolveGenre(decodeText(data)))
+		case "TYER", "TYE", "TDRC":
+			setIfEmpty(&tags.Year, year(decodeText(data)))
+		case "TRCK", "TRK":
+			setIfEmpty(&tags.Track, decodeText(data))
+		case "APIC", "PIC":
+			if picture, ok := parseAPIC(data, version == 2); ok {
+				info.addPicture(picture)
+			}
+		}
+	}
+}
+
+// frameData strips frame level encodings, it reports false for frames that
+// are compressed or encrypted
+func frameData(version byte, flags uint16, data []byte) ([]byte, bool) {
+	switch version {
+	case 3:
+		if flags&0x00C0 != 0 {
+			return nil, false
+		}
+		if flags&0x0020 != 0 && len(data) > 0 {
+			data = data[1:] // group identifier
+		}
+	case 4:
+		if flags&0x000C != 0 {
+			return nil, false
+		}
+		if flags&0x0040 != 0 && len(data) > 0 {
+			data = data[1:] // group identifier
+		}
+		if flags&0x0002 != 0 {
+			data = removeUnsync(data)
+		}
+		if flags&0x0001 != 0 && len(data) >= 4 {
+			data = data[4:] // data length indicator
+		}
+	}
+	return data, true
+}
+
+// parseAPIC reads an attached picture frame. ID3v2.2 frames name a three
+// letter image format instead of a MIME type.
+func parseAPIC(data []byte, v22 bool) (*Picture, bool) {
+	if len(data) < 2 {
+		return nil, false
+	}
+	encoding := data[0]
+	data = data[1:]
+
+	var mimeType string
+	if v22 {
+		if len(data) < 3 {
+			return nil, false
+		}
+		switch strings.ToUpper(string(data[:3])) {
+		case "JPG":
+			mimeType = "image/jpeg"
+		case "PNG":
+			mimeType = "image/png"
+		}
+		data = data[3:]
+	} else {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			return nil, false
+		}
+		mimeType = strings.ToLower(string(data[:end]))
+		data = data[end+1:]
+	}
+
+	if len(data) < 1 {
+		return nil, false
+	}
+	pictureType := data[0]
+	data = data[1:]
+
+	// Skip the description, UTF-16 text ends with a two byte terminator
+	end := -1
+	if encoding == 1 || encoding == 2 {
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				end = i + 2
+				break
+			}
+		}
+	} else if i := bytes.IndexByte(data, 0); i >= 0 {
+		end = i + 1
+	}
+	if end < 0 {
+		return nil, false
+	}
+
+	return &Picture{MIMEType: mimeType, Type: pictureType, Data: data[end:]}, true
+}
+
+// decodeText decodes a text frame, only the first of multiple values is kept
+func decodeText(data []byte) string {
+	if len(data) < 2 {
+		return ""
+	}
+	return firstValue(decodeString(data[0], data[1:]))
+}
+
+// decodeString decodes text in one of the ID3v2 encodings
+func decodeString(encoding byte, data []byte) string {
+	switch encoding {
+	case 0:
+		return latin1(data)
+	case 1:
+		return utf16String(data, nil)
+	case 2:
+		return utf16String(data, binary.BigEndian)
+	default:
+		return string(data)
+	}
+}
+
+// utf16String decodes UTF-16 text, the byte order comes from the BOM when order is nil
+func utf16String(data []byte, order binary.ByteOrder) string {
+	if order == nil {
+		order = binary.LittleEndian
+		if len(data) >= 2 {
+			switch {
+			case data[0] == 0xFE && data[1] == 0xFF:
+				order, data = binary.BigEndian, data[2:]
+			case data[0] == 0xFF && data[1] == 0xFE:
+				data = data[2:]
+			}
+		}
+	}
+
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, order.Uint16(data[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// firstValue returns the text up to the first NUL separator, trimmed
+func firstValue(text string) string {
+	text = strings.TrimLeft(text, "\x00")
+	if value, _, found := strings.Cut(text, "\x00"); found {
+		text = value
+	}
+	return strings.TrimSpace(text)
+}
+
+// year returns the year of a date such as "2004" or "2004-05-12"
+func year(date string) string {
+	if len(date) >= 4 {
+		if _, err := strconv.Atoi(date[:4]); err == nil {
+			return date[:4]
+		}
+	}
+	return ""
+}
+
+// resolveGenre replaces ID3v1 genre references such as "(17)" or "17" by their name
+func resolveGenre(genre string) string {
+	reference := strings.TrimSuffix(strings.TrimPrefix(genre, "("), ")")
+	if index, err := strconv.Atoi(reference); err == nil {
+		if index >= 0 && index < len(id3v1Genres) {
+			return id3v1Genres[index]
+		}
+		return ""
+	}
+
+	// "(17)Rock" style refinements keep their text
+	if strings.HasPrefix(genre, "(") {
+		if end := strings.Index(genre, ")"); end > 0 && end+1 < len(genre) {
+			return genre[end+1:]
+		}
+	}
+	return genre
+}
+
+// parseID3v1 reads the fixed size tag at the end of MP3 files
+func parseID3v1(tag []byte, tags *Tags) {
+	field := func(data []byte) string {
+		return strings.TrimSpace(strings.TrimRight(latin1(data), "\x00"))
+	}
+
+	setIfEmpty(&tags.Title, field(tag[3:33]))
+	setIfEmpty(&tags.Artist, field(tag[33:63]))
+	setIfEmpty(&tags.Album, field(tag[63:93]))
+	setIfEmpty(&tags.Year, year(field(tag[93:97])))
+
+	// ID3v1.1 stores the track in the last byte of the comment
+	comment := tag[97:127]
+	if comment[28] == 0 && comment[29] != 0 {
+		setIfEmpty(&tags.Track, strconv.Itoa(int(comment[29])))
+	}
+
+	if genre := int(tag[127]); genre < len(id3v1Genres) {
+		setIfEmpty(&tags.Genre, id3v1Genres[genre])
+	}
+}
+
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
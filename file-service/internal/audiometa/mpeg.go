@@ -0,0 +1,261 @@
+package audiometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// MPEG audio versions as encoded in the frame header
+const (
+	mpeg25 = 0
+	mpeg2  = 2
+	mpeg1  = 3
+)
+
+// Bitrates in kbit/s indexed by [MPEG-1 or not][layer - 1][index]
+var mpegBitrates = [2][3][16]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
+}
+
+var mpegSampleRates = [4][3]int{
+	mpeg25: {11025, 12000, 8000},
+	mpeg2:  {22050, 24000, 16000},
+	mpeg1:  {44100, 48000, 32000},
+}
+
+// frameHeader is a decoded MPEG audio frame header
+type frameHeader struct {
+	version    int
+	layer      int
+	bitrate    int // bits per second
+	sampleRate int
+	channels   int
+	size       int // frame length in bytes including the header
+}
+
+// samples returns the number of samples per channel in the frame
+func (h frameHeader) samples() int {
+	switch {
+	case h.layer == 1:
+		return 384
+	case h.layer == 3 && h.version != mpeg1:
+		return 576
+	default:
+		return 1152
+	}
+}
+
+// sideInfoSize returns the size of the layer III side information
+func (h frameHeader) sideInfoSize() int {
+	if h.version == mpeg1 {
+		if h.channels == 1 {
+			return 17
+		}
+		return 32
+	}
+	if h.channels == 1 {
+		return 9
+	}
+	return 17
+}
+
+// parseFrameHeader decodes the 4 byte header, it reports false for invalid ones
+func parseFrameHeader(b []byte) (frameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return frameHeader{}, false
+	}
+
+	version := int(b[1]>>3) & 0x03
+	layer := 4 - int(b[1]>>1)&0x03
+	bitrateIndex := int(b[2] >> 4)
+	rateIndex := int(b[2]>>2) & 0x03
+	padding := int(b[2]>>1) & 0x01
+
+	// Reserved values, free format streams are not supported either
+	if version == 1 || layer == 4 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return frameHeader{}, false
+	}
+
+	table := 1
+	if version == mpeg1 {
+		table = 0
+	}
+
+	h := frameHeader{
+		version:    version,
+		layer:      layer,
+		bitrate:    mpegBitrates[table][layer-1][bitrateIndex] * 1000,
+		sampleRate: mpegSampleRates[version][rateIndex],
+		channels:   2,
+	}
+	if b[3]>>6 == 3 {
+		h.channels = 1
+	}
+
+	switch {
+	case layer == 1:
+		h.size = (12*h.bitrate/h.sampleRate + padding) * 4
+	case layer == 3 && version != mpeg1:
+		h.size = 72*h.bitrate/h.sampleRate + padding
+	default:
+		h.size = 144*h.bitrate/h.sampleRate + padding
+	}
+
+	return h, true
+}
+
+// MPEGFrame describes an MPEG audio frame
+type MPEGFrame struct {
+	Size       int // bytes including the header
+	Samples    int // per channel
+	SampleRate int
+}
+
+// ParseMPEGFrame decodes the 4 byte header of an MPEG audio frame, it
+// reports false for invalid ones
+func ParseMPEGFrame(b []byte) (MPEGFrame, bool) {
+	header, ok := parseFrameHeader(b)
+	if !ok {
+		return MPEGFrame{}, false
+	}
+	return MPEGFrame{Size: header.size, Samples: header.samples(), SampleRate: header.sampleRate}, true
+}
+
+func parseMP3(r io.ReaderAt, size int64) (*Info, error) {
+	info := &Info{Format: FormatMP3}
+
+	// Tags may be prepended more than once, e.g. by tools that don't look for existing ones
+	var offset int64
+	for {
+		header, err := readAt(r, offset, id3v2Header)
+		if err != nil || !bytes.HasPrefix(header, []byte("ID3")) {
+			break
+		}
+
+		tagSize := int64(syncsafe(header[6:10]))
+		if tagSize <= maxTagSize {
+			body, err := readAt(r, offset+id3v2Header, int(tagSize))
+			if err != nil {
+				return nil, ErrMalformed
+			}
+			parseID3v2(header, body, info)
+		}
+
+		offset += id3v2Header + tagSize
+		if header[5]&0x10 != 0 {
+			offset += id3v2Header // footer
+		}
+	}
+
+	end := size
+	if size-offset >= 128 {
+		if tag, err := readAt(r, size-128, 128); err == nil && bytes.HasPrefix(tag, []byte("TAG")) {
+			parseID3v1(tag, &info.Tags)
+			end -= 128
+		}
+	}
+
+	frameOffset, header, err := findFrame(r, offset, end)
+	if err != nil {
+		return nil, err
+	}
+
+	info.SampleRate = header.sampleRate
+	info.Channels = header.channels
+	audioSize := end - frameOffset
+
+	frames, frameBytes := vbrHeader(r, frameOffset, header)
+	if frames > 0 {
+		info.Duration = durationOf(frames*int64(header.samples()), header.sampleRate)
+		if frameBytes > 0 {
+			audioSize = frameBytes
+		}
+		info.Bitrate = bitrateOf(audioSize, info.Duration)
+	} else {
+		// Constant bitrate, the first frame is representative
+		info.Bitrate = header.bitrate
+		info.Duration = durationOf(audioSize*8*int64(header.sampleRate)/int64(header.bitrate), header.sampleRate)
+	}
+
+	return info, nil
+}
+
+// findFrame locates the first frame header that is followed by another valid
+// header, which rules out sync words occurring by chance in the data
+func findFrame(r io.ReaderAt, start int64, end int64) (int64, frameHeader, error) {
+	window, err := readUpTo(r, start, int(min(frameSearchWindow, max(end-start, 0))))
+	if err != nil {
+		return 0, frameHeader{}, err
+	}
+
+	for i := 0; i+4 <= len(window); i++ {
+		header, ok := parseFrameHeader(window[i:])
+		if !ok {
+			continue
+		}
+
+		next := int64(i + header.size)
+		if start+next+4 > end {
+			// A single frame file can't be checked against its successor
+			return start + int64(i), header, nil
+		}
+
+		nextHeader, err := readAt(r, start+next, 4)
+		if err != nil {
+			continue
+		}
+		if following, ok := parseFrameHeader(nextHeader); ok &&
+			following.version == header.version && following.layer == header.layer &&
+			following.sampleRate == header.sampleRate {
+			return start + int64(i), header, nil
+		}
+	}
+
+	return 0, frameHeader{}, ErrMalformed
+}
+
+// vbrHeader reads the frame and byte counts of a Xing, Info or VBRI header
+// in the first frame, zero counts mean the header is absent
+func vbrHeader(r io.ReaderAt, offset int64, header frameHeader) (frames int64, size int64) {
+	frame, err := readUpTo(r, offset, min(header.size, 256))
+	if err != nil {
+		return 0, 0
+	}
+
+	xing := 4 + header.sideInfoSize()
+	if len(frame) >= xing+16 {
+		tag := frame[xing:]
+		if bytes.HasPrefix(tag, []byte("Xing")) || bytes.HasPrefix(tag, []byte("Info")) {
+			flags := binary.BigEndian.Uint32(tag[4:8])
+			pos := 8
+			if flags&0x01 != 0 {
+				frames = int64(binary.BigEndian.Uint32(tag[pos:]))
+				pos += 4
+			}
+			if flags&0x02 != 0 {
+				size = int64(binary.BigEndian.Uint32(tag[pos:]))
+			}
+			return frames, size
+		}
+	}
+
+	const vbri = 4 + 32
+	if len(frame) >= vbri+18 && bytes.HasPrefix(frame[vbri:], []byte("VBRI")) {
+		tag := frame[vbri:]
+		size = int64(binary.BigEndian.Uint32(tag[10:14]))
+		frames = int64(binary.BigEndian.Uint32(tag[14:18]))
+		return frames, size
+	}
+
+	return 0, 0
+}
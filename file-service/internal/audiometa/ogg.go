@@ -0,0 +1,157 @@
+package audiometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// oggPageHeader is the size of an Ogg page header without its segment table
+const oggPageHeader = 27
+
+// oggPage is the header of an Ogg page
+type oggPage struct {
+	granule  int64
+	serial   uint32
+	segments []byte // lacing values
+	size     int    // header and segment table size
+}
+
+// dataSize returns the size of the page body
+func (p *oggPage) dataSize() int {
+	size := 0
+	for _, lacing := range p.segments {
+		size += int(lacing)
+	}
+	return size
+}
+
+func readOggPage(r io.ReaderAt, offset int64) (*oggPage, error) {
+	header, err := readAt(r, offset, oggPageHeader)
+	if err != nil || !bytes.HasPrefix(header, []byte("OggS")) {
+		return nil, ErrMalformed
+	}
+
+	segments, err := readAt(r, offset+oggPageHeader, int(header[26]))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	return &oggPage{
+		granule:  int64(binary.LittleEndian.Uint64(header[6:14])),
+		serial:   binary.LittleEndian.Uint32(header[14:18]),
+		segments: segments,
+		size:     oggPageHeader + len(segments),
+	}, nil
+}
+
+// oggHeaderPackets returns the first two packets of the first logical stream,
+// which hold the identification and comment headers of Vorbis and Opus.
+// A comment packet larger than maxTagSize is returned empty.
+func oggHeaderPackets(r io.ReaderAt) (ident []byte, comment []byte, serial uint32, err error) {
+	var packets [][]byte
+	var packet []byte
+	var packetSize int
+	first := true
+
+	for offset := int64(0); len(packets) < 2; {
+		page, err := readOggPage(r, offset)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if first {
+			serial, first = page.serial, false
+		}
+
+		dataOffset := offset + int64(page.size)
+		data, err := readAt(r, dataOffset, page.dataSize())
+		if err != nil {
+			return nil, nil, 0, ErrMalformed
+		}
+
+		pos := 0
+		for _, lacing := range page.segments {
+			length := int(lacing)
+			if page.serial == serial {
+				packetSize += length
+				if packetSize <= maxTagSize {
+					packet = append(packet, data[pos:pos+length]...)
+				}
+				// A lacing value below 255 ends the packet
+				if lacing < 255 {
+					if packetSize > maxTagSize {
+						packet = nil
+					}
+					packets = append(packets, packet)
+					packet, packetSize = nil, 0
+					if len(packets) == 2 {
+						break
+					}
+				}
+			}
+			pos += length
+		}
+		offset = dataOffset + int64(len(data))
+	}
+
+	return packets[0], packets[1], serial, nil
+}
+
+// lastGranule returns the granule position of the last page of the stream
+func lastGranule(r io.ReaderAt, size int64, serial uint32) int64 {
+	start := max(size-frameSearchWindow, 0)
+	tail, err := readUpTo(r, start, int(size-start))
+	if err != nil {
+		return 0
+	}
+
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if i+oggPageHeader > len(tail) {
+			continue
+		}
+		page := tail[i:]
+		granule := int64(binary.LittleEndian.Uint64(page[6:14]))
+		// Pages without a completed packet carry a granule of -1
+		if binary.LittleEndian.Uint32(page[14:18]) == serial && granule > 0 {
+			return granule
+		}
+	}
+	return 0
+}
+
+func parseOgg(r io.ReaderAt, size int64) (*Info, error) {
+	ident, comment, serial, err := oggHeaderPackets(r)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{}
+	switch {
+	case bytes.HasPrefix(ident, []byte("\x01vorbis")) && len(ident) >= 16:
+		info.Format = FormatOgg
+		info.Channels = int(ident[11])
+		info.SampleRate = int(binary.LittleEndian.Uint32(ident[12:16]))
+		if bytes.HasPrefix(comment, []byte("\x03vorbis")) {
+			parseVorbisComment(comment[7:], info)
+		}
+		info.Duration = durationOf(lastGranule(r, size, serial), info.SampleRate)
+	case bytes.HasPrefix(ident, []byte("OpusHead")) && len(ident) >= 16:
+		info.Format = FormatOpus
+		info.Channels = int(ident[9])
+		info.SampleRate = int(binary.LittleEndian.Uint32(ident[12:16]))
+		if bytes.HasPrefix(comment, []byte("OpusTags")) {
+			parseVorbisComment(comment[8:], info)
+		}
+		// Opus granule positions always count 48 kHz samples and include the pre-skip
+		preSkip := int64(binary.LittleEndian.Uint16(ident[10:12]))
+		info.Duration = durationOf(lastGranule(r, size, serial)-preSkip, 48000)
+		if info.SampleRate == 0 {
+			info.SampleRate = 48000
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	info.Bitrate = bitrateOf(size, info.Duration)
+	return info, nil
+}
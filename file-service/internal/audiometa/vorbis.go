@@ -0,0 +1,64 @@
+package audiometa
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+)
+
+// parseVorbisComment reads a Vorbis comment block as used by FLAC, Vorbis
+// and Opus. Its fields are little endian and the framing bit is not included.
+func parseVorbisComment(data []byte, info *Info) {
+	tags := &info.Tags
+	if len(data) < 4 {
+		return
+	}
+	vendorLength := int(binary.LittleEndian.Uint32(data))
+	pos := 4 + vendorLength
+	if vendorLength < 0 || pos+4 > len(data) || pos < 0 {
+		return
+	}
+
+	count := int(binary.LittleEndian.Uint32(data[pos:]))
+	pos += 4
+
+	for range count {
+		if pos+4 > len(data) {
+			return
+		}
+		length := int(binary.LittleEndian.Uint32(data[pos:]))
+		pos += 4
+		if length < 0 || length > len(data)-pos {
+			return
+		}
+		comment := string(data[pos : pos+length])
+		pos += length
+
+		name, value, found := strings.Cut(comment, "=")
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToUpper(name) {
+		case "METADATA_BLOCK_PICTURE":
+			if block, err := base64.StdEncoding.DecodeString(value); err == nil {
+				if picture, ok := parseFLACPicture(block); ok {
+					info.addPicture(picture)
+				}
+			}
+		case "TITLE":
+			setIfEmpty(&tags.Title, value)
+		case "ARTIST":
+			setIfEmpty(&tags.Artist, value)
+		case "ALBUM":
+			setIfEmpty(&tags.Album, value)
+		case "GENRE":
+			setIfEmpty(&tags.Genre, value)
+		case "DATE", "YEAR":
+			setIfEmpty(&tags.Year, year(value))
+		case "TRACKNUMBER":
+			setIfEmpty(&tags.Track, value)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package audiometa
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+func parseWAV(r io.ReaderAt, size int64) (*Info, error) {
+	info := &Info{Format: FormatWAV}
+
+	var byteRate int
+	var dataSize int64
+	offset := int64(12)
+	for offset+8 <= size {
+		header, err := readAt(r, offset, 8)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		id := string(header[:4])
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+		offset += 8
+
+		switch id {
+		case "fmt ":
+			chunk, err := readAt(r, offset, 16)
+			if err != nil {
+				return nil, ErrMalformed
+			}
+			info.Channels = int(binary.LittleEndian.Uint16(chunk[2:]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(chunk[4:]))
+			byteRate = int(binary.LittleEndian.Uint32(chunk[8:]))
+		case "data":
+			// Streamed files may declare a placeholder length
+			dataSize = min(length, size-offset)
+		case "LIST":
+			if length >= 4 && length <= maxTagSize {
+				chunk, err := readAt(r, offset, int(length))
+				if err == nil && bytes.HasPrefix(chunk, []byte("INFO")) {
+					parseRIFFInfo(chunk[4:], &info.Tags)
+				}
+			}
+		}
+
+		// Chunks are padded to an even length
+		offset += length + length&1
+	}
+
+	if info.SampleRate == 0 || byteRate == 0 {
+		return nil, ErrMalformed
+	}
+
+	info.Bitrate = byteRate * 8
+	info.Duration = durationOf(dataSize*int64(info.SampleRate)/int64(byteRate), info.SampleRate)
+
+	return info, nil
+}
+
+// parseRIFFInfo reads the subchunks of a LIST INFO chunk
+func parseRIFFInfo(data []byte, tags *Tags) {
+	for pos := 0; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		pos += 8
+		if length < 0 || length > len(data)-pos {
+			return
+		}
+		value := strings.TrimSpace(strings.TrimRight(string(data[pos:pos+length]), "\x00"))
+		pos += length + length&1
+
+		switch id {
+		case "INAM":
+			setIfEmpty(&tags.Title, value)
+		case "IART":
+			setIfEmpty(&tags.Artist, value)
+		case "IPRD":
+			setIfEmpty(&tags.Album, value)
+		case "IGNR":
+			setIfEmpty(&tags.Genre, value)
+		case "ICRD":
+			setIfEmpty(&tags.Year, year(value))
+		case "ITRK", "IPRT":
+			setIfEmpty(&tags.Track, value)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"file-service/internal/config"
+	"file-service/pkg/logger"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// policyTimeout bounds a request to the management API
+const policyTimeout = 5 * time.Second
+
+// DeadLetters declares the dead letter topology of consumers, so dead letters
+// don't depend on another service having declared it first
+type DeadLetters struct {
+	cfg    *config.RabbitMQConfig
+	client *http.Client
+	logger *logger.Logger
+}
+
+func NewDeadLetters(cfg *config.RabbitMQConfig, l *logger.Logger) *DeadLetters {
+	return &DeadLetters{
+		cfg:    cfg,
+		client: &http.Client{Timeout: policyTimeout},
+		logger: l.WithComponent("dead_letters"),
+	}
+}
+
+// Declare declares the dead letter exchange and the dead letter queue of the
+// main queue, bound by its name, and returns the arguments the main queue
+// dead-letters with. The backend declares the same queue without arguments,
+// so the limits of the queue are set by a policy, which doesn't conflict.
+func (d *DeadLetters) Declare(ctx context.Context, ch *amqp.Channel, queue string) (amqp.Table, error) {
+	exchange := d.cfg.DeadLetter.Exchange
+	if err := ch.ExchangeDeclare(exchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare exchange %s: %w", exchange, err)
+	}
+
+	dlq := queue + ".dlq"
+
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare queue %s: %w", dlq, err)
+	}
+
+	if err := ch.QueueBind(dlq, dlq, exchange, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to bind queue %s to exchange %s: %w", dlq, exchange, err)
+	}
+
+	// Dead letters are kept either way, consuming doesn't depend on the limits
+	if err := d.applyPolicy(ctx, dlq); err != nil {
+		d.logger.Warn().Err(err).
+			Str("queue", dlq).
+			Msg("Failed to apply dead letter queue policy")
+	}
+
+	return amqp.Table{
+		"x-dead-letter-exchange":    exchange,
+		"x-dead-letter-routing-key": dlq,
+	}, nil
+}
+
+// applyPolicy sets the limits and lazy mode of the dead letter queue, or clears them once
+// they are no longer configured
+func (d *DeadLetters) applyPolicy(ctx context.Context, dlq string) error {
+	cfg := &d.cfg.DeadLetter
+	if cfg.ManagementURL == "" {
+		return nil
+	}
+
+	uri, err := amqp.ParseURI(d.cfg.URL)
+	if err != nil {
+		return fmt.Errorf("failed to parse RabbitMQ URL: %w", err)
+	}
+
+	policyURL := fmt.Sprintf("%s/api/policies/%s/%s",
+		cfg.ManagementURL, url.PathEscape(uri.Vhost), url.PathEscape(dlq+".limits"))
+
+	method, body := http.MethodDelete, []byte(nil)
+	if cfg.Limited() {
+		definition := map[string]any{}
+		if cfg.MaxLength > 0 {
+			definition["max-length"] = cfg.MaxLength
+		}
+		if cfg.MessageTTL > 0 {
+			definition["message-ttl"] = cfg.MessageTTL.Milliseconds()
+		}
+		if cfg.Lazy {
+			definition["queue-mode"] = "lazy"
+		}
+
+		method = http.MethodPut
+		body, err = json.Marshal(map[string]any{
+			"pattern":    "^" + regexp.QuoteMeta(dlq) + "$",
+			"definition": definition,
+			"apply-to":   "queues",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal policy: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, policyURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create policy request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(uri.Username, uri.Password)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to apply policy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Clearing a policy that was never set is fine
+	if resp.StatusCode >= 300 && !(method == http.MethodDelete && resp.StatusCode == http.StatusNotFound) {
+		return fmt.Errorf("management API responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"file-service/internal/config"
 	"file-service/internal/domain"
+	"file-service/internal/publisher"
 	"file-service/internal/service"
 	"file-service/pkg/logger"
 
@@ -17,25 +18,38 @@ import (
 
 type DeleteFileConsumer struct {
 	cfg         *config.RabbitMQConfig
+	supervisor  *Supervisor
+	deadLetters *DeadLetters
 	fileService service.FileService
+	events      publisher.EventPublisher
 	logger      *logger.Logger
 }
 
-func NewDeleteFileConsumer(cfg *config.RabbitMQConfig, fileService service.FileService, l *logger.Logger) *DeleteFileConsumer {
+func NewDeleteFileConsumer(
+	cfg *config.RabbitMQConfig,
+	supervisor *Supervisor,
+	deadLetters *DeadLetters,
+	fileService service.FileService,
+	events publisher.EventPublisher,
+	l *logger.Logger,
+) *DeleteFileConsumer {
 	return &DeleteFileConsumer{
 		cfg:         cfg,
+		supervisor:  supervisor,
+		deadLetters: deadLetters,
 		fileService: fileService,
+		events:      events,
 		logger:      l.WithComponent("delete_file_consumer"),
 	}
 }
 
+// Run consumes until the context ends, reconnecting whenever the connection is lost
 func (c *DeleteFileConsumer) Run(ctx context.Context) error {
-	conn, err := amqp.Dial(c.cfg.URL)
-	if err != nil {
-		return fmt.Errorf("failed to connect to RabbitMQ: %w", err)
-	}
-	defer conn.Close()
+	return c.supervisor.Run(ctx, c.consume)
+}
 
+// consume declares the queues and consumes until the channel is closed
+func (c *DeleteFileConsumer) consume(ctx context.Context, conn *amqp.Connection) error {
 	ch, err := conn.Channel()
 	if err != nil {
 		return fmt.Errorf("failed to open channel: %w", err)
@@ -49,9 +63,9 @@ func (c *DeleteFileConsumer) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to set QoS: %w", err)
 	}
 
-	args := amqp.Table{
-		"x-dead-letter-exchange":    "system.dlx",
-		"x-dead-letter-routing-key": domain.DeleteFileQueue + ".dlq",
+	args, err := c.deadLetters.Declare(ctx, ch, domain.DeleteFileQueue)
+	if err != nil {
+		return err
 	}
 
 	_, err = ch.QueueDeclare(
@@ -66,6 +80,12 @@ func (c *DeleteFileConsumer) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to declare queue %s: %w", domain.DeleteFileQueue, err)
 	}
 
+	retries, err := declareRetryQueues(conn, domain.DeleteFileQueue, &c.cfg.DeleteRetry)
+	if err != nil {
+		return err
+	}
+	defer retries.Close()
+
 	deliveries, err := ch.Consume(
 		domain.DeleteFileQueue,
 		"file-service-delete-consumer",
@@ -94,7 +114,7 @@ func (c *DeleteFileConsumer) Run(ctx context.Context) error {
 			return nil
 		case d, ok := <-deliveries:
 			if !ok {
-				c.logger.Info().Msg("RabbitMQ channel closed, shutting down...")
+				c.logger.Warn().Msg("RabbitMQ channel closed")
 				wg.Wait()
 				return nil
 			}
@@ -105,13 +125,13 @@ func (c *DeleteFileConsumer) Run(ctx context.Context) error {
 			go func(delivery amqp.Delivery) {
 				defer wg.Done()
 				defer func() { <-sem }() // Release token
-				c.handleMessage(ctx, delivery)
+				c.handleMessage(ctx, retries, delivery)
 			}(d)
 		}
 	}
 }
 
-func (c *DeleteFileConsumer) handleMessage(ctx context.Context, d amqp.Delivery) {
+func (c *DeleteFileConsumer) handleMessage(ctx context.Context, retries *retryQueues, d amqp.Delivery) {
 	nack := func(requeue bool) {
 		if err := d.Nack(false, requeue); err != nil {
 			c.logger.Error().Err(err).Msg("Failed to nack message")
@@ -155,16 +175,64 @@ func (c *DeleteFileConsumer) handleMessage(ctx context.Context, d amqp.Delivery)
 			_ = d.Ack(false)
 			return
 		}
+		if ctx.Err() != nil {
+			nack(true) // Shutting down, not a failed attempt
+			return
+		}
+		c.retry(ctx, retries, d, msg, err)
+		return
+	}
+
+	event := domain.FileEvent{FileID: msg.FileID, FileType: msg.FileType}
+	if err := c.events.PublishEvent(ctx, domain.EventFileDeleted, event); err != nil {
 		c.logger.Error().Err(err).
 			Str("file_id", msg.FileID).
-			Str("file_type", string(msg.FileType)).
-			Msg("Failed to delete file")
+			Str("event", domain.EventFileDeleted).
+			Msg("Failed to publish file event")
+	}
 
-		// TODO: Create retry mechanism
-		nack(false)
+	if err := d.Ack(false); err != nil {
+		c.logger.Error().Err(err).
+			Str("file_id", msg.FileID).
+			Msg("Failed to ack message")
+	}
+}
+
+// retry schedules another attempt of the failed deletion, only messages
+// without attempts left are dead-lettered
+func (c *DeleteFileConsumer) retry(ctx context.Context, retries *retryQueues, d amqp.Delivery, msg domain.DeleteFileMessage, err error) {
+	attempt := deliveryAttempt(d)
+
+	delay, retryErr := retries.Retry(ctx, d)
+	if errors.Is(retryErr, errRetriesExhausted) {
+		c.logger.Error().Err(err).
+			Str("file_id", msg.FileID).
+			Str("file_type", string(msg.FileType)).
+			Int("attempt", attempt).
+			Msg("Failed to delete file, retries exhausted")
+		if err := d.Nack(false, false); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to nack message")
+		}
+		return
+	}
+	if retryErr != nil {
+		// Without a scheduled retry the message is redelivered right away
+		c.logger.Error().Err(retryErr).
+			Str("file_id", msg.FileID).
+			Msg("Failed to schedule retry, requeueing")
+		if err := d.Nack(false, true); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to nack message")
+		}
 		return
 	}
 
+	c.logger.Warn().Err(err).
+		Str("file_id", msg.FileID).
+		Str("file_type", string(msg.FileType)).
+		Int("attempt", attempt).
+		Dur("retry_in", delay).
+		Msg("Failed to delete file, retrying")
+
 	if err := d.Ack(false); err != nil {
 		c.logger.Error().Err(err).
 			Str("file_id", msg.FileID).
@@ -0,0 +1,285 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/service"
+	"file-service/pkg/logger"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// bucketNotification is the event MinIO publishes through its AMQP target
+type bucketNotification struct {
+	Records []objectRecord `json:"Records"`
+}
+
+type objectRecord struct {
+	EventName string `json:"eventName"`
+	S3        struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key string `json:"key"` // URL encoded
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// ObjectCreatedConsumer processes uploads as MinIO reports them, so they are
+// processed even if the client never completes them
+type ObjectCreatedConsumer struct {
+	cfg         *config.NotificationConfig
+	supervisor  *Supervisor
+	deadLetters *DeadLetters
+	buckets     map[string]domain.FileType
+	pipeline    service.UploadPipeline
+	logger      *logger.Logger
+}
+
+func NewObjectCreatedConsumer(
+	cfg *config.Config,
+	supervisor *Supervisor,
+	deadLetters *DeadLetters,
+	pipeline service.UploadPipeline,
+	l *logger.Logger,
+) *ObjectCreatedConsumer {
+	return &ObjectCreatedConsumer{
+		cfg:         &cfg.Minio.Notifications,
+		supervisor:  supervisor,
+		deadLetters: deadLetters,
+		buckets: map[string]domain.FileType{
+			cfg.Minio.ImageBucket: domain.FileTypeImage,
+			cfg.Minio.AudioBucket: domain.FileTypeAudio,
+		},
+		pipeline: pipeline,
+		logger:   l.WithComponent("object_created_consumer"),
+	}
+}
+
+// Run consumes until the context ends, reconnecting whenever the connection is lost
+func (c *ObjectCreatedConsumer) Run(ctx context.Context) error {
+	return c.supervisor.Run(ctx, c.consume)
+}
+
+// consume declares the queue and consumes until the channel is closed
+func (c *ObjectCreatedConsumer) consume(ctx context.Context, conn *amqp.Connection) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := ch.Qos(c.cfg.Concurrency, 0, false); err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
+
+	args, err := c.deadLetters.Declare(ctx, ch, c.cfg.Queue)
+	if err != nil {
+		return err
+	}
+
+	_, err = ch.QueueDeclare(
+		c.cfg.Queue,
+		true,
+		false,
+		false,
+		false,
+		args,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", c.cfg.Queue, err)
+	}
+
+	retries, err := declareRetryQueues(conn, c.cfg.Queue, &c.cfg.Retry)
+	if err != nil {
+		return err
+	}
+	defer retries.Close()
+
+	// The exchange itself is declared by MinIO's AMQP target
+	if c.cfg.Exchange != "" {
+		if err := ch.QueueBind(c.cfg.Queue, c.cfg.RoutingKey, c.cfg.Exchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind queue %s to exchange %s: %w", c.cfg.Queue, c.cfg.Exchange, err)
+		}
+	}
+
+	deliveries, err := ch.Consume(
+		c.cfg.Queue,
+		"file-service-object-created-consumer",
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register consumer: %w", err)
+	}
+
+	c.logger.Info().
+		Str("queue", c.cfg.Queue).
+		Msg("Object created consumer started")
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.cfg.Concurrency)
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.logger.Info().Msg("Object created consumer shutting down")
+			wg.Wait()
+			return nil
+		case d, ok := <-deliveries:
+			if !ok {
+				c.logger.Warn().Msg("RabbitMQ channel closed")
+				wg.Wait()
+				return nil
+			}
+
+			sem <- struct{}{}
+			wg.Go(func() {
+				defer func() { <-sem }()
+				c.handleMessage(ctx, retries, d)
+			})
+		}
+	}
+}
+
+func (c *ObjectCreatedConsumer) handleMessage(ctx context.Context, retries *retryQueues, d amqp.Delivery) {
+	nack := func(requeue bool) {
+		if err := d.Nack(false, requeue); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to nack message")
+		}
+	}
+
+	var notification bucketNotification
+	if err := json.Unmarshal(d.Body, &notification); err != nil {
+		c.logger.Error().Err(err).
+			Str("body", string(d.Body)).
+			Msg("Failed to unmarshal bucket notification")
+		nack(false) // Don't requeue malformed JSON
+		return
+	}
+
+	for _, record := range notification.Records {
+		fileType, fileID, ok := c.upload(record)
+		if !ok {
+			continue
+		}
+
+		_, err := c.pipeline.Process(ctx, fileType, fileID)
+		switch {
+		case err == nil:
+		case errors.Is(err, domain.ErrFileNotFound):
+			c.logger.Debug().
+				Str("file_id", fileID).
+				Str("file_type", string(fileType)).
+				Msg("Uploaded file not found, acking (deleted)")
+		case errors.Is(err, domain.ErrInvalidFileContent), errors.Is(err, domain.ErrMalwareDetected):
+			// Rejected files are quarantined, retrying won't change the verdict.
+			// The message is kept for inspection.
+			nack(false)
+			return
+		case ctx.Err() != nil:
+			nack(true) // Shutting down, another replica takes over
+			return
+		default:
+			// The pipeline logged the failure and recorded it in the processing status
+			c.retry(ctx, retries, d, fileType, fileID, err)
+			return
+		}
+	}
+
+	if err := d.Ack(false); err != nil {
+		c.logger.Error().Err(err).Msg("Failed to ack message")
+	}
+}
+
+// retry schedules another attempt of the failed processing, only messages
+// without attempts left are dead-lettered
+func (c *ObjectCreatedConsumer) retry(
+	ctx context.Context,
+	retries *retryQueues,
+	d amqp.Delivery,
+	fileType domain.FileType,
+	fileID string,
+	err error,
+) {
+	attempt := deliveryAttempt(d)
+
+	delay, retryErr := retries.Retry(ctx, d)
+	if errors.Is(retryErr, errRetriesExhausted) {
+		c.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Str("file_type", string(fileType)).
+			Int("attempt", attempt).
+			Msg("Failed to process upload, retries exhausted")
+		if err := d.Nack(false, false); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to nack message")
+		}
+		return
+	}
+	if retryErr != nil {
+		// Without a scheduled retry the message is redelivered right away
+		c.logger.Error().Err(retryErr).
+			Str("file_id", fileID).
+			Msg("Failed to schedule retry, requeueing")
+		if err := d.Nack(false, true); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to nack message")
+		}
+		return
+	}
+
+	c.logger.Warn().Err(err).
+		Str("file_id", fileID).
+		Str("file_type", string(fileType)).
+		Int("attempt", attempt).
+		Dur("retry_in", delay).
+		Msg("Failed to process upload, retrying")
+
+	if err := d.Ack(false); err != nil {
+		c.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Msg("Failed to ack message")
+	}
+}
+
+// upload returns the uploaded file the record reports. Objects derived from
+// uploads, like variants and waveforms, and copies are skipped: metadata is
+// updated by copying objects onto themselves.
+func (c *ObjectCreatedConsumer) upload(record objectRecord) (domain.FileType, string, bool) {
+	event := strings.TrimPrefix(record.EventName, "s3:")
+	if !strings.HasPrefix(event, "ObjectCreated:") || event == "ObjectCreated:Copy" {
+		return "", "", false
+	}
+
+	fileType, ok := c.buckets[record.S3.Bucket.Name]
+	if !ok {
+		return "", "", false
+	}
+
+	key, err := url.QueryUnescape(record.S3.Object.Key)
+	if err != nil {
+		c.logger.Warn().Err(err).
+			Str("key", record.S3.Object.Key).
+			Msg("Invalid object key in bucket notification")
+		return "", "", false
+	}
+
+	// Uploads are stored under their ID, derived objects under prefixes
+	if _, err := uuid.Parse(key); err != nil || strings.Contains(key, "/") {
+		return "", "", false
+	}
+
+	return fileType, key, true
+}
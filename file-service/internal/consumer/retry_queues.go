@@ -0,0 +1,129 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"file-service/internal/config"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// attemptHeader carries the number of the delivery attempt, starting at 1
+const attemptHeader = "x-attempt"
+
+// retryConfirmTimeout bounds the wait for the broker to confirm a retry
+const retryConfirmTimeout = 5 * time.Second
+
+var errRetriesExhausted = errors.New("retries exhausted")
+
+// retryQueues delays redeliveries of failed messages. Each delay of the
+// schedule has a queue without consumers whose messages expire after the
+// delay and are dead-lettered back to the main queue. The queues are named
+// after their delay, so changing the schedule doesn't conflict with the
+// arguments of existing queues.
+type retryQueues struct {
+	queue string
+	cfg   *config.RetryConfig
+	ch    *amqp.Channel // in confirm mode, so retries aren't lost
+}
+
+// declareRetryQueues declares the retry queues of the main queue on a channel of their own
+func declareRetryQueues(conn *amqp.Connection, queue string, cfg *config.RetryConfig) (*retryQueues, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open retry channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	r := &retryQueues{queue: queue, cfg: cfg, ch: ch}
+
+	for _, delay := range cfg.Delays {
+		args := amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		}
+		if _, err := ch.QueueDeclare(r.name(delay), true, false, false, false, args); err != nil {
+			ch.Close()
+			return nil, fmt.Errorf("failed to declare queue %s: %w", r.name(delay), err)
+		}
+	}
+
+	return r, nil
+}
+
+func (r *retryQueues) name(delay time.Duration) string {
+	return r.queue + ".retry." + delay.String()
+}
+
+// Retry schedules the redelivery of the failed message and returns the delay.
+// It returns errRetriesExhausted once the message had its last attempt, the
+// caller dead-letters it then.
+func (r *retryQueues) Retry(ctx context.Context, d amqp.Delivery) (time.Duration, error) {
+	attempt := deliveryAttempt(d)
+	if attempt >= r.cfg.MaxAttempts {
+		return 0, errRetriesExhausted
+	}
+
+	delay := r.cfg.Delays[min(attempt, len(r.cfg.Delays))-1]
+
+	// The broker records dead-lettering in death headers of its own
+	headers := amqp.Table{}
+	for key, value := range d.Headers {
+		if !strings.HasPrefix(key, "x-death") && !strings.HasPrefix(key, "x-first-death-") &&
+			!strings.HasPrefix(key, "x-last-death-") {
+			headers[key] = value
+		}
+	}
+	headers[attemptHeader] = int32(attempt + 1)
+
+	confirmation, err := r.ch.PublishWithDeferredConfirmWithContext(ctx, "", r.name(delay), false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    d.MessageId,
+		Timestamp:    d.Timestamp,
+		Type:         d.Type,
+		Body:         d.Body,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to publish retry: %w", err)
+	}
+
+	confirmCtx, cancel := context.WithTimeout(ctx, retryConfirmTimeout)
+	defer cancel()
+
+	acked, err := confirmation.WaitContext(confirmCtx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to await confirmation of retry: %w", err)
+	}
+	if !acked {
+		return 0, errors.New("retry was rejected by the broker")
+	}
+
+	return delay, nil
+}
+
+func (r *retryQueues) Close() error {
+	return r.ch.Close()
+}
+
+// deliveryAttempt returns the attempt number of the delivery, messages
+// without the header are on their first attempt
+func deliveryAttempt(d amqp.Delivery) int {
+	switch attempt := d.Headers[attemptHeader].(type) {
+	case int32:
+		return max(int(attempt), 1)
+	case int64:
+		return max(int(attempt), 1)
+	default:
+		return 1
+	}
+}
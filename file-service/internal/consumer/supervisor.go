@@ -0,0 +1,179 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"file-service/internal/config"
+	"file-service/pkg/logger"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Connection states of a supervisor
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateStopped      = "stopped"
+)
+
+var (
+	consumerConnected = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rabbitmq_consumer_connected",
+			Help: "Whether the consumer is connected to RabbitMQ",
+		},
+		[]string{"consumer"},
+	)
+
+	consumerReconnectsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rabbitmq_consumer_reconnects_total",
+			Help: "Total number of times the consumer lost its RabbitMQ connection or failed to connect",
+		},
+		[]string{"consumer"},
+	)
+
+	errSessionEnded     = errors.New("consumer session ended")
+	errConnectionClosed = errors.New("connection closed")
+)
+
+// Session consumes on the connection until it is closed or the context ends.
+// It declares its topology first, since the broker may have lost it.
+type Session func(ctx context.Context, conn *amqp.Connection) error
+
+// ConnectionStatus is the state of a supervised connection
+type ConnectionStatus struct {
+	State     string    `json:"state"`
+	Since     time.Time `json:"since"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Supervisor keeps a consumer connected to RabbitMQ. When the connection is
+// lost or can't be established, it reconnects with jittered exponential
+// backoff and starts a new session.
+type Supervisor struct {
+	name   string
+	cfg    *config.RabbitMQConfig
+	logger *logger.Logger
+
+	mu     sync.Mutex
+	status ConnectionStatus
+}
+
+func NewSupervisor(name string, cfg *config.RabbitMQConfig, l *logger.Logger) *Supervisor {
+	s := &Supervisor{
+		name:   name,
+		cfg:    cfg,
+		logger: l.WithComponent("rabbitmq_supervisor"),
+	}
+	s.setState(StateConnecting, nil)
+	return s
+}
+
+func (s *Supervisor) Name() string {
+	return s.name
+}
+
+// Status returns the current state of the connection
+func (s *Supervisor) Status() ConnectionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+// Run runs sessions until the context ends
+func (s *Supervisor) Run(ctx context.Context, session Session) error {
+	initial := s.cfg.Reconnect.InitialBackoff
+	maxBackoff := max(s.cfg.Reconnect.MaxBackoff, initial)
+	backoff := initial
+
+	for {
+		err := s.connect(ctx, session, func() { backoff = initial })
+		if ctx.Err() != nil {
+			s.setState(StateStopped, nil)
+			return nil
+		}
+
+		s.setState(StateReconnecting, err)
+		consumerReconnectsTotal.WithLabelValues(s.name).Inc()
+
+		// Jitter keeps replicas from reconnecting all at once after a broker restart
+		delay := backoff/2 + rand.N(backoff/2+1)
+		s.logger.Warn().Err(err).
+			Str("consumer", s.name).
+			Dur("backoff", delay).
+			Msg("Disconnected from RabbitMQ, reconnecting")
+
+		select {
+		case <-ctx.Done():
+			s.setState(StateStopped, nil)
+			return nil
+		case <-time.After(delay):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// connect runs a session on a new connection and returns why it ended. The
+// backoff is reset once the connection proved stable.
+func (s *Supervisor) connect(ctx context.Context, session Session, resetBackoff func()) error {
+	conn, err := amqp.Dial(s.cfg.URL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	s.setState(StateConnected, nil)
+	s.logger.Info().Str("consumer", s.name).Msg("Connected to RabbitMQ")
+
+	// The session drains its in-flight messages after the connection closed,
+	// the state must not claim it is connected meanwhile
+	go func() {
+		if closeErr, ok := <-closed; ok && closeErr != nil {
+			s.setState(StateReconnecting, closeErr)
+		}
+	}()
+
+	connectedAt := time.Now()
+	err = session(ctx, conn)
+	if time.Since(connectedAt) >= s.cfg.Reconnect.MaxBackoff {
+		resetBackoff()
+	}
+
+	if err == nil && !conn.IsClosed() {
+		// The session ended on its own, e.g. its channel was closed by the broker
+		return errSessionEnded
+	}
+	if err == nil {
+		return errConnectionClosed
+	}
+	return err
+}
+
+func (s *Supervisor) setState(state string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status.State != state {
+		s.status.State = state
+		s.status.Since = time.Now().UTC()
+	}
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+
+	connected := 0.0
+	if state == StateConnected {
+		connected = 1
+	}
+	consumerConnected.WithLabelValues(s.name).Set(connected)
+}
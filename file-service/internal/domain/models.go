@@ -2,18 +2,105 @@ package domain
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strconv"
 	"time"
 )
 
 const DeleteFileQueue = "file-service.delete-file"
 
-var ErrFileNotFound = errors.New("file not found")
+// AudioMetadataQueue receives the metadata extracted from completed audio uploads
+const AudioMetadataQueue = "file-service.audio-metadata"
+
+// UploadRejectedQueue receives the uploads rejected and quarantined by file-service
+const UploadRejectedQueue = "file-service.upload-rejected"
+
+// Types of the file lifecycle events, they are the routing keys on the events exchange
+const (
+	EventFileUploaded  = "file.uploaded"
+	EventFileProcessed = "file.processed"
+	EventFileRejected  = "file.rejected"
+	EventFileDeleted   = "file.deleted"
+)
+
+// AudioLoudnessQueue receives the loudness measured on audio uploads
+const AudioLoudnessQueue = "file-service.audio-loudness"
+
+var (
+	ErrFileNotFound          = errors.New("file not found")
+	ErrPreviewNotFound       = errors.New("preview clip not found")
+	ErrFileNotReady          = errors.New("file is not processed yet")
+	ErrForbidden             = errors.New("file belongs to another user")
+	ErrFileTooLarge          = errors.New("file size exceeds the limit")
+	ErrContentTypeNotAllowed = errors.New("content type is not allowed")
+	ErrInvalidFileContent    = errors.New("file content does not match file type")
+	ErrMalwareDetected       = errors.New("file contains malware")
+	ErrUploadNotFound        = errors.New("multipart upload not found")
+	ErrOffsetMismatch        = errors.New("upload offset does not match")
+	ErrNotSupported          = errors.New("operation is not supported by the storage backend")
+	ErrQuotaExceeded         = errors.New("storage quota exceeded")
+	ErrInvalidTransform      = errors.New("invalid image transformation")
+	ErrTransformNotAllowed   = errors.New("image transformation is not allowed")
+)
+
+// QuotaExceededError describes a rejected upload, it matches ErrQuotaExceeded
+type QuotaExceededError struct {
+	FileType  FileType
+	Used      int64
+	Limit     int64
+	Requested int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s quota exceeded: %d of %d bytes used, %d requested",
+		e.FileType, e.Used, e.Limit, e.Requested)
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Object user metadata keys recorded by the service
+const (
+	MetadataUploadStatus = "Upload-Status"
+	MetadataChecksum     = "Sha256"
+	MetadataOwner        = "Owner"
+	// MetadataImageVariants lists the sizes of the generated image variants, e.g. "64,300"
+	MetadataImageVariants = "Image-Variants"
+	// MetadataWaveforms lists the resolutions of the generated waveforms, e.g. "256,1024"
+	MetadataWaveforms = "Waveforms"
+	// MetadataPreview holds the length of the audio preview clip in seconds, e.g. "30"
+	MetadataPreview = "Preview"
+	// MetadataRejection holds the reason a quarantined object was rejected for
+	MetadataRejection = "Rejection"
+	// MetadataDetectedContentType holds the content type sniffed from a quarantined object
+	MetadataDetectedContentType = "Detected-Content-Type"
+	// MetadataScanVerdict holds the verdict of the malware scan, ScanVerdictClean or ScanVerdictInfected
+	MetadataScanVerdict = "Scan-Verdict"
+	// MetadataScanSignature holds the name of the malware found in a quarantined object
+	MetadataScanSignature = "Scan-Signature"
+)
+
+// Verdicts of the malware scan
+const (
+	ScanVerdictClean    = "clean"
+	ScanVerdictInfected = "infected"
+)
+
+// VariantPreview selects the preview clip of an audio file for download
+const VariantPreview = "preview"
+
+const UploadStatusCompleted = "completed"
 
 type FileType string
 
 const (
 	FileTypeImage FileType = "image"
 	FileTypeAudio FileType = "audio"
+	// FileTypeQuarantine addresses the private storage of rejected uploads,
+	// clients can't use it
+	FileTypeQuarantine FileType = "quarantine"
 )
 
 type PresignedURLResponse struct {
@@ -22,16 +109,348 @@ type PresignedURLResponse struct {
 	FileID    string    `json:"file_id"`
 }
 
-type UploadRequest struct {
+// PresignedPostResponse describes an S3 POST policy upload: the client sends
+// a multipart/form-data request to URL with FormData fields followed by the file
+type PresignedPostResponse struct {
+	URL       string            `json:"url"`
+	FormData  map[string]string `json:"form_data"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	FileID    string            `json:"file_id"`
+}
+
+// FileInfo describes a stored object
+type FileInfo struct {
+	FileID       string            `json:"file_id"`
+	FileType     FileType          `json:"file_type"`
+	Size         int64             `json:"size"`
+	ContentType  string            `json:"content_type"`
+	Checksum     string            `json:"checksum,omitempty"`
+	LastModified time.Time         `json:"last_modified"`
+	Metadata     map[string]string `json:"-"`
+}
+
+// Ready tells if the file completed processing and may be downloaded. When
+// scanning is required it must have been scanned clean as well.
+func (f *FileInfo) Ready(scanRequired bool) bool {
+	if f.Metadata[MetadataUploadStatus] != UploadStatusCompleted {
+		return false
+	}
+	return !scanRequired || f.Metadata[MetadataScanVerdict] == ScanVerdictClean
+}
+
+type CompleteUploadRequest struct {
 	FileType FileType `json:"file_type" binding:"required,oneof=image audio"`
 }
 
+type UploadRequest struct {
+	FileType    FileType `json:"file_type" binding:"required,oneof=image audio"`
+	ContentType string   `json:"content_type" binding:"omitempty,max=255"`
+	Size        int64    `json:"size" binding:"omitempty,gt=0"`
+	// OwnerID is the authenticated uploader, it is never read from the request body
+	OwnerID string `json:"-"`
+	// QuotaLeft is what the owner may still store, zero when no quota applies
+	QuotaLeft int64 `json:"-"`
+}
+
+// MaxSize returns the largest upload allowed by the size limit and the quota
+// left. It bounds uploads whose size wasn't declared.
+func (r UploadRequest) MaxSize(limit int64) int64 {
+	if r.QuotaLeft > 0 {
+		return min(limit, r.QuotaLeft)
+	}
+	return limit
+}
+
+// Validate checks the declared size and content type against the limits of the file type
+func (r UploadRequest) Validate(maxSize int64, contentTypes []string) error {
+	if r.Size > maxSize {
+		return ErrFileTooLarge
+	}
+	if r.ContentType != "" && !slices.Contains(contentTypes, r.ContentType) {
+		return ErrContentTypeNotAllowed
+	}
+	return nil
+}
+
+type MultipartUploadResponse struct {
+	FileID   string `json:"file_id"`
+	UploadID string `json:"upload_id"`
+	PartSize int64  `json:"part_size"`
+}
+
+type PartURLsRequest struct {
+	FileType    FileType `json:"file_type" binding:"required,oneof=image audio"`
+	FileID      string   `json:"file_id" binding:"required"`
+	PartNumbers []int    `json:"part_numbers" binding:"required,min=1,max=10000,dive,min=1,max=10000"`
+}
+
+type PartURL struct {
+	PartNumber int    `json:"part_number"`
+	URL        string `json:"url"`
+}
+
+type PartURLsResponse struct {
+	Parts     []PartURL `json:"parts"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type CompletedPart struct {
+	PartNumber int    `json:"part_number" binding:"min=1,max=10000"`
+	ETag       string `json:"etag" binding:"required"`
+}
+
+type CompleteMultipartRequest struct {
+	FileType FileType        `json:"file_type" binding:"required,oneof=image audio"`
+	FileID   string          `json:"file_id" binding:"required"`
+	Parts    []CompletedPart `json:"parts" binding:"required,min=1,dive"`
+}
+
+// MultipartUpload records who initiated a multipart upload. Storage doesn't
+// expose the metadata of an upload before it is completed, so the record is
+// the only way to check that parts are uploaded by the owner.
+type MultipartUpload struct {
+	UploadID string   `json:"upload_id"`
+	FileID   string   `json:"file_id"`
+	FileType FileType `json:"file_type"`
+	OwnerID  string   `json:"owner_id,omitempty"`
+}
+
+// TusUpload is the persisted state of a resumable upload. Received bytes are
+// stored as multipart upload parts, the remainder smaller than a part is kept
+// in a temporary tail object until more data arrives.
+type TusUpload struct {
+	FileID      string          `json:"file_id"`
+	FileType    FileType        `json:"file_type"`
+	ContentType string          `json:"content_type,omitempty"`
+	OwnerID     string          `json:"owner_id,omitempty"`
+	Length      int64           `json:"length"`
+	Offset      int64           `json:"offset"`
+	UploadID    string          `json:"upload_id"`
+	Parts       []CompletedPart `json:"parts"`
+	TailSize    int64           `json:"tail_size"`
+	ExpiresAt   time.Time       `json:"expires_at"`
+}
+
+// TusTailKey returns the object key of the temporary tail of a resumable upload
+func TusTailKey(fileID string) string {
+	return TusTailPrefix + fileID
+}
+
+const TusTailPrefix = "tus-tail/"
+
+// ImageVariantKey returns the object key of a resized variant of an image
+func ImageVariantKey(fileID string, size int) string {
+	return ImageVariantPrefix + fileID + "/" + strconv.Itoa(size)
+}
+
+const ImageVariantPrefix = "variants/"
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Details string `json:"details,omitempty"`
 }
 
+// Fit modes of image transformations
+const (
+	FitCover   = "cover"   // fill the box and crop the overflow
+	FitContain = "contain" // fit inside the box, keeping the aspect ratio
+	FitFill    = "fill"    // stretch to the box
+)
+
+// ImageTransformRequest describes an on-the-fly image transformation, a
+// zero width or height follows the aspect ratio of the image
+type ImageTransformRequest struct {
+	Width     int    `form:"w" binding:"min=0"`
+	Height    int    `form:"h" binding:"min=0"`
+	Fit       string `form:"fit" binding:"omitempty,oneof=cover contain fill"`
+	Quality   int    `form:"q" binding:"omitempty,min=1,max=100"`
+	Signature string `form:"sig"`
+}
+
+type TransformedImage struct {
+	Data        []byte
+	ContentType string
+	ETag        string
+}
+
+// QuotaErrorResponse is returned when an upload would exceed the user's quota
+type QuotaErrorResponse struct {
+	Error     string   `json:"error"`
+	FileType  FileType `json:"file_type"`
+	Used      int64    `json:"used"`
+	Limit     int64    `json:"limit"`
+	Requested int64    `json:"requested"`
+}
+
+// FileTypeUsage is the storage used by a user for one file type, a zero limit means unlimited
+type FileTypeUsage struct {
+	FileType FileType `json:"file_type"`
+	Used     int64    `json:"used"`
+	Limit    int64    `json:"limit"`
+}
+
+type UsageResponse struct {
+	UserID string          `json:"user_id"`
+	Usage  []FileTypeUsage `json:"usage"`
+}
+
+// WaveformPrefix prefixes the keys of waveforms generated from audio files
+const WaveformPrefix = "waveforms/"
+
+// WaveformKey returns the key of the waveform of an audio file with the given resolution
+func WaveformKey(fileID string, resolution int) string {
+	return WaveformPrefix + fileID + "/" + strconv.Itoa(resolution) + ".json"
+}
+
+// QuarantineKey returns the key a rejected upload is moved to in the
+// quarantine storage, which is never served
+func QuarantineKey(fileType FileType, fileID string) string {
+	return string(fileType) + "/" + fileID
+}
+
+// Reasons for rejecting an upload
+const (
+	RejectionContentMismatch = "content_mismatch"
+	RejectionMalware         = "malware"
+)
+
+// Rejection describes why an upload was rejected
+type Rejection struct {
+	Reason              string `json:"reason"`
+	DetectedContentType string `json:"detected_content_type,omitempty"`
+	Signature           string `json:"signature,omitempty"` // malware found by the scan
+}
+
+// UploadRejectedMessage is published to UploadRejectedQueue
+type UploadRejectedMessage struct {
+	FileID   string   `json:"file_id"`
+	FileType FileType `json:"file_type"`
+	OwnerID  string   `json:"owner_id,omitempty"`
+	Rejection
+	DeclaredContentType string    `json:"declared_content_type,omitempty"`
+	QuarantineKey       string    `json:"quarantine_key,omitempty"`
+	RejectedAt          time.Time `json:"rejected_at"`
+}
+
+// PreviewPrefix prefixes the keys of preview clips cut from audio files
+const PreviewPrefix = "previews/"
+
+// PreviewKey returns the key of the preview clip of an audio file
+func PreviewKey(fileID string) string {
+	return PreviewPrefix + fileID
+}
+
+// AudioMetadataPrefix prefixes the keys of the extracted audio metadata
+const AudioMetadataPrefix = "metadata/"
+
+// AudioMetadataKey returns the key of the metadata extracted from an audio file
+func AudioMetadataKey(fileID string) string {
+	return AudioMetadataPrefix + fileID + ".json"
+}
+
+// AudioMetadata holds the tags and stream properties read from an audio file,
+// tags missing from the file are omitted
+type AudioMetadata struct {
+	FileID          string  `json:"file_id"`
+	Format          string  `json:"format"`
+	Title           string  `json:"title,omitempty"`
+	Artist          string  `json:"artist,omitempty"`
+	Album           string  `json:"album,omitempty"`
+	Genre           string  `json:"genre,omitempty"`
+	Year            string  `json:"year,omitempty"`
+	Track           string  `json:"track,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Bitrate         int     `json:"bitrate"`
+	SampleRate      int     `json:"sample_rate"`
+	Channels        int     `json:"channels"`
+	// CoverImageID is the image file extracted from the embedded cover art
+	CoverImageID string `json:"cover_image_id,omitempty"`
+	// Loudness is measured in the background and missing until then
+	Loudness *Loudness `json:"loudness,omitempty"`
+}
+
+// Loudness holds EBU R128 measurements of an audio file. Players normalize
+// by applying the track gain, limited so that the true peak doesn't clip.
+type Loudness struct {
+	IntegratedLUFS float64 `json:"integrated_lufs"`
+	RangeLU        float64 `json:"range_lu"`
+	TruePeakDBTP   float64 `json:"true_peak_dbtp"`
+	// TrackGainDB is the ReplayGain 2.0 track gain towards -18 LUFS
+	TrackGainDB float64 `json:"track_gain_db"`
+}
+
+// AudioLoudnessMessage is published to AudioLoudnessQueue
+type AudioLoudnessMessage struct {
+	FileID  string `json:"file_id"`
+	OwnerID string `json:"owner_id,omitempty"`
+	Loudness
+}
+
+// AudioMetadataMessage is published to AudioMetadataQueue
+type AudioMetadataMessage struct {
+	AudioMetadata
+	OwnerID string `json:"owner_id,omitempty"`
+}
+
 type DeleteFileMessage struct {
 	FileType FileType `json:"file_type"`
 	FileID   string   `json:"file_id"`
 }
+
+// Duplicate is an audio file likely holding the same recording
+type Duplicate struct {
+	FileID  string `json:"file_id"`
+	OwnerID string `json:"owner_id,omitempty"`
+	// Similarity of the acoustic fingerprints, 1 for identical audio
+	Similarity float64 `json:"similarity"`
+}
+
+// DuplicatesResponse lists the likely duplicates of an audio file, most similar first
+type DuplicatesResponse struct {
+	FileID     string      `json:"file_id"`
+	Duplicates []Duplicate `json:"duplicates"`
+}
+
+// Processing states of an uploaded file
+const (
+	ProcessingStateProcessing = "processing"
+	ProcessingStateCompleted  = "completed"
+	ProcessingStateFailed     = "failed"
+	ProcessingStateRejected   = "rejected"
+)
+
+// ProcessingStatus tracks the processing of an uploaded file by its processor chain
+type ProcessingStatus struct {
+	FileID   string   `json:"file_id"`
+	FileType FileType `json:"file_type"`
+	State    string   `json:"state"`
+	// Processor is the processor running, or the one that failed or rejected the file
+	Processor string    `json:"processor,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EventSchemaVersion is the version of the event envelope and data, it changes
+// only with incompatible changes
+const EventSchemaVersion = 1
+
+// EventEnvelope wraps the data of every event published to the events exchange
+type EventEnvelope struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	Source     string    `json:"source"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// FileEvent is the data of the file lifecycle events
+type FileEvent struct {
+	FileID      string     `json:"file_id"`
+	FileType    FileType   `json:"file_type"`
+	OwnerID     string     `json:"owner_id,omitempty"`
+	Size        int64      `json:"size,omitempty"`
+	ContentType string     `json:"content_type,omitempty"`
+	Checksum    string     `json:"checksum,omitempty"`
+	Rejection   *Rejection `json:"rejection,omitempty"` // set on file.rejected
+}
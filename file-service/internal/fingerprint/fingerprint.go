@@ -0,0 +1,313 @@
+// Package fingerprint computes acoustic fingerprints of audio to find copies
+// of a recording, regardless of its encoding, bitrate or tags.
+//
+// Like Chromaprint, a fingerprint is a sequence of 32-bit sub-fingerprints
+// of overlapping frames of audio. The bits of a sub-fingerprint tell whether
+// the energy difference of two adjacent frequency bands grew or shrank since
+// the previous frame (Haitsma and Kalker, "A Highly Robust Audio
+// Fingerprinting System"). Lossy encoding flips few bits, so copies are found
+// by the bit error rate of aligned fingerprints.
+package fingerprint
+
+import (
+	"math"
+	"math/bits"
+)
+
+const (
+	sampleRate = 11025
+	frameSize  = 4096
+	// hopSize is small, so that copies cut at a different time still have
+	// frames aligned closely enough to share bits
+	hopSize = frameSize / 16
+	// maxSamples limits the fingerprint to the first two minutes like
+	// Chromaprint does, which is plenty to tell recordings apart
+	maxSamples = 120 * sampleRate
+
+	bandCount    = 33
+	minFrequency = 300
+	maxFrequency = 2000
+	// cutoffFrequency of the low pass filter applied before resampling
+	cutoffFrequency = 4000
+
+	// hashShift drops the low bits of sub-fingerprints used as lookup hashes,
+	// fewer bits survive lossy encoding more often
+	hashShift = 12
+	// minOverlap is the least number of aligned sub-fingerprints to compare,
+	// about five seconds
+	minOverlap = 5 * sampleRate / hopSize
+	// alignments is the number of most voted offsets compared
+	alignments = 3
+)
+
+var (
+	window    = hannWindow(frameSize)
+	bandEdges = logBandEdges()
+	cos, sin  = twiddles(frameSize)
+)
+
+// Fingerprinter computes the fingerprint of decoded audio. It implements
+// audiodecode.Sink.
+type Fingerprinter struct {
+	step     float64 // position advance of input samples in resampled samples
+	channels int
+	lowPass  [2]biquad
+
+	position float64
+	previous float64
+	total    int
+	frame    []float32
+	re, im   []float64
+	energy   []float64
+	prev     []float64
+	result   []uint32
+}
+
+func New() *Fingerprinter {
+	return &Fingerprinter{
+		frame:  make([]float32, 0, frameSize),
+		re:     make([]float64, frameSize),
+		im:     make([]float64, frameSize),
+		energy: make([]float64, bandCount),
+	}
+}
+
+func (f *Fingerprinter) Format(rate int, channels int) {
+	f.step = sampleRate / float64(rate)
+	f.channels = channels
+	// Fourth order Butterworth against aliasing, the bands end well below
+	f.lowPass[0] = newLowPass(float64(rate), cutoffFrequency, 0.5412)
+	f.lowPass[1] = newLowPass(float64(rate), cutoffFrequency, 1.3066)
+}
+
+// Write downmixes the samples to mono and resamples them by interpolation
+func (f *Fingerprinter) Write(samples []float32) {
+	for i := 0; i+f.channels <= len(samples); i += f.channels {
+		if f.total >= maxSamples {
+			return
+		}
+
+		var mono float64
+		for _, sample := range samples[i : i+f.channels] {
+			mono += float64(sample)
+		}
+		mono /= float64(f.channels)
+		for j := range f.lowPass {
+			mono = f.lowPass[j].process(mono)
+		}
+
+		// position is the offset of the next resampled sample past the previous input sample
+		for ; f.position < f.step; f.position++ {
+			fraction := f.position / f.step
+			f.add(float32(f.previous + (mono-f.previous)*fraction))
+		}
+		f.position -= f.step
+		f.previous = mono
+	}
+}
+
+func (f *Fingerprinter) add(sample float32) {
+	f.total++
+	f.frame = append(f.frame, sample)
+	if len(f.frame) < frameSize {
+		return
+	}
+
+	f.processFrame()
+	f.frame = append(f.frame[:0], f.frame[hopSize:]...)
+}
+
+func (f *Fingerprinter) processFrame() {
+	for i, sample := range f.frame {
+		f.re[i] = float64(sample) * window[i]
+		f.im[i] = 0
+	}
+	fft(f.re, f.im)
+
+	for band := range bandCount {
+		var energy float64
+		for bin := bandEdges[band]; bin < bandEdges[band+1]; bin++ {
+			energy += f.re[bin]*f.re[bin] + f.im[bin]*f.im[bin]
+		}
+		f.energy[band] = energy
+	}
+
+	if f.prev != nil {
+		var value uint32
+		for band := range bandCount - 1 {
+			diff := f.energy[band] - f.energy[band+1] - (f.prev[band] - f.prev[band+1])
+			if diff > 0 {
+				value |= 1 << band
+			}
+		}
+		f.result = append(f.result, value)
+	} else {
+		f.prev = make([]float64, bandCount)
+	}
+	copy(f.prev, f.energy)
+}
+
+// Fingerprint returns the sub-fingerprints of the audio written so far
+func (f *Fingerprinter) Fingerprint() []uint32 {
+	return f.result
+}
+
+// Hashes returns the distinct lookup hashes of a fingerprint. Copies of a
+// recording share many of them. Silence, whose sub-fingerprints are zero, is
+// left out.
+func Hashes(fingerprint []uint32) []uint32 {
+	seen := make(map[uint32]struct{}, len(fingerprint))
+	hashes := make([]uint32, 0, len(fingerprint))
+	for _, value := range fingerprint {
+		hash := value >> hashShift
+		if hash == 0 {
+			continue
+		}
+		if _, ok := seen[hash]; !ok {
+			seen[hash] = struct{}{}
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+// Similarity compares two fingerprints at the offsets where most of their
+// hashes line up. It returns 1 for identical audio and about 0 for unrelated
+// audio, whose bits match by chance half of the time.
+func Similarity(a []uint32, b []uint32) float64 {
+	positions := make(map[uint32][]int, len(b))
+	for j, value := range b {
+		if hash := value >> hashShift; hash != 0 {
+			positions[hash] = append(positions[hash], j)
+		}
+	}
+
+	votes := make(map[int]int)
+	for i, value := range a {
+		for _, j := range positions[value>>hashShift] {
+			votes[j-i]++
+		}
+	}
+
+	var best float64
+	for range alignments {
+		offset, count := 0, 0
+		for o, c := range votes {
+			if c > count || (c == count && o < offset) {
+				offset, count = o, c
+			}
+		}
+		if count == 0 {
+			break
+		}
+		delete(votes, offset)
+
+		best = max(best, alignedSimilarity(a, b, offset))
+	}
+	return best
+}
+
+// alignedSimilarity compares a[i] with b[i+offset]
+func alignedSimilarity(a []uint32, b []uint32, offset int) float64 {
+	start := max(0, -offset)
+	end := min(len(a), len(b)-offset)
+	if end-start < minOverlap {
+		return 0
+	}
+
+	var flipped int
+	for i := start; i < end; i++ {
+		flipped += bits.OnesCount32(a[i] ^ b[i+offset])
+	}
+	bitErrorRate := float64(flipped) / float64((end-start)*(bandCount-1))
+	return max(0, 1-2*bitErrorRate)
+}
+
+func hannWindow(size int) []float64 {
+	window := make([]float64, size)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(size-1))
+	}
+	return window
+}
+
+// logBandEdges returns the first FFT bin of each band and the end of the last
+func logBandEdges() []int {
+	edges := make([]int, bandCount+1)
+	for i := range edges {
+		frequency := minFrequency * math.Pow(maxFrequency/minFrequency, float64(i)/bandCount)
+		edges[i] = int(math.Round(frequency * frameSize / sampleRate))
+	}
+	return edges
+}
+
+// twiddles returns the roots of unity of a transform of the given size
+func twiddles(size int) ([]float64, []float64) {
+	cos, sin := make([]float64, size/2), make([]float64, size/2)
+	for k := range size / 2 {
+		angle := -2 * math.Pi * float64(k) / float64(size)
+		cos[k], sin[k] = math.Cos(angle), math.Sin(angle)
+	}
+	return cos, sin
+}
+
+// fft computes the discrete Fourier transform of a frame in place
+func fft(re []float64, im []float64) {
+	n := len(re)
+
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			re[i], re[j] = re[j], re[i]
+			im[i], im[j] = im[j], im[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		stride := n / size
+		for start := 0; start < n; start += size {
+			for k := range size / 2 {
+				wr, wi := cos[k*stride], sin[k*stride]
+				a, b := start+k, start+k+size/2
+				tr := re[b]*wr - im[b]*wi
+				ti := re[b]*wi + im[b]*wr
+				re[b], im[b] = re[a]-tr, im[a]-ti
+				re[a], im[a] = re[a]+tr, im[a]+ti
+			}
+		}
+	}
+}
+
+// biquad is a second order filter in transposed direct form II
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+// newLowPass returns a low pass section with the quality factor q, the
+// sections of a Butterworth filter differ in q only
+func newLowPass(rate float64, cutoff float64, q float64) biquad {
+	w := 2 * math.Pi * min(cutoff, rate*0.45) / rate
+	alpha := math.Sin(w) / (2 * q)
+	a0 := 1 + alpha
+	b := (1 - math.Cos(w)) / 2 / a0
+
+	return biquad{
+		b0: b,
+		b1: 2 * b,
+		b2: b,
+		a1: -2 * math.Cos(w) / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (q *biquad) process(x float64) float64 {
+	y := q.b0*x + q.z1
+	q.z1 = q.b1*x - q.a1*y + q.z2
+	q.z2 = q.b2*x - q.a2*y
+	return y
+}
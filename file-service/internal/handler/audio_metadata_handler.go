@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"file-service/internal/domain"
+	"file-service/internal/service"
+	"file-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AudioMetadataHandler struct {
+	service service.AudioMetadataService
+	logger  *logger.Logger
+}
+
+func NewAudioMetadataHandler(service service.AudioMetadataService, logger *logger.Logger) *AudioMetadataHandler {
+	return &AudioMetadataHandler{
+		service: service,
+		logger:  logger.WithComponent("audio_metadata_handler"),
+	}
+}
+
+// GetMetadata returns the metadata extracted from an audio file on upload completion
+func (h *AudioMetadataHandler) GetMetadata(c *gin.Context) {
+	fileID := c.Param("file_id")
+
+	metadata, err := h.service.Get(c.Request.Context(), fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: "Audio metadata not found",
+			})
+			return
+		}
+		h.logger.Error().Err(err).Str("file_id", fileID).Msg("Failed to get audio metadata")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to get audio metadata",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, metadata)
+}
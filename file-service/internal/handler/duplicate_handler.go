@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"file-service/internal/domain"
+	"file-service/internal/service"
+	"file-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DuplicateHandler struct {
+	service service.DuplicateService
+	logger  *logger.Logger
+}
+
+func NewDuplicateHandler(service service.DuplicateService, logger *logger.Logger) *DuplicateHandler {
+	return &DuplicateHandler{
+		service: service,
+		logger:  logger.WithComponent("duplicate_handler"),
+	}
+}
+
+// GetDuplicates lists the audio files likely holding the same recording, for moderation
+func (h *DuplicateHandler) GetDuplicates(c *gin.Context) {
+	fileID := c.Param("file_id")
+
+	duplicates, err := h.service.Find(c.Request.Context(), fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: "Audio fingerprint not found",
+			})
+			return
+		}
+		h.logger.Error().Err(err).Str("file_id", fileID).Msg("Failed to find duplicates")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to find duplicates",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, domain.DuplicatesResponse{
+		FileID:     fileID,
+		Duplicates: duplicates,
+	})
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -37,6 +38,9 @@ func (h *FileHandler) GenerateUploadURL(c *gin.Context) {
 
 	response, err := h.service.GenerateUploadURL(ctx, req)
 	if err != nil {
+		if writeUploadLimitError(c, err, req) {
+			return
+		}
 		h.logger.Error().Err(err).Msg("Failed to generate upload URL")
 		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
 			Error: "Failed to generate upload URL",
@@ -47,21 +51,23 @@ func (h *FileHandler) GenerateUploadURL(c *gin.Context) {
 	c.PureJSON(http.StatusOK, response)
 }
 
+// GenerateDownloadURL redirects to a download URL reachable by clients
 func (h *FileHandler) GenerateDownloadURL(c *gin.Context) {
+	h.generateDownloadURL(c, false)
+}
+
+// GenerateInternalDownloadURL redirects to a download URL reachable from the
+// internal network. It is only routed on the internal listener.
+func (h *FileHandler) GenerateInternalDownloadURL(c *gin.Context) {
+	h.generateDownloadURL(c, true)
+}
+
+func (h *FileHandler) generateDownloadURL(c *gin.Context, isInternalRequest bool) {
 	ctx := c.Request.Context()
 
 	fileType := domain.FileType(c.Query("type"))
 	fileID := c.Query("file_id")
 
-	var isInternalRequest bool
-	isInternalParam := c.Query("is_internal")
-	isInternalParsed, err := strconv.ParseBool(isInternalParam)
-	if err != nil {
-		isInternalRequest = false
-	} else {
-		isInternalRequest = isInternalParsed
-	}
-
 	if fileType == "" || fileID == "" {
 		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
 			Error: "Missing required parameters: type, file_id",
@@ -78,9 +84,49 @@ func (h *FileHandler) GenerateDownloadURL(c *gin.Context) {
 		return
 	}
 
-	response, err := h.service.GenerateDownloadURL(ctx, fileType, fileID, isInternalRequest)
+	// Optional edge length in pixels, images resolve it to the nearest variant
+	var size int
+	if sizeParam := c.Query("size"); sizeParam != "" {
+		parsed, err := strconv.Atoi(sizeParam)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error: "Invalid size",
+			})
+			return
+		}
+		size = parsed
+	}
+
+	// Optional derived variant, currently the preview clip of audio files
+	variant := c.Query("variant")
+	if variant != "" && variant != domain.VariantPreview {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Invalid variant",
+		})
+		return
+	}
+
+	response, err := h.service.GenerateDownloadURL(ctx, fileType, fileID, size, variant, isInternalRequest)
 
 	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: "File not found",
+			})
+			return
+		}
+		if errors.Is(err, domain.ErrPreviewNotFound) {
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: "Preview not found",
+			})
+			return
+		}
+		if errors.Is(err, domain.ErrFileNotReady) {
+			c.JSON(http.StatusConflict, domain.ErrorResponse{
+				Error: "File is not processed yet",
+			})
+			return
+		}
 		h.logger.Error().Err(err).
 			Str("file_id", fileID).
 			Str("file_type", string(fileType)).
@@ -93,3 +139,92 @@ func (h *FileHandler) GenerateDownloadURL(c *gin.Context) {
 
 	c.Redirect(http.StatusFound, response.URL)
 }
+
+func (h *FileHandler) CompleteUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	fileID := c.Param("file_id")
+
+	var req domain.CompleteUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Warn().Err(err).Msg("Invalid complete upload request")
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Invalid request payload",
+		})
+		return
+	}
+
+	info, err := h.service.CompleteUpload(ctx, req.FileType, fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrForbidden) {
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error: "Upload belongs to another user",
+			})
+			return
+		}
+		if writeProcessingError(c, err) {
+			return
+		}
+		h.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Str("file_type", string(req.FileType)).
+			Msg("Failed to complete upload")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to complete upload",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// writeProcessingError responds to errors of processing a completed upload and reports whether it did
+func writeProcessingError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, domain.ErrFileNotFound):
+		c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error: "File not found",
+		})
+	case errors.Is(err, domain.ErrInvalidFileContent):
+		c.JSON(http.StatusUnprocessableEntity, domain.ErrorResponse{
+			Error:   "File content does not match file type",
+			Details: "The file has been rejected",
+		})
+	case errors.Is(err, domain.ErrMalwareDetected):
+		c.JSON(http.StatusUnprocessableEntity, domain.ErrorResponse{
+			Error:   "File rejected",
+			Details: "Malware detected",
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// writeUploadLimitError responds to errors caused by upload limits and reports whether it did
+func writeUploadLimitError(c *gin.Context, err error, req domain.UploadRequest) bool {
+	var quotaErr *domain.QuotaExceededError
+
+	switch {
+	case errors.As(err, &quotaErr):
+		c.JSON(http.StatusForbidden, domain.QuotaErrorResponse{
+			Error:     "Storage quota exceeded",
+			FileType:  quotaErr.FileType,
+			Used:      quotaErr.Used,
+			Limit:     quotaErr.Limit,
+			Requested: quotaErr.Requested,
+		})
+	case errors.Is(err, domain.ErrFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, domain.ErrorResponse{
+			Error: "File too large",
+		})
+	case errors.Is(err, domain.ErrContentTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, domain.ErrorResponse{
+			Error:   "Content type is not allowed",
+			Details: req.ContentType,
+		})
+	default:
+		return false
+	}
+	return true
+}
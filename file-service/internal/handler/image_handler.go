@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"file-service/internal/domain"
+	"file-service/internal/service"
+	"file-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImageHandler struct {
+	service service.ImageTransformService
+	logger  *logger.Logger
+}
+
+func NewImageHandler(service service.ImageTransformService, logger *logger.Logger) *ImageHandler {
+	return &ImageHandler{
+		service: service,
+		logger:  logger.WithComponent("image_handler"),
+	}
+}
+
+// GetImage streams the image transformed by the w, h, fit and q query parameters
+func (h *ImageHandler) GetImage(c *gin.Context) {
+	fileID := c.Param("file_id")
+
+	var req domain.ImageTransformRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error:   "Invalid transformation parameters",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	image, err := h.service.Transform(c.Request.Context(), fileID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidTransform):
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error: "Width or height is required",
+			})
+		case errors.Is(err, domain.ErrTransformNotAllowed):
+			c.JSON(http.StatusForbidden, domain.ErrorResponse{
+				Error: "Transformation is not allowed",
+			})
+		case errors.Is(err, domain.ErrFileNotFound):
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: "File not found",
+			})
+		case errors.Is(err, domain.ErrFileNotReady):
+			c.JSON(http.StatusConflict, domain.ErrorResponse{
+				Error: "File is not processed yet",
+			})
+		case errors.Is(err, domain.ErrInvalidFileContent), errors.Is(err, domain.ErrFileTooLarge):
+			c.JSON(http.StatusUnprocessableEntity, domain.ErrorResponse{
+				Error:   "Image cannot be transformed",
+				Details: err.Error(),
+			})
+		default:
+			h.logger.Error().Err(err).Str("file_id", fileID).Msg("Failed to transform image")
+			c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+				Error: "Failed to transform image",
+			})
+		}
+		return
+	}
+
+	c.Header("ETag", image.ETag)
+	c.Header("Cache-Control", "public, max-age=86400")
+	if c.GetHeader("If-None-Match") == image.ETag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, image.ContentType, image.Data)
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"file-service/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *FileHandler) InitiateMultipartUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req domain.UploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Warn().Err(err).Msg("Invalid multipart upload request")
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Invalid request payload",
+		})
+		return
+	}
+
+	response, err := h.service.InitiateMultipartUpload(ctx, req)
+	if err != nil {
+		if writeUploadLimitError(c, err, req) || writeNotSupportedError(c, err) {
+			return
+		}
+		h.logger.Error().Err(err).Msg("Failed to initiate multipart upload")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to initiate multipart upload",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *FileHandler) GeneratePartURLs(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	uploadID := c.Param("upload_id")
+
+	var req domain.PartURLsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Warn().Err(err).Msg("Invalid part URLs request")
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Invalid request payload",
+		})
+		return
+	}
+
+	response, err := h.service.GeneratePartURLs(ctx, uploadID, req)
+	if err != nil {
+		if errors.Is(err, domain.ErrUploadNotFound) {
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: "Multipart upload not found",
+			})
+			return
+		}
+		if writeNotSupportedError(c, err) {
+			return
+		}
+		h.logger.Error().Err(err).
+			Str("upload_id", uploadID).
+			Msg("Failed to generate part URLs")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to generate part URLs",
+		})
+		return
+	}
+
+	c.PureJSON(http.StatusOK, response)
+}
+
+func (h *FileHandler) CompleteMultipartUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	uploadID := c.Param("upload_id")
+
+	var req domain.CompleteMultipartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Warn().Err(err).Msg("Invalid complete multipart upload request")
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Invalid request payload",
+		})
+		return
+	}
+
+	info, err := h.service.CompleteMultipartUpload(ctx, uploadID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUploadNotFound):
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: "Multipart upload not found",
+			})
+		case errors.Is(err, domain.ErrFileTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, domain.ErrorResponse{
+				Error:   "File too large",
+				Details: "The file has been deleted",
+			})
+		case errors.Is(err, domain.ErrNotSupported):
+			writeNotSupportedError(c, err)
+		default:
+			if writeProcessingError(c, err) || writeUploadLimitError(c, err, domain.UploadRequest{}) {
+				return
+			}
+			h.logger.Error().Err(err).
+				Str("upload_id", uploadID).
+				Msg("Failed to complete multipart upload")
+			c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+				Error: "Failed to complete multipart upload",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+func (h *FileHandler) AbortMultipartUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	uploadID := c.Param("upload_id")
+	fileType := domain.FileType(c.Query("type"))
+	fileID := c.Query("file_id")
+
+	if fileType == "" || fileID == "" {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Missing required parameters: type, file_id",
+		})
+		return
+	}
+
+	switch fileType {
+	case domain.FileTypeImage, domain.FileTypeAudio:
+	default:
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Invalid file type",
+		})
+		return
+	}
+
+	if err := h.service.AbortMultipartUpload(ctx, fileType, fileID, uploadID); err != nil {
+		if errors.Is(err, domain.ErrUploadNotFound) {
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: "Multipart upload not found",
+			})
+			return
+		}
+		if writeNotSupportedError(c, err) {
+			return
+		}
+		h.logger.Error().Err(err).
+			Str("upload_id", uploadID).
+			Msg("Failed to abort multipart upload")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to abort multipart upload",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// writeNotSupportedError responds to operations the storage backend lacks and reports whether it did
+func writeNotSupportedError(c *gin.Context, err error) bool {
+	if !errors.Is(err, domain.ErrNotSupported) {
+		return false
+	}
+	c.JSON(http.StatusNotImplemented, domain.ErrorResponse{
+		Error: "Multipart uploads are not supported by the storage backend",
+	})
+	return true
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"file-service/internal/domain"
+	"file-service/internal/service"
+	"file-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProcessingHandler struct {
+	pipeline service.UploadPipeline
+	logger   *logger.Logger
+}
+
+func NewProcessingHandler(pipeline service.UploadPipeline, logger *logger.Logger) *ProcessingHandler {
+	return &ProcessingHandler{
+		pipeline: pipeline,
+		logger:   logger.WithComponent("processing_handler"),
+	}
+}
+
+// GetStatus returns the processing status of an uploaded file
+func (h *ProcessingHandler) GetStatus(c *gin.Context) {
+	fileID := c.Param("file_id")
+
+	status, err := h.pipeline.Status(c.Request.Context(), fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: "Processing status not found",
+			})
+			return
+		}
+		h.logger.Error().Err(err).Str("file_id", fileID).Msg("Failed to get processing status")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to get processing status",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+
+	"file-service/internal/domain"
+	"file-service/internal/service"
+	"file-service/pkg/auth"
+	"file-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuotaHandler struct {
+	service service.QuotaService
+	logger  *logger.Logger
+}
+
+func NewQuotaHandler(service service.QuotaService, logger *logger.Logger) *QuotaHandler {
+	return &QuotaHandler{
+		service: service,
+		logger:  logger.WithComponent("quota_handler"),
+	}
+}
+
+// GetUsage reports the storage usage of the authenticated user
+func (h *QuotaHandler) GetUsage(c *gin.Context) {
+	userID := auth.UserIDFromContext(c.Request.Context())
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, domain.ErrorResponse{
+			Error: "Usage requires an authenticated user",
+		})
+		return
+	}
+
+	h.writeUsage(c, userID)
+}
+
+// GetUserUsage reports the storage usage of any user, it is only routed on the internal listener
+func (h *QuotaHandler) GetUserUsage(c *gin.Context) {
+	h.writeUsage(c, c.Param("user_id"))
+}
+
+func (h *QuotaHandler) writeUsage(c *gin.Context, userID string) {
+	usage, err := h.service.GetUsage(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("user_id", userID).Msg("Failed to get storage usage")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to get storage usage",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
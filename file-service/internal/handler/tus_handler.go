@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"file-service/internal/domain"
+	"file-service/internal/service"
+	"file-service/internal/storage/cache"
+	"file-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tusVersion          = "1.0.0"
+	tusExtensions       = "creation,termination,expiration"
+	tusChunkContentType = "application/offset+octet-stream"
+)
+
+// TusHandler serves the tus 1.0 resumable upload protocol
+type TusHandler struct {
+	service service.TusService
+	logger  *logger.Logger
+}
+
+func NewTusHandler(service service.TusService, logger *logger.Logger) *TusHandler {
+	return &TusHandler{
+		service: service,
+		logger:  logger.WithComponent("tus_handler"),
+	}
+}
+
+// TusResumable rejects requests made with an unsupported protocol version
+func (h *TusHandler) TusResumable(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+
+	if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return
+	}
+
+	c.Next()
+}
+
+func (h *TusHandler) Options(c *gin.Context) {
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(h.service.MaxSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TusHandler) CreateUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Invalid Upload-Length header",
+		})
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Invalid Upload-Metadata header",
+		})
+		return
+	}
+
+	req := domain.UploadRequest{
+		FileType:    domain.FileType(metadata["file_type"]),
+		ContentType: metadata["content_type"],
+		Size:        length,
+	}
+	// tus-js-client sends the MIME type as "filetype"
+	if req.ContentType == "" {
+		req.ContentType = metadata["filetype"]
+	}
+
+	switch req.FileType {
+	case domain.FileTypeImage, domain.FileTypeAudio:
+	default:
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Invalid file type",
+		})
+		return
+	}
+
+	upload, err := h.service.CreateUpload(ctx, req)
+	if err != nil {
+		if writeUploadLimitError(c, err, req) {
+			return
+		}
+		h.logger.Error().Err(err).Msg("Failed to create resumable upload")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to create resumable upload",
+		})
+		return
+	}
+
+	// Relative to the request URL, so it resolves correctly behind path-rewriting proxies
+	location := upload.FileID
+	if !strings.HasSuffix(c.Request.URL.Path, "/") {
+		location = path.Base(c.Request.URL.Path) + "/" + upload.FileID
+	}
+
+	c.Header("Location", location)
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+func (h *TusHandler) GetOffset(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	fileID := c.Param("file_id")
+
+	upload, err := h.service.GetUpload(ctx, fileID)
+	if err != nil {
+		h.writeError(c, err, fileID)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusOK)
+}
+
+func (h *TusHandler) WriteChunk(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	fileID := c.Param("file_id")
+
+	if c.ContentType() != tusChunkContentType {
+		c.JSON(http.StatusUnsupportedMediaType, domain.ErrorResponse{
+			Error: "Content-Type must be " + tusChunkContentType,
+		})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+			Error: "Invalid Upload-Offset header",
+		})
+		return
+	}
+
+	upload, err := h.service.WriteChunk(ctx, fileID, offset, c.Request.Body)
+	if err != nil {
+		h.writeError(c, err, fileID)
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TusHandler) TerminateUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	fileID := c.Param("file_id")
+
+	if err := h.service.TerminateUpload(ctx, fileID); err != nil {
+		h.writeError(c, err, fileID)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TusHandler) writeError(c *gin.Context, err error, fileID string) {
+	switch {
+	case errors.Is(err, domain.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, domain.ErrorResponse{
+			Error: "Upload not found",
+		})
+	case errors.Is(err, domain.ErrOffsetMismatch):
+		c.JSON(http.StatusConflict, domain.ErrorResponse{
+			Error: "Upload-Offset does not match the current offset",
+		})
+	case errors.Is(err, cache.ErrLocked):
+		c.JSON(http.StatusLocked, domain.ErrorResponse{
+			Error: "Upload is in use by another request",
+		})
+	default:
+		if writeProcessingError(c, err) {
+			return
+		}
+		h.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Msg("Resumable upload request failed")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to process resumable upload",
+		})
+	}
+}
+
+// parseUploadMetadata decodes the tus Upload-Metadata header:
+// comma-separated "key base64value" pairs, where the value is optional
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+
+	for pair := range strings.SplitSeq(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"file-service/internal/domain"
+	"file-service/internal/service"
+	"file-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WaveformHandler struct {
+	service service.WaveformService
+	logger  *logger.Logger
+}
+
+func NewWaveformHandler(service service.WaveformService, logger *logger.Logger) *WaveformHandler {
+	return &WaveformHandler{
+		service: service,
+		logger:  logger.WithComponent("waveform_handler"),
+	}
+}
+
+// GetWaveform serves the waveform peaks of an audio file. The optional
+// resolution selects the number of points.
+func (h *WaveformHandler) GetWaveform(c *gin.Context) {
+	fileID := c.Param("file_id")
+
+	var resolution int
+	if resolutionParam := c.Query("resolution"); resolutionParam != "" {
+		parsed, err := strconv.Atoi(resolutionParam)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, domain.ErrorResponse{
+				Error: "Invalid resolution",
+			})
+			return
+		}
+		resolution = parsed
+	}
+
+	reader, err := h.service.Open(c.Request.Context(), fileID, resolution)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			c.JSON(http.StatusNotFound, domain.ErrorResponse{
+				Error: "Waveform not found",
+			})
+			return
+		}
+		h.logger.Error().Err(err).Str("file_id", fileID).Msg("Failed to get waveform")
+		c.JSON(http.StatusInternalServerError, domain.ErrorResponse{
+			Error: "Failed to get waveform",
+		})
+		return
+	}
+	defer reader.Close()
+
+	// Waveforms never change for a file ID
+	c.DataFromReader(http.StatusOK, -1, "application/json", reader, map[string]string{
+		"Cache-Control": "public, max-age=86400",
+	})
+}
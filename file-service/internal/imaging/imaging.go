@@ -0,0 +1,233 @@
+// Package imaging decodes, resizes and encodes images with the standard
+// library only. Resampling uses a separable tent filter whose support grows
+// with the downscale factor, which averages all covered source pixels.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooManyPixels     = errors.New("image has too many pixels")
+)
+
+// Formats of encoded images
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// Decode decodes a JPEG, PNG or GIF image. The dimensions are checked
+// against maxPixels before decoding to reject decompression bombs.
+func Decode(data []byte, maxPixels int) (image.Image, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return nil, "", ErrUnsupportedFormat
+		}
+		return nil, "", fmt.Errorf("failed to decode image header: %w", err)
+	}
+
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxPixels/max(cfg.Height, 1) {
+		return nil, "", ErrTooManyPixels
+	}
+
+	var img image.Image
+	switch format {
+	case "jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	default:
+		return nil, "", ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return img, format, nil
+}
+
+// OutputFormat returns the format resized images of the source format are
+// encoded in. Formats with transparency are kept lossless.
+func OutputFormat(sourceFormat string) string {
+	if sourceFormat == "jpeg" {
+		return FormatJPEG
+	}
+	return FormatPNG
+}
+
+// ContentType returns the media type of an output format
+func ContentType(format string) string {
+	if format == FormatJPEG {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// Encode writes the image in the output format, quality only applies to JPEG
+func Encode(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case FormatPNG:
+		return png.Encode(w, img)
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+// Cover scales the image to fill width x height and crops the overflow, keeping the center
+func Cover(img image.Image, width, height int) *image.NRGBA {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	// Crop the source to the target aspect ratio first
+	cropW, cropH := srcW, srcH
+	if srcW*height > srcH*width {
+		cropW = max(srcH*width/height, 1)
+	} else {
+		cropH = max(srcW*height/width, 1)
+	}
+
+	x0 := bounds.Min.X + (srcW-cropW)/2
+	y0 := bounds.Min.Y + (srcH-cropH)/2
+	crop := image.Rect(x0, y0, x0+cropW, y0+cropH)
+
+	return Resize(subImage(img, crop), width, height)
+}
+
+// Contain scales the image to fit within width x height, keeping the aspect ratio
+func Contain(img image.Image, width, height int) *image.NRGBA {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	scale := math.Min(float64(width)/float64(srcW), float64(height)/float64(srcH))
+	dstW := max(int(math.Round(float64(srcW)*scale)), 1)
+	dstH := max(int(math.Round(float64(srcH)*scale)), 1)
+
+	return Resize(img, dstW, dstH)
+}
+
+// Resize scales the image to exactly width x height. Source rows are
+// converted one at a time, so memory use depends on the output size only.
+func Resize(img image.Image, width, height int) *image.NRGBA {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	// Invert the vertical weights to know which output rows each source row feeds
+	rowTaps := make([][]tap, srcH)
+	for dstY, taps := range filterWeights(height, srcH) {
+		for _, t := range taps {
+			rowTaps[t.index] = append(rowTaps[t.index], tap{index: dstY, weight: t.weight})
+		}
+	}
+	columnTaps := filterWeights(width, srcW)
+
+	// RGBA rows are premultiplied, so transparent pixels don't bleed their color
+	row := image.NewRGBA(image.Rect(0, 0, srcW, 1))
+	scaledRow := make([]float32, width*4)
+	sums := make([]float32, width*height*4)
+
+	for y := range srcH {
+		if len(rowTaps[y]) == 0 {
+			continue
+		}
+		draw.Draw(row, row.Bounds(), img, image.Pt(bounds.Min.X, bounds.Min.Y+y), draw.Src)
+
+		clear(scaledRow)
+		for x, taps := range columnTaps {
+			for _, t := range taps {
+				for c := range 4 {
+					scaledRow[x*4+c] += float32(row.Pix[t.index*4+c]) * t.weight
+				}
+			}
+		}
+
+		for _, t := range rowTaps[y] {
+			out := sums[t.index*width*4 : (t.index+1)*width*4]
+			for i, value := range scaledRow {
+				out[i] += value * t.weight
+			}
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(sums); i += 4 {
+		alpha := sums[i+3]
+		dst.Pix[i+3] = clamp(alpha)
+		if dst.Pix[i+3] == 0 {
+			continue
+		}
+		for c := range 3 {
+			dst.Pix[i+c] = clamp(sums[i+c] * 255 / alpha)
+		}
+	}
+
+	return dst
+}
+
+type tap struct {
+	index  int
+	weight float32
+}
+
+// filterWeights returns the source pixels and weights contributing to each destination pixel
+func filterWeights(dstSize, srcSize int) [][]tap {
+	scale := float64(srcSize) / float64(dstSize)
+	support := math.Max(scale, 1)
+
+	weights := make([][]tap, dstSize)
+	for i := range dstSize {
+		center := (float64(i)+0.5)*scale - 0.5
+		first := int(math.Floor(center - support))
+		last := int(math.Ceil(center + support))
+
+		var taps []tap
+		var total float64
+		for j := first; j <= last; j++ {
+			distance := math.Abs(float64(j)-center) / support
+			if distance >= 1 {
+				continue
+			}
+			weight := 1 - distance
+			taps = append(taps, tap{index: min(max(j, 0), srcSize-1), weight: float32(weight)})
+			total += weight
+		}
+
+		for k := range taps {
+			taps[k].weight /= float32(total)
+		}
+		weights[i] = taps
+	}
+
+	return weights
+}
+
+func subImage(img image.Image, rect image.Rectangle) image.Image {
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+func clamp(value float32) uint8 {
+	return uint8(min(max(math.Round(float64(value)), 0), 255))
+}
@@ -0,0 +1,126 @@
+package loudness
+
+import (
+	"math"
+)
+
+// biquad is a second order IIR filter in transposed direct form II
+type biquad struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	z1, z2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting is the BS.1770 frequency weighting: a high shelf modelling the
+// head followed by a high pass
+type kWeighting struct {
+	shelf, highPass biquad
+}
+
+// newKWeighting derives the filter coefficients for the sample rate, they
+// match the ones tabulated in BS.1770 at 48 kHz
+func newKWeighting(sampleRate float64) kWeighting {
+	const (
+		shelfFrequency = 1681.974450955533
+		shelfGain      = 3.999843853973347
+		shelfQ         = 0.7071752369554196
+
+		highPassFrequency = 38.13547087602444
+		highPassQ         = 0.5003270373238773
+	)
+
+	k := math.Tan(math.Pi * shelfFrequency / sampleRate)
+	vh := math.Pow(10, shelfGain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/shelfQ + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/shelfQ + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/shelfQ + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/shelfQ + k*k) / a0,
+	}
+
+	k = math.Tan(math.Pi * highPassFrequency / sampleRate)
+	a0 = 1 + k/highPassQ + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/highPassQ + k*k) / a0,
+	}
+
+	return kWeighting{shelf: shelf, highPass: highPass}
+}
+
+func (k *kWeighting) process(x float64) float64 {
+	return k.highPass.process(k.shelf.process(x))
+}
+
+// tapsPerPhase is the length of each polyphase filter, BS.1770 uses 48 taps at 4x
+const tapsPerPhase = 12
+
+// oversampler interpolates samples to find peaks between them. Rates below
+// 96 kHz are oversampled 4x and rates below 192 kHz 2x, as BS.1770 suggests.
+type oversampler struct {
+	phases  [][]float64 // coefficients of each interpolated position
+	history [tapsPerPhase]float64
+	pos     int
+}
+
+func newOversampler(sampleRate int) *oversampler {
+	factor := 4
+	switch {
+	case sampleRate >= 192000:
+		// Sample peaks are close enough at high rates
+		phases := [][]float64{make([]float64, tapsPerPhase)}
+		phases[0][0] = 1
+		return &oversampler{phases: phases}
+	case sampleRate >= 96000:
+		factor = 2
+	}
+
+	// Windowed sinc low pass at the original Nyquist frequency
+	taps := tapsPerPhase * factor
+	center := float64(taps-1) / 2
+	phases := make([][]float64, factor)
+	for p := range phases {
+		phases[p] = make([]float64, tapsPerPhase)
+	}
+	for i := range taps {
+		t := (float64(i) - center) / float64(factor)
+		sinc := 1.0
+		if t != 0 {
+			sinc = math.Sin(math.Pi*t) / (math.Pi * t)
+		}
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*(float64(i)+0.5)/float64(taps))
+		phases[i%factor][i/factor] = sinc * window
+	}
+
+	return &oversampler{phases: phases}
+}
+
+// peak adds a sample and returns the largest magnitude of the interpolated
+// samples preceding it
+func (o *oversampler) peak(x float64) float64 {
+	o.history[o.pos] = x
+	o.pos = (o.pos + 1) % tapsPerPhase
+
+	var peak float64
+	for _, coefficients := range o.phases {
+		var y float64
+		for j, c := range coefficients {
+			y += c * o.history[(o.pos+tapsPerPhase-1-j)%tapsPerPhase]
+		}
+		peak = max(peak, math.Abs(y))
+	}
+	return peak
+}
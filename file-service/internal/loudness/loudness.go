@@ -0,0 +1,234 @@
+// Package loudness measures loudness as specified by ITU-R BS.1770 and EBU
+// R128: integrated loudness, loudness range (EBU Tech 3342) and true peak.
+// Block loudness is kept in fixed size histograms, so memory doesn't grow
+// with the length of the audio.
+package loudness
+
+import (
+	"math"
+)
+
+const (
+	// absoluteGate drops silent blocks from all measurements
+	absoluteGate = -70.0
+	// integratedGate is the relative gate of the integrated loudness in LU
+	integratedGate = -10.0
+	// rangeGate is the relative gate of the loudness range in LU
+	rangeGate = -20.0
+
+	// Blocks advance in 100ms steps, momentary blocks span 400ms and short-term blocks 3s
+	momentaryBlocks = 4
+	shortTermBlocks = 30
+
+	// Histogram bins of 0.1 LU cover -70 to +30 LUFS
+	histogramBins = 1000
+	binsPerLU     = 10
+)
+
+// Result holds the loudness of the audio
+type Result struct {
+	Integrated float64 // LUFS
+	Range      float64 // LU
+	TruePeak   float64 // dBTP
+}
+
+// Meter measures decoded audio, it implements audiodecode.Sink
+type Meter struct {
+	channels      int
+	weights       []float64
+	filters       []kWeighting
+	oversamplers  []*oversampler
+	subBlockSize  int
+	frames        int
+	sums          []float64 // squared filtered samples of the current 100ms block per channel
+	recent        [shortTermBlocks]float64
+	blocks        int
+	momentary     histogram
+	shortTerm     histogram
+	peak          float64
+	oversampleMax float64
+}
+
+func NewMeter() *Meter {
+	return &Meter{}
+}
+
+func (m *Meter) Format(sampleRate int, channels int) {
+	m.channels = channels
+	m.weights = channelWeights(channels)
+	m.filters = make([]kWeighting, channels)
+	m.oversamplers = make([]*oversampler, channels)
+	for ch := range channels {
+		m.filters[ch] = newKWeighting(float64(sampleRate))
+		m.oversamplers[ch] = newOversampler(sampleRate)
+	}
+	m.subBlockSize = max(sampleRate/10, 1)
+	m.sums = make([]float64, channels)
+}
+
+func (m *Meter) Write(samples []float32) {
+	for i := 0; i+m.channels <= len(samples); i += m.channels {
+		for ch := range m.channels {
+			sample := float64(samples[i+ch])
+
+			m.peak = max(m.peak, math.Abs(sample))
+			m.oversampleMax = max(m.oversampleMax, m.oversamplers[ch].peak(sample))
+
+			filtered := m.filters[ch].process(sample)
+			m.sums[ch] += filtered * filtered
+		}
+
+		m.frames++
+		if m.frames == m.subBlockSize {
+			m.endBlock()
+		}
+	}
+}
+
+// endBlock records the energy of the current 100ms block and measures the
+// momentary and short-term blocks ending with it
+func (m *Meter) endBlock() {
+	var energy float64
+	for ch, sum := range m.sums {
+		energy += m.weights[ch] * sum / float64(m.frames)
+		m.sums[ch] = 0
+	}
+	m.frames = 0
+
+	m.recent[m.blocks%shortTermBlocks] = energy
+	m.blocks++
+
+	if m.blocks >= momentaryBlocks {
+		m.momentary.add(m.meanEnergy(momentaryBlocks))
+	}
+	if m.blocks >= shortTermBlocks {
+		m.shortTerm.add(m.meanEnergy(shortTermBlocks))
+	}
+}
+
+// meanEnergy returns the mean energy of the last n 100ms blocks
+func (m *Meter) meanEnergy(n int) float64 {
+	var sum float64
+	for i := range n {
+		sum += m.recent[(m.blocks-1-i+shortTermBlocks)%shortTermBlocks]
+	}
+	return sum / float64(n)
+}
+
+// Result returns the loudness of the audio written so far. It reports false
+// when the audio is too short or too quiet to be measured.
+func (m *Meter) Result() (Result, bool) {
+	// Integrated loudness is the mean of the momentary blocks above the relative gate
+	gate, ok := m.momentary.relativeGate(integratedGate)
+	if !ok {
+		return Result{}, false
+	}
+	integrated, _ := m.momentary.meanAbove(gate)
+
+	return Result{
+		Integrated: integrated,
+		Range:      m.loudnessRange(),
+		TruePeak:   20 * math.Log10(max(m.peak, m.oversampleMax)),
+	}, true
+}
+
+// loudnessRange returns the spread of the gated short-term loudness between
+// its 10th and 95th percentile
+func (m *Meter) loudnessRange() float64 {
+	gate, ok := m.shortTerm.relativeGate(rangeGate)
+	if !ok {
+		return 0
+	}
+
+	low, high := m.shortTerm.percentile(gate, 0.10), m.shortTerm.percentile(gate, 0.95)
+	return max(high-low, 0)
+}
+
+// channelWeights returns the BS.1770 weights, surround channels of 5.0 and 5.1
+// layouts count more and the LFE channel isn't measured
+func channelWeights(channels int) []float64 {
+	weights := make([]float64, channels)
+	for ch := range weights {
+		weights[ch] = 1
+	}
+	switch channels {
+	case 5:
+		weights[3], weights[4] = 1.41, 1.41
+	case 6:
+		weights[3], weights[4], weights[5] = 0, 1.41, 1.41
+	}
+	return weights
+}
+
+func energyToLoudness(energy float64) float64 {
+	return -0.691 + 10*math.Log10(energy)
+}
+
+func loudnessToEnergy(loudness float64) float64 {
+	return math.Pow(10, (loudness+0.691)/10)
+}
+
+// histogram counts blocks above the absolute gate by their loudness
+type histogram struct {
+	counts   [histogramBins]int64
+	energies [histogramBins]float64
+}
+
+func (h *histogram) add(energy float64) {
+	loudness := energyToLoudness(energy)
+	if !(loudness >= absoluteGate) {
+		return
+	}
+	bin := min(int((loudness-absoluteGate)*binsPerLU), histogramBins-1)
+	h.counts[bin]++
+	h.energies[bin] += energy
+}
+
+// bin returns the index of the bin holding the loudness
+func (h *histogram) bin(loudness float64) int {
+	return max(0, min(int((loudness-absoluteGate)*binsPerLU), histogramBins-1))
+}
+
+// relativeGate returns the loudness offset from the mean of all blocks
+func (h *histogram) relativeGate(offset float64) (float64, bool) {
+	mean, ok := h.meanAbove(absoluteGate)
+	if !ok {
+		return 0, false
+	}
+	return mean + offset, true
+}
+
+// meanAbove returns the loudness of the mean energy of the blocks at or above the gate
+func (h *histogram) meanAbove(gate float64) (float64, bool) {
+	var count int64
+	var energy float64
+	for bin := h.bin(gate); bin < histogramBins; bin++ {
+		count += h.counts[bin]
+		energy += h.energies[bin]
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return energyToLoudness(energy / float64(count)), true
+}
+
+// percentile returns the loudness below which the fraction of the blocks at
+// or above the gate lies
+func (h *histogram) percentile(gate float64, fraction float64) float64 {
+	first := h.bin(gate)
+
+	var total int64
+	for bin := first; bin < histogramBins; bin++ {
+		total += h.counts[bin]
+	}
+
+	threshold := int64(math.Ceil(fraction * float64(total)))
+	var seen int64
+	for bin := first; bin < histogramBins; bin++ {
+		seen += h.counts[bin]
+		if seen >= max(threshold, 1) {
+			return absoluteGate + (float64(bin)+0.5)/binsPerLU
+		}
+	}
+	return absoluteGate + float64(histogramBins)/binsPerLU
+}
@@ -0,0 +1,267 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/pkg/logger"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// eventSource identifies file-service as the source of events
+const eventSource = "file-service"
+
+// errNotConnected is returned while the connection is being (re)established
+var errNotConnected = errors.New("not connected to RabbitMQ")
+
+// Publisher sends messages to the queues of other services
+type Publisher interface {
+	// Publish sends the message as JSON to the durable queue and returns once
+	// the broker confirmed it
+	Publish(ctx context.Context, queue string, message any) error
+	Close() error
+}
+
+// EventPublisher publishes file lifecycle events for other services to bind queues to
+type EventPublisher interface {
+	// PublishEvent wraps the data in a domain.EventEnvelope and returns once
+	// the broker confirmed the event
+	PublishEvent(ctx context.Context, eventType string, data any) error
+	Close() error
+}
+
+// RabbitMQPublisher publishes messages to queues through the default exchange
+// and events to a topic exchange, routed by their type, on one channel in
+// confirm mode. The connection is established in the background and
+// re-established whenever it is lost. Meanwhile publishing fails right away,
+// so requests publishing messages don't wait for the broker.
+type RabbitMQPublisher struct {
+	cfg    *config.RabbitMQConfig
+	logger *logger.Logger
+
+	mu       sync.Mutex
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+	declared map[string]bool // queues declared on the connection
+	closed   bool
+	done     chan struct{}
+}
+
+// NewRabbitMQPublisher starts connecting in the background
+func NewRabbitMQPublisher(cfg *config.RabbitMQConfig, l *logger.Logger) *RabbitMQPublisher {
+	p := &RabbitMQPublisher{
+		cfg:    cfg,
+		logger: l.WithComponent("rabbitmq_publisher"),
+		done:   make(chan struct{}),
+	}
+	go p.connect()
+	return p
+}
+
+func (p *RabbitMQPublisher) Publish(ctx context.Context, queue string, message any) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	return p.publish(ctx, "", queue, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Body:         body,
+	})
+}
+
+func (p *RabbitMQPublisher) PublishEvent(ctx context.Context, eventType string, data any) error {
+	envelope := domain.EventEnvelope{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		Version:    domain.EventSchemaVersion,
+		Source:     eventSource,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	return p.publish(ctx, p.cfg.EventsExchange, eventType, amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		MessageId:    envelope.ID,
+		Type:         eventType,
+		AppId:        eventSource,
+		Timestamp:    envelope.OccurredAt,
+		Body:         body,
+	})
+}
+
+// publish sends the message and awaits its confirmation. Messages to the
+// default exchange are routed to the queue named by the routing key, which
+// is declared first.
+func (p *RabbitMQPublisher) publish(ctx context.Context, exchange string, routingKey string, msg amqp.Publishing) error {
+	confirmation, err := p.send(ctx, exchange, routingKey, msg)
+	if err != nil {
+		return err
+	}
+
+	// Confirms are awaited without the lock, so messages are published concurrently
+	confirmCtx, cancel := context.WithTimeout(ctx, p.cfg.ConfirmTimeout)
+	defer cancel()
+
+	acked, err := confirmation.WaitContext(confirmCtx)
+	if err != nil {
+		return fmt.Errorf("failed to await confirmation of %s: %w", routingKey, err)
+	}
+	if !acked {
+		return fmt.Errorf("%s was rejected by the broker", routingKey)
+	}
+
+	return nil
+}
+
+func (p *RabbitMQPublisher) send(
+	ctx context.Context,
+	exchange string,
+	routingKey string,
+	msg amqp.Publishing,
+) (*amqp.DeferredConfirmation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ch == nil {
+		return nil, fmt.Errorf("failed to publish %s: %w", routingKey, errNotConnected)
+	}
+
+	if exchange == "" && !p.declared[routingKey] {
+		if _, err := p.ch.QueueDeclare(routingKey, true, false, false, false, nil); err != nil {
+			return nil, fmt.Errorf("failed to declare queue %s: %w", routingKey, err)
+		}
+		p.declared[routingKey] = true
+	}
+
+	confirmation, err := p.ch.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, false, false, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish %s: %w", routingKey, err)
+	}
+
+	return confirmation, nil
+}
+
+// connect dials until a connection is established or the publisher is closed
+func (p *RabbitMQPublisher) connect() {
+	backoff := p.cfg.Reconnect.InitialBackoff
+
+	for {
+		conn, ch, err := p.dial()
+		if err == nil {
+			p.mu.Lock()
+			if p.closed {
+				p.mu.Unlock()
+				conn.Close()
+				return
+			}
+			p.conn, p.ch, p.declared = conn, ch, make(map[string]bool)
+			p.mu.Unlock()
+
+			p.logger.Info().Str("exchange", p.cfg.EventsExchange).Msg("Connected to RabbitMQ")
+			go p.watch(conn, ch)
+			return
+		}
+
+		p.logger.Warn().Err(err).
+			Dur("backoff", backoff).
+			Msg("Failed to connect to RabbitMQ, retrying")
+
+		select {
+		case <-p.done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, max(p.cfg.Reconnect.MaxBackoff, p.cfg.Reconnect.InitialBackoff))
+	}
+}
+
+// dial opens a channel in confirm mode on which the events exchange is declared
+func (p *RabbitMQPublisher) dial() (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.DialConfig(p.cfg.URL, amqp.Config{
+		Heartbeat: 10 * time.Second,
+		Locale:    "en_US",
+		Dial:      amqp.DefaultDial(p.cfg.DialTimeout),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	if err := ch.ExchangeDeclare(p.cfg.EventsExchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to declare exchange %s: %w", p.cfg.EventsExchange, err)
+	}
+
+	return conn, ch, nil
+}
+
+// watch reconnects once the connection or its channel is closed. The broker
+// closes the channel on errors like a failed queue declaration.
+func (p *RabbitMQPublisher) watch(conn *amqp.Connection, ch *amqp.Channel) {
+	var closeErr *amqp.Error
+	select {
+	case <-p.done:
+		return
+	case closeErr = <-conn.NotifyClose(make(chan *amqp.Error, 1)):
+	case closeErr = <-ch.NotifyClose(make(chan *amqp.Error, 1)):
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	_ = conn.Close()
+	p.conn, p.ch = nil, nil
+	p.mu.Unlock()
+
+	event := p.logger.Warn()
+	if closeErr != nil {
+		event = event.Err(closeErr)
+	}
+	event.Msg("Disconnected from RabbitMQ, reconnecting")
+	p.connect()
+}
+
+func (p *RabbitMQPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+	p.conn, p.ch = nil, nil
+	return nil
+}
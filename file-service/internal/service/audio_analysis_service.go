@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"file-service/internal/audiodecode"
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/fingerprint"
+	"file-service/internal/loudness"
+	"file-service/internal/storage"
+	"file-service/internal/waveform"
+	"file-service/pkg/logger"
+)
+
+const (
+	// analysisTimeout bounds the analysis of one file
+	analysisTimeout = 10 * time.Minute
+	// replayGainReference is the ReplayGain 2.0 target loudness in LUFS
+	replayGainReference = -18
+)
+
+// AudioAnalysisService decodes uploaded audio files once to generate their
+// waveforms, measure their loudness and fingerprint them for duplicates. It
+// also has their preview clips cut. Decoding a file takes seconds, so
+// the analysis is queued and runs in the background.
+type AudioAnalysisService interface {
+	// Enqueue schedules the analysis of the file without blocking
+	Enqueue(fileID string)
+	// Run analyzes enqueued files until the context is done
+	Run(ctx context.Context) error
+}
+
+type audioAnalysisService struct {
+	storage    storage.Backend
+	waveforms  WaveformService
+	audio      AudioMetadataService
+	duplicates DuplicateService
+	previews   PreviewService
+	cfg        *config.AudioAnalysisConfig
+	queue      chan string
+	logger     *logger.Logger
+}
+
+func NewAudioAnalysisService(
+	backend storage.Backend,
+	waveforms WaveformService,
+	audio AudioMetadataService,
+	duplicates DuplicateService,
+	previews PreviewService,
+	cfg *config.Config,
+	logger *logger.Logger,
+) AudioAnalysisService {
+	return &audioAnalysisService{
+		storage:    backend,
+		waveforms:  waveforms,
+		audio:      audio,
+		duplicates: duplicates,
+		previews:   previews,
+		cfg:        &cfg.Analysis,
+		queue:      make(chan string, cfg.Analysis.QueueSize),
+		logger:     logger.WithComponent("audio_analysis_service"),
+	}
+}
+
+func (s *audioAnalysisService) Enqueue(fileID string) {
+	select {
+	case s.queue <- fileID:
+	default:
+		// Completing the upload again schedules the file anew
+		s.logger.Warn().Str("file_id", fileID).Msg("Audio analysis queue is full, skipping file")
+	}
+}
+
+func (s *audioAnalysisService) Run(ctx context.Context) error {
+	s.logger.Info().
+		Int("concurrency", s.cfg.Concurrency).
+		Msg("Audio analyzer started")
+
+	var wg sync.WaitGroup
+	for range s.cfg.Concurrency {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case fileID := <-s.queue:
+					s.process(ctx, fileID)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	s.logger.Info().Msg("Audio analyzer shutting down")
+	return nil
+}
+
+// process decodes the file and stores its waveforms and loudness
+func (s *audioAnalysisService) process(ctx context.Context, fileID string) {
+	ctx, cancel := context.WithTimeout(ctx, analysisTimeout)
+	defer cancel()
+
+	info, err := s.storage.Stat(ctx, domain.FileTypeAudio, fileID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrFileNotFound) {
+			s.logger.Error().Err(err).Str("file_id", fileID).Msg("Failed to stat audio file")
+		}
+		return
+	}
+
+	builder := s.waveforms.NewBuilder()
+	meter := loudness.NewMeter()
+	fingerprinter := fingerprint.New()
+	if err := s.decode(ctx, fileID, builder, meter, fingerprinter); err != nil {
+		s.logger.Warn().Err(err).
+			Str("file_id", fileID).
+			Msg("Failed to analyze audio file")
+		return
+	}
+
+	if !s.storeWaveforms(ctx, info, builder) {
+		return
+	}
+
+	if err := s.previews.Generate(ctx, fileID); err != nil {
+		s.logger.Warn().Err(err).
+			Str("file_id", fileID).
+			Msg("Failed to generate preview clip")
+	}
+
+	if err := s.duplicates.Index(ctx, fileID, fingerprinter.Fingerprint()); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Msg("Failed to index audio fingerprint")
+	}
+
+	result, ok := meter.Result()
+	if !ok {
+		s.logger.Info().Str("file_id", fileID).Msg("Audio file is too short or silent to measure loudness")
+		return
+	}
+
+	if err := s.audio.SaveLoudness(ctx, info, loudnessOf(result)); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Msg("Failed to save audio loudness")
+	}
+}
+
+func (s *audioAnalysisService) decode(ctx context.Context, fileID string, sinks ...audiodecode.Sink) error {
+	reader, err := s.storage.Open(ctx, domain.FileTypeAudio, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer reader.Close()
+
+	return audiodecode.Decode(reader, sinks...)
+}
+
+// storeWaveforms stores and records the waveforms, it returns false if the
+// file was deleted meanwhile
+func (s *audioAnalysisService) storeWaveforms(ctx context.Context, info *domain.FileInfo, builder *waveform.Builder) bool {
+	resolutions, err := s.waveforms.Store(ctx, info.FileID, builder)
+	if err != nil {
+		s.logger.Warn().Err(err).
+			Str("file_id", info.FileID).
+			Msg("Failed to store waveforms")
+	}
+	if len(resolutions) == 0 {
+		return true
+	}
+
+	metadata := map[string]string{domain.MetadataWaveforms: formatSizes(resolutions)}
+	if err := s.storage.UpdateMetadata(ctx, domain.FileTypeAudio, info.FileID, "", metadata); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", info.FileID).
+			Msg("Failed to record waveforms")
+
+		// The file was deleted while it was analyzed
+		if errors.Is(err, domain.ErrFileNotFound) {
+			_ = s.waveforms.Delete(ctx, info) // Logged by the waveform service
+			return false
+		}
+		return true
+	}
+
+	s.logger.Info().
+		Str("file_id", info.FileID).
+		Ints("resolutions", resolutions).
+		Msg("Waveforms generated")
+	return true
+}
+
+// loudnessOf rounds the measurement to hundredths, well below audible differences
+func loudnessOf(result loudness.Result) *domain.Loudness {
+	round := func(value float64) float64 {
+		return math.Round(value*100) / 100
+	}
+
+	return &domain.Loudness{
+		IntegratedLUFS: round(result.Integrated),
+		RangeLU:        round(result.Range),
+		TruePeakDBTP:   round(result.TruePeak),
+		TrackGainDB:    round(replayGainReference - result.Integrated),
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"file-service/internal/audiometa"
+	"file-service/internal/domain"
+	"file-service/internal/publisher"
+	"file-service/internal/storage"
+	"file-service/pkg/logger"
+)
+
+// AudioMetadataService extracts tags and stream properties from uploaded
+// audio files. The results are stored next to the file and published to
+// the backend.
+type AudioMetadataService interface {
+	// Extract parses the audio file, it also returns the embedded cover art if there is any
+	Extract(ctx context.Context, info *domain.FileInfo) (*domain.AudioMetadata, *audiometa.Picture, error)
+	// Save stores the metadata and publishes it
+	Save(ctx context.Context, info *domain.FileInfo, metadata *domain.AudioMetadata) error
+	// SaveLoudness merges the measured loudness into the stored metadata and publishes it
+	SaveLoudness(ctx context.Context, info *domain.FileInfo, loudness *domain.Loudness) error
+	// Get returns the stored metadata, or domain.ErrFileNotFound if there is none
+	Get(ctx context.Context, fileID string) (*domain.AudioMetadata, error)
+	// Delete removes the stored metadata
+	Delete(ctx context.Context, fileID string) error
+}
+
+type audioMetadataService struct {
+	storage   storage.Backend
+	publisher publisher.Publisher
+	logger    *logger.Logger
+}
+
+func NewAudioMetadataService(
+	backend storage.Backend,
+	publisher publisher.Publisher,
+	logger *logger.Logger,
+) AudioMetadataService {
+	return &audioMetadataService{
+		storage:   backend,
+		publisher: publisher,
+		logger:    logger.WithComponent("audio_metadata_service"),
+	}
+}
+
+func (s *audioMetadataService) Extract(
+	ctx context.Context,
+	info *domain.FileInfo,
+) (*domain.AudioMetadata, *audiometa.Picture, error) {
+	object, err := s.storage.Open(ctx, domain.FileTypeAudio, info.FileID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer object.Close()
+
+	readerAt, ok := object.(io.ReaderAt)
+	if !ok {
+		return nil, nil, domain.ErrNotSupported
+	}
+
+	parsed, err := audiometa.Parse(readerAt, info.Size)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse audio file: %w", err)
+	}
+
+	metadata := &domain.AudioMetadata{
+		FileID:          info.FileID,
+		Format:          parsed.Format,
+		Title:           parsed.Tags.Title,
+		Artist:          parsed.Tags.Artist,
+		Album:           parsed.Tags.Album,
+		Genre:           parsed.Tags.Genre,
+		Year:            parsed.Tags.Year,
+		Track:           parsed.Tags.Track,
+		DurationSeconds: parsed.Duration.Seconds(),
+		Bitrate:         parsed.Bitrate,
+		SampleRate:      parsed.SampleRate,
+		Channels:        parsed.Channels,
+	}
+
+	return metadata, parsed.Picture, nil
+}
+
+func (s *audioMetadataService) Save(ctx context.Context, info *domain.FileInfo, metadata *domain.AudioMetadata) error {
+	if err := s.put(ctx, metadata); err != nil {
+		return err
+	}
+
+	// The stored metadata stays available through the API if publishing fails
+	message := domain.AudioMetadataMessage{
+		AudioMetadata: *metadata,
+		OwnerID:       info.Metadata[domain.MetadataOwner],
+	}
+	if err := s.publisher.Publish(ctx, domain.AudioMetadataQueue, message); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", info.FileID).
+			Msg("Failed to publish audio metadata")
+	}
+
+	s.logger.Info().
+		Str("file_id", info.FileID).
+		Str("format", metadata.Format).
+		Float64("duration_seconds", metadata.DurationSeconds).
+		Str("cover_image_id", metadata.CoverImageID).
+		Msg("Audio metadata extracted")
+
+	return nil
+}
+
+func (s *audioMetadataService) SaveLoudness(ctx context.Context, info *domain.FileInfo, loudness *domain.Loudness) error {
+	// The analysis runs after extraction, a missing sidecar means extraction failed
+	metadata, err := s.Get(ctx, info.FileID)
+	if errors.Is(err, domain.ErrFileNotFound) {
+		metadata, err = &domain.AudioMetadata{FileID: info.FileID}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	metadata.Loudness = loudness
+	if err := s.put(ctx, metadata); err != nil {
+		return err
+	}
+
+	message := domain.AudioLoudnessMessage{
+		FileID:   info.FileID,
+		OwnerID:  info.Metadata[domain.MetadataOwner],
+		Loudness: *loudness,
+	}
+	if err := s.publisher.Publish(ctx, domain.AudioLoudnessQueue, message); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", info.FileID).
+			Msg("Failed to publish audio loudness")
+	}
+
+	s.logger.Info().
+		Str("file_id", info.FileID).
+		Float64("integrated_lufs", loudness.IntegratedLUFS).
+		Float64("true_peak_dbtp", loudness.TruePeakDBTP).
+		Msg("Audio loudness measured")
+
+	return nil
+}
+
+func (s *audioMetadataService) Get(ctx context.Context, fileID string) (*domain.AudioMetadata, error) {
+	object, err := s.storage.Open(ctx, domain.FileTypeAudio, domain.AudioMetadataKey(fileID))
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to open audio metadata: %w", err)
+	}
+	defer object.Close()
+
+	var metadata domain.AudioMetadata
+	if err := json.NewDecoder(object).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to read audio metadata: %w", err)
+	}
+
+	return &metadata, nil
+}
+
+func (s *audioMetadataService) Delete(ctx context.Context, fileID string) error {
+	if err := s.storage.Delete(ctx, domain.FileTypeAudio, domain.AudioMetadataKey(fileID)); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Msg("Failed to delete audio metadata")
+		return fmt.Errorf("failed to delete audio metadata: %w", err)
+	}
+	return nil
+}
+
+func (s *audioMetadataService) put(ctx context.Context, metadata *domain.AudioMetadata) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audio metadata: %w", err)
+	}
+
+	key := domain.AudioMetadataKey(metadata.FileID)
+	if err := s.storage.Put(ctx, domain.FileTypeAudio, key, bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		return fmt.Errorf("failed to store audio metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+
+	"file-service/internal/domain"
+	"file-service/internal/sniff"
+	"file-service/pkg/logger"
+)
+
+// ContentValidator checks the content type sniffed from the leading bytes of
+// new objects against the formats allowed for their file type. Rejected
+// objects are moved to quarantine.
+type ContentValidator interface {
+	// Validate returns domain.ErrInvalidFileContent if the content type isn't
+	// allowed for the file, which is quarantined then
+	Validate(ctx context.Context, info *domain.FileInfo, contentType string) error
+}
+
+type contentValidator struct {
+	quarantine QuarantineService
+	logger     *logger.Logger
+}
+
+func NewContentValidator(quarantine QuarantineService, logger *logger.Logger) ContentValidator {
+	return &contentValidator{
+		quarantine: quarantine,
+		logger:     logger.WithComponent("content_validator"),
+	}
+}
+
+func (v *contentValidator) Validate(ctx context.Context, info *domain.FileInfo, contentType string) error {
+	if sniff.MatchesFileType(info.FileType, contentType) {
+		return nil
+	}
+
+	v.logger.Warn().
+		Str("file_id", info.FileID).
+		Str("file_type", string(info.FileType)).
+		Str("detected_content_type", contentType).
+		Msg("Uploaded file content is not allowed for file type, quarantining")
+
+	v.quarantine.Reject(ctx, info, domain.Rejection{
+		Reason:              domain.RejectionContentMismatch,
+		DetectedContentType: contentType,
+	})
+
+	return domain.ErrInvalidFileContent
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/fingerprint"
+	"file-service/internal/storage"
+	"file-service/internal/storage/cache"
+	"file-service/pkg/logger"
+)
+
+// minSharedHashes is the number of fingerprint hashes a file must share to be
+// compared, unrelated files share a few by chance
+const minSharedHashes = 5
+
+// DuplicateService finds copies of a recording among the uploaded audio files
+// by their acoustic fingerprints, which the audio analysis computes
+type DuplicateService interface {
+	// Index stores the fingerprint of the file for lookups
+	Index(ctx context.Context, fileID string, fingerprint []uint32) error
+	// Find returns the files likely holding the same recording, most similar
+	// first. It returns domain.ErrFileNotFound if the file has no fingerprint.
+	Find(ctx context.Context, fileID string) ([]domain.Duplicate, error)
+	// Delete removes the fingerprint of the file
+	Delete(ctx context.Context, fileID string) error
+}
+
+type duplicateService struct {
+	storage      storage.Backend
+	fingerprints cache.FingerprintStore
+	cfg          *config.DuplicateConfig
+	logger       *logger.Logger
+}
+
+func NewDuplicateService(
+	backend storage.Backend,
+	fingerprints cache.FingerprintStore,
+	cfg *config.Config,
+	logger *logger.Logger,
+) DuplicateService {
+	return &duplicateService{
+		storage:      backend,
+		fingerprints: fingerprints,
+		cfg:          &cfg.Duplicate,
+		logger:       logger.WithComponent("duplicate_service"),
+	}
+}
+
+func (s *duplicateService) Index(ctx context.Context, fileID string, values []uint32) error {
+	if err := s.fingerprints.Add(ctx, fileID, values, fingerprint.Hashes(values)); err != nil {
+		return fmt.Errorf("failed to store fingerprint: %w", err)
+	}
+	return nil
+}
+
+func (s *duplicateService) Find(ctx context.Context, fileID string) ([]domain.Duplicate, error) {
+	values, err := s.fingerprints.Get(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fingerprint: %w", err)
+	}
+	if values == nil {
+		return nil, domain.ErrFileNotFound
+	}
+
+	// One more candidate than configured, as the file matches itself
+	candidates, err := s.fingerprints.Match(ctx, fingerprint.Hashes(values), s.cfg.MaxCandidates+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match fingerprint: %w", err)
+	}
+
+	duplicates := []domain.Duplicate{}
+	for candidateID, shared := range candidates {
+		if candidateID == fileID || shared < minSharedHashes {
+			continue
+		}
+
+		duplicate, err := s.compare(ctx, values, candidateID)
+		if err != nil {
+			return nil, err
+		}
+		if duplicate != nil {
+			duplicates = append(duplicates, *duplicate)
+		}
+	}
+
+	slices.SortFunc(duplicates, func(a, b domain.Duplicate) int {
+		return cmp.Compare(b.Similarity, a.Similarity)
+	})
+	return duplicates, nil
+}
+
+// compare returns the candidate if it is similar enough and still exists
+func (s *duplicateService) compare(ctx context.Context, values []uint32, candidateID string) (*domain.Duplicate, error) {
+	candidate, err := s.fingerprints.Get(ctx, candidateID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fingerprint: %w", err)
+	}
+
+	similarity := fingerprint.Similarity(values, candidate)
+	if similarity < s.cfg.MinSimilarity {
+		return nil, nil
+	}
+
+	info, err := s.storage.Stat(ctx, domain.FileTypeAudio, candidateID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to stat audio file: %w", err)
+	}
+
+	return &domain.Duplicate{
+		FileID:     candidateID,
+		OwnerID:    info.Metadata[domain.MetadataOwner],
+		Similarity: math.Round(similarity*100) / 100,
+	}, nil
+}
+
+func (s *duplicateService) Delete(ctx context.Context, fileID string) error {
+	values, err := s.fingerprints.Get(ctx, fileID)
+	if err == nil && values != nil {
+		err = s.fingerprints.Remove(ctx, fileID, fingerprint.Hashes(values))
+	}
+	if err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Msg("Failed to delete fingerprint")
+		return fmt.Errorf("failed to delete fingerprint: %w", err)
+	}
+	return nil
+}
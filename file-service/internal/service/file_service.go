@@ -2,39 +2,117 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"file-service/internal/config"
 	"file-service/internal/domain"
-	"file-service/internal/storage/minio"
+	"file-service/internal/storage"
+	"file-service/internal/storage/cache"
+	"file-service/pkg/auth"
 	"file-service/pkg/logger"
 
 	"github.com/google/uuid"
 )
 
 type FileService interface {
-	GenerateUploadURL(ctx context.Context, req domain.UploadRequest) (*domain.PresignedURLResponse, error)
-	GenerateDownloadURL(ctx context.Context, fileType domain.FileType, fileID string, isInternalRequest bool) (*domain.PresignedURLResponse, error)
+	GenerateUploadURL(ctx context.Context, req domain.UploadRequest) (*domain.PresignedPostResponse, error)
+	// GenerateDownloadURL signs a download of the file. For images a positive
+	// size selects the nearest generated variant instead of the original, for
+	// audio the preview variant selects the preview clip. It returns
+	// domain.ErrFileNotFound if the file doesn't exist,
+	// domain.ErrFileNotReady until it was processed and
+	// domain.ErrPreviewNotFound if it has no preview clip.
+	GenerateDownloadURL(
+		ctx context.Context,
+		fileType domain.FileType,
+		fileID string,
+		size int,
+		variant string,
+		isInternalRequest bool,
+	) (*domain.PresignedURLResponse, error)
 	CheckFileExists(ctx context.Context, fileType domain.FileType, fileID string) (bool, error)
 	DeleteFile(ctx context.Context, fileType domain.FileType, fileID string) error
+	CompleteUpload(ctx context.Context, fileType domain.FileType, fileID string) (*domain.FileInfo, error)
+	InitiateMultipartUpload(ctx context.Context, req domain.UploadRequest) (*domain.MultipartUploadResponse, error)
+	// GeneratePartURLs, CompleteMultipartUpload and AbortMultipartUpload return
+	// domain.ErrUploadNotFound unless the caller initiated the upload
+	GeneratePartURLs(ctx context.Context, uploadID string, req domain.PartURLsRequest) (*domain.PartURLsResponse, error)
+	// CompleteMultipartUpload assembles the parts and processes the file like CompleteUpload
+	CompleteMultipartUpload(ctx context.Context, uploadID string, req domain.CompleteMultipartRequest) (*domain.FileInfo, error)
+	AbortMultipartUpload(ctx context.Context, fileType domain.FileType, fileID string, uploadID string) error
 }
 
 type fileService struct {
-	storage minio.MinioClient
-	logger  *logger.Logger
+	storage    storage.Backend
+	multipart  storage.MultipartBackend // nil if the backend doesn't support multipart uploads
+	uploads    cache.MultipartStore
+	uploadTTL  time.Duration
+	quota      QuotaService
+	variants   ImageVariantService
+	audio      AudioMetadataService
+	waveforms  WaveformService
+	pipeline   UploadPipeline
+	duplicates DuplicateService
+	previews   PreviewService
+	// scanRequired keeps files that weren't scanned clean from being downloaded
+	scanRequired bool
+	logger       *logger.Logger
 }
 
-func NewFileService(storage *minio.MinioClient, logger *logger.Logger) FileService {
+func NewFileService(
+	backend storage.Backend,
+	uploads cache.MultipartStore,
+	quota QuotaService,
+	variants ImageVariantService,
+	audio AudioMetadataService,
+	waveforms WaveformService,
+	pipeline UploadPipeline,
+	duplicates DuplicateService,
+	previews PreviewService,
+	cfg *config.Config,
+	logger *logger.Logger,
+) FileService {
+	multipart, _ := backend.(storage.MultipartBackend)
+
 	return &fileService{
-		storage: *storage,
-		logger:  logger.WithComponent("file_service"),
+		storage:      backend,
+		multipart:    multipart,
+		uploads:      uploads,
+		uploadTTL:    cfg.Minio.Multipart.StaleAfter,
+		quota:        quota,
+		variants:     variants,
+		audio:        audio,
+		waveforms:    waveforms,
+		pipeline:     pipeline,
+		duplicates:   duplicates,
+		previews:     previews,
+		scanRequired: cfg.ClamAV.Enabled(),
+		logger:       logger.WithComponent("file_service"),
 	}
 }
 
-func (s *fileService) GenerateUploadURL(ctx context.Context, req domain.UploadRequest) (*domain.PresignedURLResponse, error) {
+func (s *fileService) GenerateUploadURL(ctx context.Context, req domain.UploadRequest) (*domain.PresignedPostResponse, error) {
 	fileID := uuid.New().String()
+	req.OwnerID = auth.UserIDFromContext(ctx)
+
+	quotaLeft, err := s.quota.CheckUpload(ctx, req.OwnerID, req.FileType, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	req.QuotaLeft = quotaLeft
 
-	presignedURL, err := s.storage.GeneratePresignedPutURL(ctx, req, fileID)
+	presignedPost, err := s.storage.PresignUpload(ctx, req, fileID)
 	if err != nil {
+		if errors.Is(err, domain.ErrFileTooLarge) || errors.Is(err, domain.ErrContentTypeNotAllowed) {
+			s.logger.Warn().Err(err).
+				Str("file_type", string(req.FileType)).
+				Str("content_type", req.ContentType).
+				Int64("size", req.Size).
+				Msg("Upload request rejected by limits")
+			return nil, err
+		}
 		s.logger.Error().Err(err).
 			Str("file_type", string(req.FileType)).
 			Msg("Failed to generate upload URL")
@@ -46,18 +124,241 @@ func (s *fileService) GenerateUploadURL(ctx context.Context, req domain.UploadRe
 		Str("file_type", string(req.FileType)).
 		Msg("Upload URL generated successfully")
 
-	return presignedURL, nil
+	return presignedPost, nil
+}
+
+func (s *fileService) InitiateMultipartUpload(ctx context.Context, req domain.UploadRequest) (*domain.MultipartUploadResponse, error) {
+	if s.multipart == nil {
+		return nil, domain.ErrNotSupported
+	}
+
+	fileID := uuid.New().String()
+	req.OwnerID = auth.UserIDFromContext(ctx)
+
+	if _, err := s.quota.CheckUpload(ctx, req.OwnerID, req.FileType, req.Size); err != nil {
+		return nil, err
+	}
+
+	upload, err := s.multipart.InitiateMultipartUpload(ctx, req, fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileTooLarge) || errors.Is(err, domain.ErrContentTypeNotAllowed) {
+			s.logger.Warn().Err(err).
+				Str("file_type", string(req.FileType)).
+				Str("content_type", req.ContentType).
+				Int64("size", req.Size).
+				Msg("Multipart upload request rejected by limits")
+			return nil, err
+		}
+		s.logger.Error().Err(err).
+			Str("file_type", string(req.FileType)).
+			Msg("Failed to initiate multipart upload")
+		return nil, fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	// Uploads older than the stale age are aborted by the sweeper, so is their record
+	record := &domain.MultipartUpload{
+		UploadID: upload.UploadID,
+		FileID:   fileID,
+		FileType: req.FileType,
+		OwnerID:  req.OwnerID,
+	}
+	if err := s.uploads.Save(ctx, record, s.uploadTTL); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Str("upload_id", upload.UploadID).
+			Msg("Failed to save multipart upload")
+		if err := s.multipart.AbortMultipartUpload(ctx, req.FileType, fileID, upload.UploadID); err != nil {
+			s.logger.Warn().Err(err).Str("file_id", fileID).Msg("Failed to abort multipart upload")
+		}
+		return nil, fmt.Errorf("failed to save multipart upload: %w", err)
+	}
+
+	s.logger.Info().
+		Str("file_id", fileID).
+		Str("file_type", string(req.FileType)).
+		Str("upload_id", upload.UploadID).
+		Msg("Multipart upload initiated successfully")
+
+	return upload, nil
+}
+
+func (s *fileService) GeneratePartURLs(
+	ctx context.Context,
+	uploadID string,
+	req domain.PartURLsRequest,
+) (*domain.PartURLsResponse, error) {
+	if s.multipart == nil {
+		return nil, domain.ErrNotSupported
+	}
+
+	if err := s.checkMultipartUpload(ctx, uploadID, req.FileType, req.FileID); err != nil {
+		return nil, err
+	}
+
+	parts, err := s.multipart.PresignParts(ctx, req.FileType, req.FileID, uploadID, req.PartNumbers)
+	if err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", req.FileID).
+			Str("file_type", string(req.FileType)).
+			Str("upload_id", uploadID).
+			Msg("Failed to generate part URLs")
+		return nil, fmt.Errorf("failed to generate part URLs: %w", err)
+	}
+
+	return parts, nil
+}
+
+func (s *fileService) CompleteMultipartUpload(
+	ctx context.Context,
+	uploadID string,
+	req domain.CompleteMultipartRequest,
+) (*domain.FileInfo, error) {
+	if s.multipart == nil {
+		return nil, domain.ErrNotSupported
+	}
+
+	if err := s.checkMultipartUpload(ctx, uploadID, req.FileType, req.FileID); err != nil {
+		return nil, err
+	}
+
+	err := s.multipart.CompleteMultipartUpload(ctx, req.FileType, req.FileID, uploadID, req.Parts)
+	if err != nil {
+		if errors.Is(err, domain.ErrUploadNotFound) || errors.Is(err, domain.ErrFileTooLarge) {
+			s.logger.Warn().Err(err).
+				Str("file_id", req.FileID).
+				Str("file_type", string(req.FileType)).
+				Str("upload_id", uploadID).
+				Msg("Multipart upload completion rejected")
+			return nil, err
+		}
+		s.logger.Error().Err(err).
+			Str("file_id", req.FileID).
+			Str("file_type", string(req.FileType)).
+			Str("upload_id", uploadID).
+			Msg("Failed to complete multipart upload")
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	s.deleteMultipartUpload(ctx, uploadID)
+
+	// Without a declared size the quota was only checked for owners who used
+	// it up, the assembled upload is checked in full
+	if err := s.checkAssembledQuota(ctx, req.FileType, req.FileID); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().
+		Str("file_id", req.FileID).
+		Str("file_type", string(req.FileType)).
+		Str("upload_id", uploadID).
+		Int("parts", len(req.Parts)).
+		Msg("Multipart upload assembled")
+
+	return s.pipeline.Process(ctx, req.FileType, req.FileID)
+}
+
+func (s *fileService) AbortMultipartUpload(ctx context.Context, fileType domain.FileType, fileID string, uploadID string) error {
+	if s.multipart == nil {
+		return domain.ErrNotSupported
+	}
+
+	if err := s.checkMultipartUpload(ctx, uploadID, fileType, fileID); err != nil {
+		return err
+	}
+
+	if err := s.multipart.AbortMultipartUpload(ctx, fileType, fileID, uploadID); err != nil {
+		if errors.Is(err, domain.ErrUploadNotFound) {
+			return err
+		}
+		s.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Str("file_type", string(fileType)).
+			Str("upload_id", uploadID).
+			Msg("Failed to abort multipart upload")
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+
+	s.deleteMultipartUpload(ctx, uploadID)
+
+	s.logger.Info().
+		Str("file_id", fileID).
+		Str("file_type", string(fileType)).
+		Str("upload_id", uploadID).
+		Msg("Multipart upload aborted")
+
+	return nil
+}
+
+// checkMultipartUpload returns domain.ErrUploadNotFound unless the upload is
+// the file's and the caller initiated it. Uploads of others are reported as
+// missing, so their IDs can't be probed.
+func (s *fileService) checkMultipartUpload(ctx context.Context, uploadID string, fileType domain.FileType, fileID string) error {
+	upload, found, err := s.uploads.Get(ctx, uploadID)
+	if err != nil {
+		s.logger.Error().Err(err).
+			Str("upload_id", uploadID).
+			Msg("Failed to load multipart upload")
+		return fmt.Errorf("failed to load multipart upload: %w", err)
+	}
+
+	if !found || upload.FileID != fileID || upload.FileType != fileType {
+		return domain.ErrUploadNotFound
+	}
+	if upload.OwnerID != auth.UserIDFromContext(ctx) {
+		s.logger.Warn().
+			Str("upload_id", uploadID).
+			Str("file_id", fileID).
+			Msg("Multipart upload accessed by another user")
+		return domain.ErrUploadNotFound
+	}
+
+	return nil
+}
+
+// checkAssembledQuota deletes the assembled upload when it exceeds the
+// caller's quota and returns the QuotaExceededError
+func (s *fileService) checkAssembledQuota(ctx context.Context, fileType domain.FileType, fileID string) error {
+	info, err := s.storage.Stat(ctx, fileType, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to stat assembled upload: %w", err)
+	}
+
+	_, err = s.quota.CheckUpload(ctx, auth.UserIDFromContext(ctx), fileType, info.Size)
+	if err == nil {
+		return nil
+	}
+	if err := s.storage.Delete(ctx, fileType, fileID); err != nil {
+		s.logger.Warn().Err(err).Str("file_id", fileID).Msg("Failed to delete upload exceeding the quota")
+	}
+	return err
+}
+
+// deleteMultipartUpload is best effort, the record expires with the upload
+func (s *fileService) deleteMultipartUpload(ctx context.Context, uploadID string) {
+	if err := s.uploads.Delete(ctx, uploadID); err != nil {
+		s.logger.Warn().Err(err).
+			Str("upload_id", uploadID).
+			Msg("Failed to delete multipart upload")
+	}
 }
 
 func (s *fileService) GenerateDownloadURL(
 	ctx context.Context,
 	fileType domain.FileType,
 	fileID string,
+	size int,
+	variant string,
 	isInternalRequest bool,
 ) (*domain.PresignedURLResponse, error) {
-	exists, err := s.storage.CheckFileExists(ctx, fileType, fileID)
-
+	info, err := s.storage.Stat(ctx, fileType, fileID)
 	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			s.logger.Warn().
+				Str("file_id", fileID).
+				Str("file_type", string(fileType)).
+				Msg("File not found")
+			return nil, domain.ErrFileNotFound
+		}
 		s.logger.Error().Err(err).
 			Str("file_id", fileID).
 			Str("file_type", string(fileType)).
@@ -65,15 +366,28 @@ func (s *fileService) GenerateDownloadURL(
 		return nil, fmt.Errorf("failed to check file existence: %w", err)
 	}
 
-	if !exists {
+	// Uploads are stored where they are downloaded from, they are only
+	// offered once processing accepted them
+	if !info.Ready(s.scanRequired) {
 		s.logger.Warn().
 			Str("file_id", fileID).
 			Str("file_type", string(fileType)).
-			Msg("File not found")
-		return nil, fmt.Errorf("file not found")
+			Msg("Download of a file that is not processed yet")
+		return nil, domain.ErrFileNotReady
+	}
+
+	key := fileID
+	switch {
+	case variant == domain.VariantPreview:
+		if fileType != domain.FileTypeAudio || info.Metadata[domain.MetadataPreview] == "" {
+			return nil, domain.ErrPreviewNotFound
+		}
+		key = domain.PreviewKey(fileID)
+	case fileType == domain.FileTypeImage && size > 0:
+		key = s.variants.Resolve(info, size)
 	}
 
-	presignedURL, err := s.storage.GeneratePresignedGetURL(ctx, fileType, fileID, isInternalRequest)
+	presignedURL, err := s.storage.PresignDownload(ctx, fileType, key, isInternalRequest)
 
 	if err != nil {
 		s.logger.Error().Err(err).
@@ -92,7 +406,7 @@ func (s *fileService) GenerateDownloadURL(
 }
 
 func (s *fileService) CheckFileExists(ctx context.Context, fileType domain.FileType, fileID string) (bool, error) {
-	exists, err := s.storage.CheckFileExists(ctx, fileType, fileID)
+	exists, err := s.fileExists(ctx, fileType, fileID)
 	if err != nil {
 		s.logger.Error().Err(err).
 			Str("file_id", fileID).
@@ -104,9 +418,15 @@ func (s *fileService) CheckFileExists(ctx context.Context, fileType domain.FileT
 }
 
 func (s *fileService) DeleteFile(ctx context.Context, fileType domain.FileType, fileID string) error {
-	exists, err := s.storage.CheckFileExists(ctx, fileType, fileID)
-
+	info, err := s.storage.Stat(ctx, fileType, fileID)
 	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			s.logger.Warn().
+				Str("file_id", fileID).
+				Str("file_type", string(fileType)).
+				Msg("File not found")
+			return domain.ErrFileNotFound
+		}
 		s.logger.Error().Err(err).
 			Str("file_id", fileID).
 			Str("file_type", string(fileType)).
@@ -114,15 +434,13 @@ func (s *fileService) DeleteFile(ctx context.Context, fileType domain.FileType,
 		return fmt.Errorf("failed to check file existence: %w", err)
 	}
 
-	if !exists {
-		s.logger.Warn().
-			Str("file_id", fileID).
-			Str("file_type", string(fileType)).
-			Msg("File not found")
-		return domain.ErrFileNotFound
+	// Derived files go first: while any remains the original is kept, so a
+	// retried deletion still finds the file and deletes the rest
+	if err := s.deleteDerived(ctx, info); err != nil {
+		return fmt.Errorf("failed to delete derived files: %w", err)
 	}
 
-	if err := s.storage.DeleteFile(ctx, fileType, fileID); err != nil {
+	if err := s.storage.Delete(ctx, fileType, fileID); err != nil {
 		s.logger.Error().Err(err).
 			Str("file_id", fileID).
 			Str("file_type", string(fileType)).
@@ -130,6 +448,11 @@ func (s *fileService) DeleteFile(ctx context.Context, fileType domain.FileType,
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
+	// Only completed uploads were counted towards the owner's usage
+	if info.Metadata[domain.MetadataUploadStatus] == domain.UploadStatusCompleted {
+		s.quota.RecordDelete(ctx, info.Metadata[domain.MetadataOwner], fileType, info.Size)
+	}
+
 	s.logger.Info().
 		Str("file_id", fileID).
 		Str("file_type", string(fileType)).
@@ -137,3 +460,53 @@ func (s *fileService) DeleteFile(ctx context.Context, fileType domain.FileType,
 
 	return nil
 }
+
+// deleteDerived removes the files derived from the upload, each service logs its failures
+func (s *fileService) deleteDerived(ctx context.Context, info *domain.FileInfo) error {
+	switch info.FileType {
+	case domain.FileTypeImage:
+		return s.variants.Delete(ctx, info)
+	case domain.FileTypeAudio:
+		return errors.Join(
+			s.audio.Delete(ctx, info.FileID),
+			s.waveforms.Delete(ctx, info),
+			s.duplicates.Delete(ctx, info.FileID),
+			s.previews.Delete(ctx, info.FileID),
+		)
+	default:
+		return nil
+	}
+}
+
+func (s *fileService) fileExists(ctx context.Context, fileType domain.FileType, fileID string) (bool, error) {
+	if _, err := s.storage.Stat(ctx, fileType, fileID); err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// CompleteUpload processes an uploaded object through the chain of its file
+// type, see UploadPipeline. Only the uploader may complete it, others get
+// domain.ErrForbidden.
+func (s *fileService) CompleteUpload(ctx context.Context, fileType domain.FileType, fileID string) (*domain.FileInfo, error) {
+	info, err := s.storage.Stat(ctx, fileType, fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to stat upload: %w", err)
+	}
+
+	if info.Metadata[domain.MetadataOwner] != auth.UserIDFromContext(ctx) {
+		s.logger.Warn().
+			Str("file_id", fileID).
+			Str("file_type", string(fileType)).
+			Msg("Upload completed by another user")
+		return nil, domain.ErrForbidden
+	}
+
+	return s.pipeline.Process(ctx, fileType, fileID)
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"slices"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/imaging"
+	"file-service/internal/sniff"
+	"file-service/internal/storage"
+	"file-service/pkg/diskcache"
+	"file-service/pkg/logger"
+)
+
+// ImageTransformService resizes images on request and caches the results on local disk
+type ImageTransformService interface {
+	Transform(ctx context.Context, fileID string, req domain.ImageTransformRequest) (*domain.TransformedImage, error)
+}
+
+type imageTransformService struct {
+	storage   storage.Backend
+	cache     *diskcache.Cache
+	cfg       *config.ImageConfig
+	maxSize   int64
+	semaphore chan struct{}
+	// scanRequired keeps images that weren't scanned clean from being served
+	scanRequired bool
+	logger       *logger.Logger
+}
+
+func NewImageTransformService(
+	backend storage.Backend,
+	cache *diskcache.Cache,
+	cfg *config.Config,
+	logger *logger.Logger,
+) ImageTransformService {
+	return &imageTransformService{
+		storage:      backend,
+		cache:        cache,
+		cfg:          &cfg.Image,
+		maxSize:      cfg.Minio.Upload.ImageMaxSize,
+		semaphore:    make(chan struct{}, cfg.Image.Transform.Concurrency),
+		scanRequired: cfg.ClamAV.Enabled(),
+		logger:       logger.WithComponent("image_transform_service"),
+	}
+}
+
+func (s *imageTransformService) Transform(
+	ctx context.Context,
+	fileID string,
+	req domain.ImageTransformRequest,
+) (*domain.TransformedImage, error) {
+	if req.Width == 0 && req.Height == 0 {
+		return nil, domain.ErrInvalidTransform
+	}
+	if req.Fit == "" {
+		req.Fit = domain.FitCover
+	}
+	if req.Quality == 0 {
+		req.Quality = s.cfg.JPEGQuality
+	}
+
+	if !s.allowed(fileID, req) {
+		s.logger.Warn().
+			Str("file_id", fileID).
+			Int("width", req.Width).
+			Int("height", req.Height).
+			Int("quality", req.Quality).
+			Msg("Image transformation rejected")
+		return nil, domain.ErrTransformNotAllowed
+	}
+
+	// The original is checked on every request, so deleted or replaced
+	// images are never served from the cache of any replica
+	info, err := s.storage.Stat(ctx, domain.FileTypeImage, fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to stat image: %w", err)
+	}
+	if !info.Ready(s.scanRequired) {
+		return nil, domain.ErrFileNotReady
+	}
+
+	cacheKey := fmt.Sprintf("%s/%d/%dx%d/%s/%d",
+		fileID, info.LastModified.UnixNano(), req.Width, req.Height, req.Fit, req.Quality)
+	etagSum := sha256.Sum256([]byte(cacheKey))
+	etag := `"` + hex.EncodeToString(etagSum[:16]) + `"`
+
+	if cached, ok := s.cache.Get(cacheKey); ok {
+		return &domain.TransformedImage{
+			Data:        cached,
+			ContentType: sniff.DetectContentType(cached),
+			ETag:        etag,
+		}, nil
+	}
+
+	// Bound the memory and CPU spent on concurrent transformations
+	select {
+	case s.semaphore <- struct{}{}:
+		defer func() { <-s.semaphore }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	data, format, err := s.render(ctx, fileID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cache.Put(cacheKey, data); err != nil {
+		s.logger.Error().Err(err).Str("file_id", fileID).Msg("Failed to cache transformed image")
+	}
+
+	return &domain.TransformedImage{
+		Data:        data,
+		ContentType: imaging.ContentType(format),
+		ETag:        etag,
+	}, nil
+}
+
+func (s *imageTransformService) render(
+	ctx context.Context,
+	fileID string,
+	req domain.ImageTransformRequest,
+) ([]byte, string, error) {
+	reader, err := s.storage.Open(ctx, domain.FileTypeImage, fileID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open image: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, s.maxSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(data)) > s.maxSize {
+		return nil, "", domain.ErrFileTooLarge
+	}
+
+	img, sourceFormat, err := imaging.Decode(data, s.cfg.MaxPixels)
+	if err != nil {
+		if errors.Is(err, imaging.ErrUnsupportedFormat) || errors.Is(err, imaging.ErrTooManyPixels) {
+			return nil, "", fmt.Errorf("%w: %w", domain.ErrInvalidFileContent, err)
+		}
+		return nil, "", err
+	}
+
+	format := imaging.OutputFormat(sourceFormat)
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, transform(img, req), format, req.Quality); err != nil {
+		return nil, "", fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return buf.Bytes(), format, nil
+}
+
+// allowed checks the request against the allowlist, or its signature when it has one
+func (s *imageTransformService) allowed(fileID string, req domain.ImageTransformRequest) bool {
+	transformCfg := &s.cfg.Transform
+
+	if req.Signature != "" {
+		if transformCfg.SigningKey == "" ||
+			req.Width > transformCfg.MaxDimension || req.Height > transformCfg.MaxDimension {
+			return false
+		}
+		expected := transformSignature(transformCfg.SigningKey, fileID, req)
+		return hmac.Equal([]byte(req.Signature), []byte(expected))
+	}
+
+	dimensions := config.ImageDimensions{Width: req.Width, Height: req.Height}
+	return slices.Contains(transformCfg.AllowedSizes, dimensions) &&
+		(req.Quality == s.cfg.JPEGQuality || slices.Contains(transformCfg.AllowedQualities, req.Quality))
+}
+
+// transformSignature signs "file_id/width/height/fit/quality" of a normalized
+// request with HMAC-SHA256 and returns it hex encoded
+func transformSignature(key string, fileID string, req domain.ImageTransformRequest) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s/%d/%d/%s/%d", fileID, req.Width, req.Height, req.Fit, req.Quality)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func transform(img image.Image, req domain.ImageTransformRequest) image.Image {
+	bounds := img.Bounds()
+	width, height := req.Width, req.Height
+
+	// A single dimension keeps the aspect ratio, the fit mode doesn't matter then
+	switch {
+	case width == 0:
+		width = max(bounds.Dx()*height/bounds.Dy(), 1)
+		return imaging.Resize(img, width, height)
+	case height == 0:
+		height = max(bounds.Dy()*width/bounds.Dx(), 1)
+		return imaging.Resize(img, width, height)
+	}
+
+	switch req.Fit {
+	case domain.FitContain:
+		return imaging.Contain(img, width, height)
+	case domain.FitFill:
+		return imaging.Resize(img, width, height)
+	default:
+		return imaging.Cover(img, width, height)
+	}
+}
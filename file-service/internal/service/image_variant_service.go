@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/imaging"
+	"file-service/internal/storage"
+	"file-service/pkg/logger"
+)
+
+// ImageVariantService generates center-cropped square variants of uploaded
+// images and resolves requested sizes to them
+type ImageVariantService interface {
+	// Generate stores the variants of the image and returns their sizes.
+	// Sizes larger than the shorter edge of the image are skipped.
+	Generate(ctx context.Context, fileID string) ([]int, error)
+	// Resolve returns the key of the smallest variant at least as large as
+	// size, or the file ID of the original when there is none
+	Resolve(info *domain.FileInfo, size int) string
+	// Delete removes all variants of the image, it tries every variant
+	// before returning the errors
+	Delete(ctx context.Context, info *domain.FileInfo) error
+}
+
+type imageVariantService struct {
+	storage storage.Backend
+	cfg     *config.ImageConfig
+	maxSize int64
+	logger  *logger.Logger
+}
+
+func NewImageVariantService(backend storage.Backend, cfg *config.Config, logger *logger.Logger) ImageVariantService {
+	return &imageVariantService{
+		storage: backend,
+		cfg:     &cfg.Image,
+		maxSize: cfg.Minio.Upload.ImageMaxSize,
+		logger:  logger.WithComponent("image_variant_service"),
+	}
+}
+
+func (s *imageVariantService) Generate(ctx context.Context, fileID string) ([]int, error) {
+	reader, err := s.storage.Open(ctx, domain.FileTypeImage, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, s.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(data)) > s.maxSize {
+		return nil, domain.ErrFileTooLarge
+	}
+
+	img, format, err := imaging.Decode(data, s.cfg.MaxPixels)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	shortEdge := min(bounds.Dx(), bounds.Dy())
+	outputFormat := imaging.OutputFormat(format)
+
+	var sizes []int
+	for _, size := range s.cfg.VariantSizes {
+		if size > shortEdge {
+			break
+		}
+
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, imaging.Cover(img, size, size), outputFormat, s.cfg.JPEGQuality); err != nil {
+			return sizes, fmt.Errorf("failed to encode %dpx variant: %w", size, err)
+		}
+
+		key := domain.ImageVariantKey(fileID, size)
+		err := s.storage.Put(ctx, domain.FileTypeImage, key, &buf, int64(buf.Len()), imaging.ContentType(outputFormat))
+		if err != nil {
+			return sizes, fmt.Errorf("failed to store %dpx variant: %w", size, err)
+		}
+		sizes = append(sizes, size)
+	}
+
+	s.logger.Info().
+		Str("file_id", fileID).
+		Ints("sizes", sizes).
+		Msg("Image variants generated")
+
+	return sizes, nil
+}
+
+func (s *imageVariantService) Resolve(info *domain.FileInfo, size int) string {
+	for _, variant := range variantSizes(info) {
+		if variant >= size {
+			return domain.ImageVariantKey(info.FileID, variant)
+		}
+	}
+	return info.FileID
+}
+
+func (s *imageVariantService) Delete(ctx context.Context, info *domain.FileInfo) error {
+	// Configured sizes are included in case recording the variants failed
+	sizes := append(variantSizes(info), s.cfg.VariantSizes...)
+	slices.Sort(sizes)
+
+	var errs []error
+	for _, size := range slices.Compact(sizes) {
+		if err := s.storage.Delete(ctx, domain.FileTypeImage, domain.ImageVariantKey(info.FileID, size)); err != nil {
+			s.logger.Error().Err(err).
+				Str("file_id", info.FileID).
+				Int("size", size).
+				Msg("Failed to delete image variant")
+			errs = append(errs, fmt.Errorf("failed to delete image variant %d: %w", size, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// formatSizes encodes sizes such as variant sizes for the object metadata
+func formatSizes(sizes []int) string {
+	values := make([]string, len(sizes))
+	for i, size := range sizes {
+		values[i] = strconv.Itoa(size)
+	}
+	return strings.Join(values, ",")
+}
+
+// variantSizes returns the variant sizes recorded on the image in ascending order
+func variantSizes(info *domain.FileInfo) []int {
+	return metadataSizes(info, domain.MetadataImageVariants)
+}
+
+// metadataSizes returns the sizes recorded under the metadata key in ascending order
+func metadataSizes(info *domain.FileInfo, key string) []int {
+	value := info.Metadata[key]
+	if value == "" {
+		return nil
+	}
+
+	var sizes []int
+	for field := range strings.SplitSeq(value, ",") {
+		if size, err := strconv.Atoi(field); err == nil {
+			sizes = append(sizes, size)
+		}
+	}
+	slices.Sort(sizes)
+	return sizes
+}
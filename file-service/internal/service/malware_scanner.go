@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/storage"
+	"file-service/pkg/clamd"
+	"file-service/pkg/logger"
+)
+
+// MalwareScanner scans new objects with clamd before they are offered for
+// download. Infected objects are moved to quarantine.
+type MalwareScanner interface {
+	// Scan returns the verdict of the scan, or an empty verdict if scanning is
+	// disabled. It returns domain.ErrMalwareDetected if the file is infected,
+	// which is quarantined then.
+	Scan(ctx context.Context, info *domain.FileInfo) (string, error)
+}
+
+type malwareScanner struct {
+	storage    storage.Backend
+	quarantine QuarantineService
+	client     *clamd.Client // nil if scanning is disabled
+	logger     *logger.Logger
+}
+
+func NewMalwareScanner(
+	backend storage.Backend,
+	quarantine QuarantineService,
+	cfg *config.Config,
+	logger *logger.Logger,
+) (MalwareScanner, error) {
+	s := &malwareScanner{
+		storage:    backend,
+		quarantine: quarantine,
+		logger:     logger.WithComponent("malware_scanner"),
+	}
+
+	if cfg.ClamAV.Address == "" {
+		s.logger.Warn().Msg("Malware scanning is disabled, CLAMAV_ADDRESS is not set")
+		return s, nil
+	}
+
+	client, err := clamd.New(cfg.ClamAV.Address, cfg.ClamAV.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clamd client: %w", err)
+	}
+	s.client = client
+
+	return s, nil
+}
+
+func (s *malwareScanner) Scan(ctx context.Context, info *domain.FileInfo) (string, error) {
+	if s.client == nil {
+		return "", nil
+	}
+
+	object, err := s.storage.Open(ctx, info.FileType, info.FileID)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	result, err := s.client.Scan(ctx, object)
+	object.Close()
+	if err != nil {
+		return "", err
+	}
+
+	if !result.Infected {
+		return domain.ScanVerdictClean, nil
+	}
+
+	s.logger.Warn().
+		Str("file_id", info.FileID).
+		Str("file_type", string(info.FileType)).
+		Str("signature", result.Signature).
+		Msg("Uploaded file contains malware, quarantining")
+
+	s.quarantine.Reject(ctx, info, domain.Rejection{
+		Reason:    domain.RejectionMalware,
+		Signature: result.Signature,
+	})
+
+	return domain.ScanVerdictInfected, domain.ErrMalwareDetected
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"file-service/internal/audioclip"
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/storage"
+	"file-service/pkg/logger"
+)
+
+// PreviewService cuts short preview clips out of uploaded audio files, which
+// can be shared without handing out the full file
+type PreviewService interface {
+	// Generate stores the preview clip of the file and records its length on
+	// the file. Formats that can't be cut without re-encoding are skipped.
+	Generate(ctx context.Context, fileID string) error
+	// Delete removes the preview clip of the file
+	Delete(ctx context.Context, fileID string) error
+}
+
+type previewService struct {
+	storage storage.Backend
+	cfg     *config.PreviewConfig
+	logger  *logger.Logger
+}
+
+func NewPreviewService(backend storage.Backend, cfg *config.Config, logger *logger.Logger) PreviewService {
+	return &previewService{
+		storage: backend,
+		cfg:     &cfg.Preview,
+		logger:  logger.WithComponent("preview_service"),
+	}
+}
+
+func (s *previewService) Generate(ctx context.Context, fileID string) error {
+	clip, err := s.cut(ctx, fileID)
+	if errors.Is(err, audioclip.ErrUnsupportedFormat) {
+		s.logger.Info().Str("file_id", fileID).Msg("Audio format doesn't support preview clips")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	key := domain.PreviewKey(fileID)
+	if err := s.storage.Put(ctx, domain.FileTypeAudio, key, bytes.NewReader(clip.Data), int64(len(clip.Data)), clip.ContentType); err != nil {
+		return fmt.Errorf("failed to store preview clip: %w", err)
+	}
+
+	length := strconv.Itoa(int(math.Round(clip.Duration.Seconds())))
+	if err := s.storage.UpdateMetadata(ctx, domain.FileTypeAudio, fileID, "", map[string]string{domain.MetadataPreview: length}); err != nil {
+		// The file was deleted while its clip was cut
+		if errors.Is(err, domain.ErrFileNotFound) {
+			s.Delete(ctx, fileID)
+		}
+		return fmt.Errorf("failed to record preview clip: %w", err)
+	}
+
+	s.logger.Info().
+		Str("file_id", fileID).
+		Dur("start", clip.Start).
+		Dur("length", clip.Duration).
+		Msg("Preview clip generated")
+
+	return nil
+}
+
+func (s *previewService) cut(ctx context.Context, fileID string) (*audioclip.Clip, error) {
+	reader, err := s.storage.Open(ctx, domain.FileTypeAudio, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
+	}
+	defer reader.Close()
+
+	clip, err := audioclip.Cut(reader, s.cfg.Offset, s.cfg.Length)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cut preview clip: %w", err)
+	}
+	return clip, nil
+}
+
+func (s *previewService) Delete(ctx context.Context, fileID string) error {
+	if err := s.storage.Delete(ctx, domain.FileTypeAudio, domain.PreviewKey(fileID)); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Msg("Failed to delete preview clip")
+		return fmt.Errorf("failed to delete preview clip: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"file-service/internal/domain"
+	"file-service/internal/publisher"
+	"file-service/internal/storage"
+	"file-service/pkg/logger"
+)
+
+// QuarantineService moves rejected uploads out of reach of downloads. They are
+// copied to the private quarantine storage, recording why they were rejected,
+// and the rejection is published to UploadRejectedQueue and as file.rejected
+// event.
+type QuarantineService interface {
+	// Reject quarantines the file and deletes the original, which must not
+	// stay downloadable even if quarantining fails
+	Reject(ctx context.Context, info *domain.FileInfo, rejection domain.Rejection)
+}
+
+type quarantineService struct {
+	storage   storage.Backend
+	publisher publisher.Publisher
+	events    publisher.EventPublisher
+	logger    *logger.Logger
+}
+
+func NewQuarantineService(
+	backend storage.Backend,
+	publisher publisher.Publisher,
+	events publisher.EventPublisher,
+	logger *logger.Logger,
+) QuarantineService {
+	return &quarantineService{
+		storage:   backend,
+		publisher: publisher,
+		events:    events,
+		logger:    logger.WithComponent("quarantine_service"),
+	}
+}
+
+func (s *quarantineService) Reject(ctx context.Context, info *domain.FileInfo, rejection domain.Rejection) {
+	message := domain.UploadRejectedMessage{
+		FileID:              info.FileID,
+		FileType:            info.FileType,
+		OwnerID:             info.Metadata[domain.MetadataOwner],
+		Rejection:           rejection,
+		DeclaredContentType: info.ContentType,
+		RejectedAt:          time.Now().UTC(),
+	}
+
+	if err := s.quarantine(ctx, info, rejection); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", info.FileID).
+			Str("file_type", string(info.FileType)).
+			Msg("Failed to quarantine rejected file")
+	} else {
+		message.QuarantineKey = domain.QuarantineKey(info.FileType, info.FileID)
+	}
+
+	if err := s.storage.Delete(ctx, info.FileType, info.FileID); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", info.FileID).
+			Str("file_type", string(info.FileType)).
+			Msg("Failed to delete rejected file")
+	}
+
+	if err := s.publisher.Publish(ctx, domain.UploadRejectedQueue, message); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", info.FileID).
+			Msg("Failed to publish upload rejection")
+	}
+
+	event := domain.FileEvent{
+		FileID:      info.FileID,
+		FileType:    info.FileType,
+		OwnerID:     message.OwnerID,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+		Rejection:   &rejection,
+	}
+	if err := s.events.PublishEvent(ctx, domain.EventFileRejected, event); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", info.FileID).
+			Str("event", domain.EventFileRejected).
+			Msg("Failed to publish file event")
+	}
+}
+
+// quarantine copies the object to the quarantine storage, recording why it was rejected
+func (s *quarantineService) quarantine(ctx context.Context, info *domain.FileInfo, rejection domain.Rejection) error {
+	object, err := s.storage.Open(ctx, info.FileType, info.FileID)
+	if err != nil {
+		return fmt.Errorf("failed to open rejected file: %w", err)
+	}
+	defer object.Close()
+
+	key := domain.QuarantineKey(info.FileType, info.FileID)
+	if err := s.storage.Put(ctx, domain.FileTypeQuarantine, key, object, info.Size, info.ContentType); err != nil {
+		return fmt.Errorf("failed to copy rejected file: %w", err)
+	}
+
+	metadata := map[string]string{
+		domain.MetadataRejection: rejection.Reason,
+	}
+	if rejection.DetectedContentType != "" {
+		metadata[domain.MetadataDetectedContentType] = rejection.DetectedContentType
+	}
+	if rejection.Reason == domain.RejectionMalware {
+		metadata[domain.MetadataScanVerdict] = domain.ScanVerdictInfected
+		metadata[domain.MetadataScanSignature] = rejection.Signature
+	}
+	if owner := info.Metadata[domain.MetadataOwner]; owner != "" {
+		metadata[domain.MetadataOwner] = owner
+	}
+	if err := s.storage.UpdateMetadata(ctx, domain.FileTypeQuarantine, key, "", metadata); err != nil {
+		return fmt.Errorf("failed to record rejection: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/storage/cache"
+	"file-service/pkg/logger"
+)
+
+// QuotaService enforces per-user storage quotas. Usage counts completed
+// uploads only and is skipped for uploads without an owner.
+type QuotaService interface {
+	// CheckUpload returns a QuotaExceededError when the upload would exceed
+	// the owner's quota. Without a declared size it only rejects owners who
+	// already used up their quota. Otherwise it returns the quota left, which
+	// is zero when no quota applies.
+	CheckUpload(ctx context.Context, ownerID string, fileType domain.FileType, size int64) (int64, error)
+	RecordUpload(ctx context.Context, ownerID string, fileType domain.FileType, size int64)
+	RecordDelete(ctx context.Context, ownerID string, fileType domain.FileType, size int64)
+	GetUsage(ctx context.Context, ownerID string) (*domain.UsageResponse, error)
+}
+
+type quotaService struct {
+	store  cache.UsageStore
+	cfg    *config.QuotaConfig
+	logger *logger.Logger
+}
+
+func NewQuotaService(store cache.UsageStore, cfg *config.QuotaConfig, logger *logger.Logger) QuotaService {
+	return &quotaService{
+		store:  store,
+		cfg:    cfg,
+		logger: logger.WithComponent("quota_service"),
+	}
+}
+
+func (s *quotaService) CheckUpload(ctx context.Context, ownerID string, fileType domain.FileType, size int64) (int64, error) {
+	limit := s.cfg.ForFileType(string(fileType))
+	if ownerID == "" || limit == 0 {
+		return 0, nil
+	}
+
+	usage, err := s.store.Get(ctx, ownerID)
+	if err != nil {
+		s.logger.Error().Err(err).
+			Str("owner_id", ownerID).
+			Msg("Failed to get storage usage")
+		return 0, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	used := usage[fileType]
+	if used+size > limit || used >= limit {
+		s.logger.Warn().
+			Str("owner_id", ownerID).
+			Str("file_type", string(fileType)).
+			Int64("used", used).
+			Int64("limit", limit).
+			Int64("size", size).
+			Msg("Upload request rejected by quota")
+		return 0, &domain.QuotaExceededError{
+			FileType:  fileType,
+			Used:      used,
+			Limit:     limit,
+			Requested: size,
+		}
+	}
+
+	return limit - used, nil
+}
+
+func (s *quotaService) RecordUpload(ctx context.Context, ownerID string, fileType domain.FileType, size int64) {
+	s.record(ctx, ownerID, fileType, size)
+}
+
+func (s *quotaService) RecordDelete(ctx context.Context, ownerID string, fileType domain.FileType, size int64) {
+	s.record(ctx, ownerID, fileType, -size)
+}
+
+// record adjusts the usage. Failures are only logged, the file operation
+// itself has already succeeded.
+func (s *quotaService) record(ctx context.Context, ownerID string, fileType domain.FileType, delta int64) {
+	if ownerID == "" || delta == 0 {
+		return
+	}
+
+	used, err := s.store.Add(ctx, ownerID, fileType, delta)
+	if err != nil {
+		s.logger.Error().Err(err).
+			Str("owner_id", ownerID).
+			Str("file_type", string(fileType)).
+			Int64("delta", delta).
+			Msg("Failed to update storage usage")
+		return
+	}
+
+	s.logger.Debug().
+		Str("owner_id", ownerID).
+		Str("file_type", string(fileType)).
+		Int64("used", used).
+		Msg("Storage usage updated")
+}
+
+func (s *quotaService) GetUsage(ctx context.Context, ownerID string) (*domain.UsageResponse, error) {
+	usage, err := s.store.Get(ctx, ownerID)
+	if err != nil {
+		s.logger.Error().Err(err).
+			Str("owner_id", ownerID).
+			Msg("Failed to get storage usage")
+		return nil, fmt.Errorf("failed to get storage usage: %w", err)
+	}
+
+	response := &domain.UsageResponse{UserID: ownerID}
+	for _, fileType := range []domain.FileType{domain.FileTypeAudio, domain.FileTypeImage} {
+		response.Usage = append(response.Usage, domain.FileTypeUsage{
+			FileType: fileType,
+			Used:     usage[fileType],
+			Limit:    s.cfg.ForFileType(string(fileType)),
+		})
+	}
+
+	return response, nil
+}
@@ -0,0 +1,382 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/storage"
+	"file-service/internal/storage/cache"
+	"file-service/pkg/auth"
+	"file-service/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// TusService implements the storage side of the tus 1.0 resumable upload protocol
+type TusService interface {
+	CreateUpload(ctx context.Context, req domain.UploadRequest) (*domain.TusUpload, error)
+	// GetUpload, WriteChunk and TerminateUpload return domain.ErrUploadNotFound
+	// unless the caller created the upload
+	GetUpload(ctx context.Context, fileID string) (*domain.TusUpload, error)
+	// WriteChunk processes the file like FileService.CompleteUpload once its last byte arrived
+	WriteChunk(ctx context.Context, fileID string, offset int64, chunk io.Reader) (*domain.TusUpload, error)
+	TerminateUpload(ctx context.Context, fileID string) error
+	MaxSize() int64
+}
+
+type tusService struct {
+	storage  storage.MultipartBackend
+	store    cache.UploadStore
+	quota    QuotaService
+	pipeline UploadPipeline
+	cfg      *config.TusConfig
+	partSize int64
+	maxSize  int64
+	// buffers holds the part buffers of chunks being written, allocated on
+	// first use. Waiting for one bounds the memory of concurrent writes.
+	buffers chan []byte
+	logger  *logger.Logger
+}
+
+func NewTusService(
+	backend storage.MultipartBackend,
+	store cache.UploadStore,
+	quota QuotaService,
+	pipeline UploadPipeline,
+	cfg *config.Config,
+	logger *logger.Logger,
+) TusService {
+	buffers := make(chan []byte, cfg.Tus.MaxConcurrentWrites)
+	for range cfg.Tus.MaxConcurrentWrites {
+		buffers <- nil
+	}
+
+	return &tusService{
+		storage:  backend,
+		store:    store,
+		quota:    quota,
+		pipeline: pipeline,
+		cfg:      &cfg.Tus,
+		partSize: cfg.Minio.Multipart.PartSize,
+		maxSize:  max(cfg.Minio.Upload.AudioMaxSize, cfg.Minio.Upload.ImageMaxSize),
+		buffers:  buffers,
+		logger:   logger.WithComponent("tus_service"),
+	}
+}
+
+func (s *tusService) MaxSize() int64 {
+	return s.maxSize
+}
+
+func (s *tusService) CreateUpload(ctx context.Context, req domain.UploadRequest) (*domain.TusUpload, error) {
+	fileID := uuid.New().String()
+	req.OwnerID = auth.UserIDFromContext(ctx)
+
+	if _, err := s.quota.CheckUpload(ctx, req.OwnerID, req.FileType, req.Size); err != nil {
+		return nil, err
+	}
+
+	multipart, err := s.storage.InitiateMultipartUpload(ctx, req, fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileTooLarge) || errors.Is(err, domain.ErrContentTypeNotAllowed) {
+			s.logger.Warn().Err(err).
+				Str("file_type", string(req.FileType)).
+				Str("content_type", req.ContentType).
+				Int64("size", req.Size).
+				Msg("Resumable upload request rejected by limits")
+			return nil, err
+		}
+		s.logger.Error().Err(err).
+			Str("file_type", string(req.FileType)).
+			Msg("Failed to create resumable upload")
+		return nil, fmt.Errorf("failed to create resumable upload: %w", err)
+	}
+
+	upload := &domain.TusUpload{
+		FileID:      fileID,
+		FileType:    req.FileType,
+		ContentType: req.ContentType,
+		OwnerID:     req.OwnerID,
+		Length:      req.Size,
+		UploadID:    multipart.UploadID,
+	}
+
+	if err := s.save(ctx, upload); err != nil {
+		s.abort(upload)
+		return nil, err
+	}
+
+	s.logger.Info().
+		Str("file_id", fileID).
+		Str("file_type", string(req.FileType)).
+		Int64("length", req.Size).
+		Msg("Resumable upload created")
+
+	return upload, nil
+}
+
+func (s *tusService) GetUpload(ctx context.Context, fileID string) (*domain.TusUpload, error) {
+	upload, found, err := s.store.Get(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load upload state: %w", err)
+	}
+	if !found {
+		return nil, domain.ErrUploadNotFound
+	}
+
+	// Uploads of others are reported as missing, so their IDs can't be probed
+	if upload.OwnerID != auth.UserIDFromContext(ctx) {
+		s.logger.Warn().
+			Str("file_id", fileID).
+			Msg("Resumable upload accessed by another user")
+		return nil, domain.ErrUploadNotFound
+	}
+
+	return upload, nil
+}
+
+// WriteChunk appends the chunk at the given offset. Full parts are uploaded
+// as they fill up, so bytes received before a dropped connection are kept
+// and the client can resume from the returned offset.
+func (s *tusService) WriteChunk(ctx context.Context, fileID string, offset int64, chunk io.Reader) (*domain.TusUpload, error) {
+	buf, err := s.acquireBuffer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseBuffer(buf)
+
+	unlock, err := s.store.Lock(ctx, fileID, s.cfg.LockTTL)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	upload, err := s.GetUpload(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset != upload.Offset {
+		return nil, domain.ErrOffsetMismatch
+	}
+
+	buf, err = s.readTail(ctx, upload, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := io.LimitReader(chunk, upload.Length-upload.Offset)
+	var readErr error
+	for readErr == nil && upload.Offset < upload.Length {
+		var n int
+		n, readErr = io.ReadFull(reader, buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		upload.Offset += int64(n)
+
+		if len(buf) == cap(buf) && upload.Offset < upload.Length {
+			if err := s.uploadPart(ctx, upload, buf); err != nil {
+				return nil, err
+			}
+			buf = buf[:0]
+			upload.TailSize = 0
+			if err := s.save(ctx, upload); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if upload.Offset == upload.Length {
+		if err := s.finish(ctx, upload, buf); err != nil {
+			return nil, err
+		}
+		return upload, nil
+	}
+
+	if upload.Offset != offset {
+		if err := s.writeTail(ctx, upload, buf); err != nil {
+			return nil, err
+		}
+		if err := s.save(ctx, upload); err != nil {
+			return nil, err
+		}
+	}
+
+	if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+		s.logger.Warn().Err(readErr).
+			Str("file_id", fileID).
+			Int64("offset", upload.Offset).
+			Msg("Chunk interrupted, progress saved")
+	}
+
+	return upload, nil
+}
+
+func (s *tusService) TerminateUpload(ctx context.Context, fileID string) error {
+	unlock, err := s.store.Lock(ctx, fileID, s.cfg.LockTTL)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	upload, err := s.GetUpload(ctx, fileID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.storage.AbortMultipartUpload(ctx, upload.FileType, fileID, upload.UploadID); err != nil &&
+		!errors.Is(err, domain.ErrUploadNotFound) {
+		return fmt.Errorf("failed to abort upload: %w", err)
+	}
+
+	s.deleteTail(ctx, upload)
+
+	if err := s.store.Delete(ctx, fileID); err != nil {
+		return fmt.Errorf("failed to delete upload state: %w", err)
+	}
+
+	s.logger.Info().
+		Str("file_id", fileID).
+		Str("file_type", string(upload.FileType)).
+		Msg("Resumable upload terminated")
+
+	return nil
+}
+
+// finish uploads the remaining bytes as the last part, assembles the object
+// and processes it
+func (s *tusService) finish(ctx context.Context, upload *domain.TusUpload, buf []byte) error {
+	if len(buf) > 0 || len(upload.Parts) == 0 {
+		if err := s.uploadPart(ctx, upload, buf); err != nil {
+			return err
+		}
+	}
+
+	err := s.storage.CompleteMultipartUpload(ctx, upload.FileType, upload.FileID, upload.UploadID, upload.Parts)
+	if err != nil {
+		return fmt.Errorf("failed to complete resumable upload: %w", err)
+	}
+
+	s.deleteTail(ctx, upload)
+
+	if err := s.store.Delete(ctx, upload.FileID); err != nil {
+		s.logger.Warn().Err(err).Str("file_id", upload.FileID).Msg("Failed to delete upload state")
+	}
+
+	s.logger.Info().
+		Str("file_id", upload.FileID).
+		Str("file_type", string(upload.FileType)).
+		Int64("length", upload.Length).
+		Int("parts", len(upload.Parts)).
+		Msg("Resumable upload assembled")
+
+	_, err = s.pipeline.Process(ctx, upload.FileType, upload.FileID)
+	return err
+}
+
+func (s *tusService) uploadPart(ctx context.Context, upload *domain.TusUpload, data []byte) error {
+	partNumber := len(upload.Parts) + 1
+
+	etag, err := s.storage.UploadPart(ctx, upload.FileType, upload.FileID, upload.UploadID, partNumber, data)
+	if err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", upload.FileID).
+			Int("part_number", partNumber).
+			Msg("Failed to upload part")
+		return fmt.Errorf("failed to upload part: %w", err)
+	}
+
+	upload.Parts = append(upload.Parts, domain.CompletedPart{
+		PartNumber: partNumber,
+		ETag:       etag,
+	})
+	return nil
+}
+
+// acquireBuffer waits for an empty part-sized buffer
+func (s *tusService) acquireBuffer(ctx context.Context) ([]byte, error) {
+	select {
+	case buf := <-s.buffers:
+		if buf == nil {
+			buf = make([]byte, 0, s.partSize)
+		}
+		return buf[:0], nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *tusService) releaseBuffer(buf []byte) {
+	s.buffers <- buf[:0]
+}
+
+// readTail prefills the buffer with the stored tail bytes
+func (s *tusService) readTail(ctx context.Context, upload *domain.TusUpload, buf []byte) ([]byte, error) {
+	if upload.TailSize == 0 {
+		return buf, nil
+	}
+
+	tail, err := s.storage.Open(ctx, upload.FileType, domain.TusTailKey(upload.FileID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload tail: %w", err)
+	}
+	defer tail.Close()
+
+	buf = buf[:upload.TailSize]
+	if _, err := io.ReadFull(tail, buf); err != nil {
+		return nil, fmt.Errorf("failed to read upload tail: %w", err)
+	}
+
+	return buf, nil
+}
+
+func (s *tusService) writeTail(ctx context.Context, upload *domain.TusUpload, buf []byte) error {
+	if len(buf) == 0 {
+		s.deleteTail(ctx, upload)
+		return nil
+	}
+
+	err := s.storage.Put(ctx, upload.FileType, domain.TusTailKey(upload.FileID),
+		bytes.NewReader(buf), int64(len(buf)), "application/octet-stream")
+	if err != nil {
+		return fmt.Errorf("failed to write upload tail: %w", err)
+	}
+
+	upload.TailSize = int64(len(buf))
+	return nil
+}
+
+// deleteTail removes the tail object. A stale tail may exist even when the
+// state has no tail bytes, so deletion is attempted unconditionally.
+func (s *tusService) deleteTail(ctx context.Context, upload *domain.TusUpload) {
+	if err := s.storage.Delete(ctx, upload.FileType, domain.TusTailKey(upload.FileID)); err != nil {
+		s.logger.Warn().Err(err).Str("file_id", upload.FileID).Msg("Failed to delete upload tail")
+	}
+	upload.TailSize = 0
+}
+
+func (s *tusService) save(ctx context.Context, upload *domain.TusUpload) error {
+	upload.ExpiresAt = time.Now().Add(s.cfg.UploadTTL)
+	if err := s.store.Save(ctx, upload, s.cfg.UploadTTL); err != nil {
+		s.logger.Error().Err(err).
+			Str("file_id", upload.FileID).
+			Msg("Failed to save upload state")
+		return fmt.Errorf("failed to save upload state: %w", err)
+	}
+	return nil
+}
+
+// abort discards the multipart upload of an upload that couldn't be registered
+func (s *tusService) abort(upload *domain.TusUpload) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.storage.AbortMultipartUpload(ctx, upload.FileType, upload.FileID, upload.UploadID); err != nil {
+		s.logger.Warn().Err(err).Str("file_id", upload.FileID).Msg("Failed to abort multipart upload")
+	}
+}
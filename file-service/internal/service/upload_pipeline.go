@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/publisher"
+	"file-service/internal/sniff"
+	"file-service/internal/storage"
+	"file-service/internal/storage/cache"
+	"file-service/pkg/logger"
+)
+
+// lockRetryInterval is how often a file locked by another processing is checked
+const lockRetryInterval = 500 * time.Millisecond
+
+// Upload is an uploaded file handed along a processor chain
+type Upload struct {
+	// Info is the file as stored before processing
+	Info *domain.FileInfo
+	// ContentType is sniffed from the leading bytes of the content
+	ContentType string
+	Checksum    string
+	// Metadata is recorded on the file by the record processor
+	Metadata map[string]string
+}
+
+// Reprocessed tells if the file was processed successfully before, processors
+// skip work that must only be done once
+func (u *Upload) Reprocessed() bool {
+	return u.Info.Metadata[domain.MetadataUploadStatus] == domain.UploadStatusCompleted
+}
+
+// Processor is a step of the processing of uploaded files. Processors must
+// tolerate running again on a file they already processed.
+type Processor interface {
+	// Process returns an error to stop the chain, domain.ErrInvalidFileContent
+	// and domain.ErrMalwareDetected reject the file
+	Process(ctx context.Context, upload *Upload) error
+}
+
+// ProcessorFunc adapts a function to a Processor
+type ProcessorFunc func(ctx context.Context, upload *Upload) error
+
+func (f ProcessorFunc) Process(ctx context.Context, upload *Upload) error {
+	return f(ctx, upload)
+}
+
+// UploadPipeline runs a chain of processors on uploaded files. Processors are
+// registered by name and the chain of each file type lists them in order.
+// A file is processed by one replica at a time. The file.uploaded and
+// file.processed events are published the first time a file is processed.
+type UploadPipeline interface {
+	Register(name string, processor Processor)
+	// SetChain sets the processors run on files of the type, they must be registered
+	SetChain(fileType domain.FileType, names ...string) error
+	// Process runs the chain of the file type on the file and returns it as
+	// processed. It returns domain.ErrFileNotFound if the file doesn't exist.
+	Process(ctx context.Context, fileType domain.FileType, fileID string) (*domain.FileInfo, error)
+	// Status returns domain.ErrFileNotFound if no processing of the file is known
+	Status(ctx context.Context, fileID string) (*domain.ProcessingStatus, error)
+}
+
+type uploadPipeline struct {
+	storage    storage.Backend
+	store      cache.ProcessingStore
+	events     publisher.EventPublisher
+	cfg        *config.PipelineConfig
+	processors map[string]Processor
+	chains     map[domain.FileType][]string
+	logger     *logger.Logger
+}
+
+func NewUploadPipeline(
+	backend storage.Backend,
+	store cache.ProcessingStore,
+	events publisher.EventPublisher,
+	cfg *config.Config,
+	logger *logger.Logger,
+) UploadPipeline {
+	return &uploadPipeline{
+		storage:    backend,
+		store:      store,
+		events:     events,
+		cfg:        &cfg.Pipeline,
+		processors: make(map[string]Processor),
+		chains:     make(map[domain.FileType][]string),
+		logger:     logger.WithComponent("upload_pipeline"),
+	}
+}
+
+func (p *uploadPipeline) Register(name string, processor Processor) {
+	p.processors[name] = processor
+}
+
+func (p *uploadPipeline) SetChain(fileType domain.FileType, names ...string) error {
+	for _, name := range names {
+		if _, ok := p.processors[name]; !ok {
+			return fmt.Errorf("processor %q of the %s chain is not registered", name, fileType)
+		}
+	}
+	p.chains[fileType] = names
+	return nil
+}
+
+func (p *uploadPipeline) Process(ctx context.Context, fileType domain.FileType, fileID string) (*domain.FileInfo, error) {
+	chain, ok := p.chains[fileType]
+	if !ok {
+		return nil, fmt.Errorf("no processor chain for file type %s", fileType)
+	}
+
+	unlock, err := p.lock(ctx, fileID)
+	if err != nil {
+		p.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Str("file_type", string(fileType)).
+			Msg("Failed to lock uploaded file for processing")
+		return nil, fmt.Errorf("failed to lock uploaded file: %w", err)
+	}
+	defer unlock()
+
+	info, err := p.storage.Stat(ctx, fileType, fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			p.logger.Warn().
+				Str("file_id", fileID).
+				Str("file_type", string(fileType)).
+				Msg("Uploaded file not found")
+			return nil, err
+		}
+		p.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Str("file_type", string(fileType)).
+			Msg("Failed to stat uploaded file")
+		return nil, fmt.Errorf("failed to stat uploaded file: %w", err)
+	}
+
+	contentType, checksum, err := p.inspect(ctx, fileType, fileID)
+	if err != nil {
+		p.logger.Error().Err(err).
+			Str("file_id", fileID).
+			Str("file_type", string(fileType)).
+			Msg("Failed to read uploaded file")
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+
+	upload := &Upload{
+		Info:        info,
+		ContentType: contentType,
+		Checksum:    checksum,
+		Metadata: map[string]string{
+			domain.MetadataUploadStatus: domain.UploadStatusCompleted,
+			domain.MetadataChecksum:     checksum,
+		},
+	}
+
+	if !upload.Reprocessed() {
+		p.publishEvent(ctx, domain.EventFileUploaded, upload)
+	}
+
+	for _, name := range chain {
+		p.saveStatus(ctx, info, domain.ProcessingStateProcessing, name, nil)
+
+		if err := p.processors[name].Process(ctx, upload); err != nil {
+			state := domain.ProcessingStateFailed
+			if errors.Is(err, domain.ErrInvalidFileContent) || errors.Is(err, domain.ErrMalwareDetected) {
+				state = domain.ProcessingStateRejected
+			} else {
+				p.logger.Error().Err(err).
+					Str("file_id", fileID).
+					Str("file_type", string(fileType)).
+					Str("processor", name).
+					Msg("Failed to process uploaded file")
+			}
+			p.saveStatus(ctx, info, state, name, err)
+			return nil, err
+		}
+	}
+	p.saveStatus(ctx, info, domain.ProcessingStateCompleted, "", nil)
+
+	if !upload.Reprocessed() {
+		p.publishEvent(ctx, domain.EventFileProcessed, upload)
+	}
+
+	info.ContentType = contentType
+	info.Checksum = checksum
+
+	p.logger.Info().
+		Str("file_id", fileID).
+		Str("file_type", string(fileType)).
+		Str("content_type", contentType).
+		Int64("size", info.Size).
+		Msg("Upload completed successfully")
+
+	return info, nil
+}
+
+func (p *uploadPipeline) Status(ctx context.Context, fileID string) (*domain.ProcessingStatus, error) {
+	status, found, err := p.store.Get(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get processing status: %w", err)
+	}
+	if !found {
+		return nil, domain.ErrFileNotFound
+	}
+	return status, nil
+}
+
+// lock waits until no other processing of the file holds its lock. A client
+// completing an upload races the bucket notification of the same upload.
+func (p *uploadPipeline) lock(ctx context.Context, fileID string) (func(), error) {
+	for {
+		unlock, err := p.store.Lock(ctx, fileID, p.cfg.LockTTL)
+		if !errors.Is(err, cache.ErrLocked) {
+			return unlock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// saveStatus is best effort, processing doesn't depend on it
+func (p *uploadPipeline) saveStatus(ctx context.Context, info *domain.FileInfo, state string, processor string, err error) {
+	status := &domain.ProcessingStatus{
+		FileID:    info.FileID,
+		FileType:  info.FileType,
+		State:     state,
+		Processor: processor,
+		UpdatedAt: time.Now().UTC(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	if err := p.store.Save(ctx, status, p.cfg.StatusTTL); err != nil {
+		p.logger.Warn().Err(err).
+			Str("file_id", info.FileID).
+			Str("state", state).
+			Msg("Failed to save processing status")
+	}
+}
+
+// publishEvent is best effort like the other events of uploads
+func (p *uploadPipeline) publishEvent(ctx context.Context, eventType string, upload *Upload) {
+	event := domain.FileEvent{
+		FileID:      upload.Info.FileID,
+		FileType:    upload.Info.FileType,
+		OwnerID:     upload.Info.Metadata[domain.MetadataOwner],
+		Size:        upload.Info.Size,
+		ContentType: upload.ContentType,
+		Checksum:    upload.Checksum,
+	}
+
+	if err := p.events.PublishEvent(ctx, eventType, event); err != nil {
+		p.logger.Error().Err(err).
+			Str("file_id", upload.Info.FileID).
+			Str("event", eventType).
+			Msg("Failed to publish file event")
+	}
+}
+
+// inspect streams the object once, sniffing its content type from the
+// leading bytes and computing the SHA-256 checksum of the whole content
+func (p *uploadPipeline) inspect(ctx context.Context, fileType domain.FileType, fileID string) (string, string, error) {
+	object, err := p.storage.Open(ctx, fileType, fileID)
+	if err != nil {
+		return "", "", err
+	}
+	defer object.Close()
+
+	hash := sha256.New()
+	reader := io.TeeReader(object, hash)
+
+	header := make([]byte, sniff.HeaderSize)
+	n, err := io.ReadFull(reader, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", "", err
+	}
+
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return "", "", err
+	}
+
+	return sniff.DetectContentType(header[:n]), hex.EncodeToString(hash.Sum(nil)), nil
+}
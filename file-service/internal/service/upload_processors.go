@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"file-service/internal/audiometa"
+	"file-service/internal/domain"
+	"file-service/internal/storage"
+	"file-service/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// Names of the processors uploads can be run through
+const (
+	ProcessorValidation    = "validation"
+	ProcessorMalwareScan   = "malware_scan"
+	ProcessorImageVariants = "image_variants"
+	ProcessorRecord        = "record"
+	ProcessorAudioMetadata = "audio_metadata"
+	ProcessorAudioAnalysis = "audio_analysis"
+)
+
+// NewValidationProcessor rejects files whose content isn't allowed for their file type
+func NewValidationProcessor(validator ContentValidator) Processor {
+	return ProcessorFunc(func(ctx context.Context, upload *Upload) error {
+		return validator.Validate(ctx, upload.Info, upload.ContentType)
+	})
+}
+
+// NewMalwareScanProcessor rejects infected files. Files that can't be scanned
+// fail processing, they must not become downloadable.
+func NewMalwareScanProcessor(scanner MalwareScanner, logger *logger.Logger) Processor {
+	log := logger.WithComponent("malware_scan_processor")
+
+	return ProcessorFunc(func(ctx context.Context, upload *Upload) error {
+		// A file that was scanned clean doesn't need scanning again
+		if upload.Info.Metadata[domain.MetadataScanVerdict] == domain.ScanVerdictClean {
+			return nil
+		}
+
+		verdict, err := scanner.Scan(ctx, upload.Info)
+		if err != nil {
+			if errors.Is(err, domain.ErrMalwareDetected) {
+				return err
+			}
+			log.Error().Err(err).
+				Str("file_id", upload.Info.FileID).
+				Str("file_type", string(upload.Info.FileType)).
+				Msg("Failed to scan uploaded file for malware")
+			return fmt.Errorf("failed to scan uploaded file: %w", err)
+		}
+		if verdict != "" {
+			upload.Metadata[domain.MetadataScanVerdict] = verdict
+		}
+		return nil
+	})
+}
+
+// NewImageVariantProcessor generates the resized variants of images. Variants
+// are best effort, downloads fall back to the original without them.
+func NewImageVariantProcessor(variants ImageVariantService, logger *logger.Logger) Processor {
+	log := logger.WithComponent("image_variant_processor")
+
+	return ProcessorFunc(func(ctx context.Context, upload *Upload) error {
+		if upload.Info.Metadata[domain.MetadataImageVariants] != "" {
+			return nil
+		}
+
+		sizes, err := variants.Generate(ctx, upload.Info.FileID)
+		if err != nil {
+			log.Warn().Err(err).
+				Str("file_id", upload.Info.FileID).
+				Str("file_type", string(upload.Info.FileType)).
+				Msg("Failed to generate image variants")
+		}
+		if len(sizes) > 0 {
+			upload.Metadata[domain.MetadataImageVariants] = formatSizes(sizes)
+		}
+		return nil
+	})
+}
+
+// NewRecordProcessor records the detected content type and the metadata
+// collected by the preceding processors on the file, which completes it. The
+// upload is counted towards the owner's quota the first time.
+func NewRecordProcessor(backend storage.Backend, quota QuotaService) Processor {
+	return ProcessorFunc(func(ctx context.Context, upload *Upload) error {
+		info := upload.Info
+		if err := backend.UpdateMetadata(ctx, info.FileType, info.FileID, upload.ContentType, upload.Metadata); err != nil {
+			return fmt.Errorf("failed to record file metadata: %w", err)
+		}
+
+		if !upload.Reprocessed() {
+			quota.RecordUpload(ctx, info.Metadata[domain.MetadataOwner], info.FileType, info.Size)
+		}
+		return nil
+	})
+}
+
+// NewAudioAnalysisProcessor queues audio files for waveforms and loudness.
+// They are added in the background after the metadata was extracted, the
+// player works without them.
+func NewAudioAnalysisProcessor(analysis AudioAnalysisService) Processor {
+	return ProcessorFunc(func(_ context.Context, upload *Upload) error {
+		if upload.Info.Metadata[domain.MetadataWaveforms] == "" {
+			analysis.Enqueue(upload.Info.FileID)
+		}
+		return nil
+	})
+}
+
+// AudioMetadataProcessor parses audio files and saves their metadata the
+// first time they are processed. Embedded cover art becomes an image file of
+// the same owner, so it can be offered as the default cover. It isn't deleted
+// with the audio file. Metadata is best effort, unparsable files are still
+// valid uploads.
+type AudioMetadataProcessor struct {
+	storage  storage.Backend
+	audio    AudioMetadataService
+	quota    QuotaService
+	pipeline UploadPipeline
+	logger   *logger.Logger
+}
+
+// NewAudioMetadataProcessor returns a processor running cover art through the pipeline like image uploads
+func NewAudioMetadataProcessor(
+	backend storage.Backend,
+	audio AudioMetadataService,
+	quota QuotaService,
+	pipeline UploadPipeline,
+	logger *logger.Logger,
+) *AudioMetadataProcessor {
+	return &AudioMetadataProcessor{
+		storage:  backend,
+		audio:    audio,
+		quota:    quota,
+		pipeline: pipeline,
+		logger:   logger.WithComponent("audio_metadata_processor"),
+	}
+}
+
+func (p *AudioMetadataProcessor) Process(ctx context.Context, upload *Upload) error {
+	if upload.Reprocessed() {
+		return nil
+	}
+
+	if err := p.extract(ctx, upload.Info); err != nil {
+		p.logger.Warn().Err(err).
+			Str("file_id", upload.Info.FileID).
+			Str("file_type", string(upload.Info.FileType)).
+			Msg("Failed to extract audio metadata")
+	}
+	return nil
+}
+
+func (p *AudioMetadataProcessor) extract(ctx context.Context, info *domain.FileInfo) error {
+	metadata, picture, err := p.audio.Extract(ctx, info)
+	if err != nil {
+		return err
+	}
+
+	if picture != nil {
+		coverID, err := p.storeCoverArt(ctx, info, picture)
+		if err != nil {
+			p.logger.Warn().Err(err).
+				Str("file_id", info.FileID).
+				Str("file_type", string(info.FileType)).
+				Msg("Failed to store embedded cover art")
+		}
+		metadata.CoverImageID = coverID
+	}
+
+	return p.audio.Save(ctx, info, metadata)
+}
+
+// storeCoverArt stores the picture as an uploaded image and processes it like
+// one, which checks its content and counts it towards the owner's quota
+func (p *AudioMetadataProcessor) storeCoverArt(ctx context.Context, info *domain.FileInfo, picture *audiometa.Picture) (string, error) {
+	owner := info.Metadata[domain.MetadataOwner]
+	size := int64(len(picture.Data))
+
+	if _, err := p.quota.CheckUpload(ctx, owner, domain.FileTypeImage, size); err != nil {
+		return "", err
+	}
+
+	contentType := picture.MIMEType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	coverID := uuid.New().String()
+	err := p.storage.Put(ctx, domain.FileTypeImage, coverID, bytes.NewReader(picture.Data), size, contentType)
+	if err != nil {
+		return "", fmt.Errorf("failed to store cover art: %w", err)
+	}
+
+	if owner != "" {
+		metadata := map[string]string{domain.MetadataOwner: owner}
+		if err := p.storage.UpdateMetadata(ctx, domain.FileTypeImage, coverID, "", metadata); err != nil {
+			p.deleteCoverArt(ctx, coverID)
+			return "", fmt.Errorf("failed to record cover art owner: %w", err)
+		}
+	}
+
+	if _, err := p.pipeline.Process(ctx, domain.FileTypeImage, coverID); err != nil {
+		// Rejected content has already been quarantined
+		if !errors.Is(err, domain.ErrInvalidFileContent) && !errors.Is(err, domain.ErrMalwareDetected) {
+			p.deleteCoverArt(ctx, coverID)
+		}
+		return "", err
+	}
+
+	return coverID, nil
+}
+
+func (p *AudioMetadataProcessor) deleteCoverArt(ctx context.Context, coverID string) {
+	if err := p.storage.Delete(ctx, domain.FileTypeImage, coverID); err != nil {
+		p.logger.Error().Err(err).
+			Str("file_id", coverID).
+			Str("file_type", string(domain.FileTypeImage)).
+			Msg("Failed to delete cover art")
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/storage"
+	"file-service/internal/waveform"
+	"file-service/pkg/logger"
+)
+
+// WaveformService stores min/max peaks of uploaded audio files for the
+// player's waveform display. The peaks are collected by the audio analysis.
+type WaveformService interface {
+	// NewBuilder returns a builder collecting peaks for the configured resolutions
+	NewBuilder() *waveform.Builder
+	// Store stores the waveforms of the builder and returns their resolutions
+	Store(ctx context.Context, fileID string, builder *waveform.Builder) ([]int, error)
+	// Open returns the waveform with the smallest resolution at least as large
+	// as requested, or the largest one. A zero resolution selects the largest.
+	// It returns domain.ErrFileNotFound if the file has no waveforms.
+	Open(ctx context.Context, fileID string, resolution int) (io.ReadCloser, error)
+	// Delete removes all waveforms of the audio file, it tries every
+	// waveform before returning the errors
+	Delete(ctx context.Context, info *domain.FileInfo) error
+}
+
+type waveformService struct {
+	storage storage.Backend
+	cfg     *config.WaveformConfig
+	logger  *logger.Logger
+}
+
+func NewWaveformService(backend storage.Backend, cfg *config.Config, logger *logger.Logger) WaveformService {
+	return &waveformService{
+		storage: backend,
+		cfg:     &cfg.Waveform,
+		logger:  logger.WithComponent("waveform_service"),
+	}
+}
+
+func (s *waveformService) NewBuilder() *waveform.Builder {
+	return waveform.NewBuilder(slices.Max(s.cfg.Resolutions))
+}
+
+func (s *waveformService) Store(ctx context.Context, fileID string, builder *waveform.Builder) ([]int, error) {
+	var resolutions []int
+	for _, resolution := range s.cfg.Resolutions {
+		data, err := json.Marshal(builder.Waveform(resolution))
+		if err != nil {
+			return resolutions, fmt.Errorf("failed to marshal waveform: %w", err)
+		}
+
+		key := domain.WaveformKey(fileID, resolution)
+		if err := s.storage.Put(ctx, domain.FileTypeAudio, key, bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+			return resolutions, fmt.Errorf("failed to store waveform with resolution %d: %w", resolution, err)
+		}
+		resolutions = append(resolutions, resolution)
+	}
+
+	return resolutions, nil
+}
+
+func (s *waveformService) Open(ctx context.Context, fileID string, resolution int) (io.ReadCloser, error) {
+	info, err := s.storage.Stat(ctx, domain.FileTypeAudio, fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to stat audio file: %w", err)
+	}
+
+	resolutions := metadataSizes(info, domain.MetadataWaveforms)
+	if len(resolutions) == 0 {
+		return nil, domain.ErrFileNotFound
+	}
+
+	selected := resolutions[len(resolutions)-1]
+	if resolution > 0 {
+		if i := slices.IndexFunc(resolutions, func(r int) bool { return r >= resolution }); i >= 0 {
+			selected = resolutions[i]
+		}
+	}
+
+	reader, err := s.storage.Open(ctx, domain.FileTypeAudio, domain.WaveformKey(fileID, selected))
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to open waveform: %w", err)
+	}
+	return reader, nil
+}
+
+func (s *waveformService) Delete(ctx context.Context, info *domain.FileInfo) error {
+	// Configured resolutions are included in case recording the waveforms failed
+	resolutions := append(metadataSizes(info, domain.MetadataWaveforms), s.cfg.Resolutions...)
+	slices.Sort(resolutions)
+
+	var errs []error
+	for _, resolution := range slices.Compact(resolutions) {
+		if err := s.storage.Delete(ctx, domain.FileTypeAudio, domain.WaveformKey(info.FileID, resolution)); err != nil {
+			s.logger.Error().Err(err).
+				Str("file_id", info.FileID).
+				Int("resolution", resolution).
+				Msg("Failed to delete waveform")
+			errs = append(errs, fmt.Errorf("failed to delete waveform %d: %w", resolution, err))
+		}
+	}
+	return errors.Join(errs...)
+}
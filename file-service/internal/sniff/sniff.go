@@ -0,0 +1,107 @@
+package sniff
+
+import (
+	"bytes"
+	"slices"
+
+	"file-service/internal/domain"
+)
+
+// HeaderSize is the number of leading bytes needed to detect any supported format
+const HeaderSize = 512
+
+const unknownContentType = "application/octet-stream"
+
+type signature struct {
+	contentType string
+	match       func(header []byte) bool
+}
+
+var signatures = []signature{
+	// Audio
+	{"audio/mpeg", isMP3},
+	{"audio/flac", prefix([]byte("fLaC"))},
+	{"audio/ogg", prefix([]byte("OggS"))},
+	{"audio/wav", riff([]byte("WAVE"))},
+	{"audio/mp4", isM4A},
+	{"audio/aac", isADTS},
+
+	// Images
+	{"image/jpeg", prefix([]byte{0xFF, 0xD8, 0xFF})},
+	{"image/png", prefix([]byte("\x89PNG\r\n\x1a\n"))},
+	{"image/gif", func(h []byte) bool {
+		return bytes.HasPrefix(h, []byte("GIF87a")) || bytes.HasPrefix(h, []byte("GIF89a"))
+	}},
+	{"image/webp", riff([]byte("WEBP"))},
+}
+
+// allowed lists the formats accepted for each file type
+var allowed = map[domain.FileType][]string{
+	domain.FileTypeAudio: {"audio/mpeg", "audio/flac", "audio/ogg", "audio/wav", "audio/mp4", "audio/aac"},
+	domain.FileTypeImage: {"image/jpeg", "image/png", "image/gif", "image/webp"},
+}
+
+// DetectContentType returns the MIME type of the supported format the header
+// starts with, or "application/octet-stream" if none matches
+func DetectContentType(header []byte) string {
+	for _, sig := range signatures {
+		if sig.match(header) {
+			return sig.contentType
+		}
+	}
+	return unknownContentType
+}
+
+// MatchesFileType reports whether the detected content type is allowed for the file type
+func MatchesFileType(fileType domain.FileType, contentType string) bool {
+	return slices.Contains(allowed[fileType], contentType)
+}
+
+func prefix(magic []byte) func([]byte) bool {
+	return func(h []byte) bool {
+		return bytes.HasPrefix(h, magic)
+	}
+}
+
+func riff(format []byte) func([]byte) bool {
+	return func(h []byte) bool {
+		return len(h) >= 12 && bytes.Equal(h[:4], []byte("RIFF")) && bytes.Equal(h[8:12], format)
+	}
+}
+
+// isMP3 accepts an ID3v2 tag or a bare MPEG audio frame sync
+func isMP3(h []byte) bool {
+	if bytes.HasPrefix(h, []byte("ID3")) {
+		return true
+	}
+	if len(h) < 2 || h[0] != 0xFF || h[1]&0xE0 != 0xE0 {
+		return false
+	}
+	// Layer bits 00 are reserved
+	return h[1]&0x06 != 0
+}
+
+// isADTS accepts a raw AAC stream, which starts with the frame sync of an
+// ADTS header. Its layer bits are always 00, the ones MP3 reserves.
+func isADTS(h []byte) bool {
+	if len(h) < 3 || h[0] != 0xFF || h[1]&0xF6 != 0xF0 {
+		return false
+	}
+	// Sampling frequency indexes 13 to 15 are reserved
+	return (h[2]>>2)&0x0F < 13
+}
+
+// isM4A accepts ISO base media files with an audio brand. Generic brands like
+// isom or mp42 are shared with MP4 video, and the tracks telling them apart
+// are rarely within the header, so they are rejected.
+func isM4A(h []byte) bool {
+	if len(h) < 12 || !bytes.Equal(h[4:8], []byte("ftyp")) {
+		return false
+	}
+	switch string(h[8:12]) {
+	case "M4A ", "M4B ", "M4P ":
+		return true
+	default:
+		return false
+	}
+}
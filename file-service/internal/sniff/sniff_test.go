@@ -0,0 +1,73 @@
+package sniff
+
+import (
+	"testing"
+
+	"file-service/internal/domain"
+)
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"mp3 with ID3 tag", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), "audio/mpeg"},
+		{"mp3 frame", []byte{0xFF, 0xFB, 0x90, 0x00}, "audio/mpeg"},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), "audio/flac"},
+		{"ogg", []byte("OggS\x00\x02"), "audio/ogg"},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), "audio/wav"},
+		{"m4a", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00"), "audio/mp4"},
+		{"aac", []byte{0xFF, 0xF1, 0x50, 0x80}, "audio/aac"},
+		{"aac of MPEG-2", []byte{0xFF, 0xF9, 0x50, 0x80}, "audio/aac"},
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, "image/jpeg"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00"), "image/png"},
+		{"gif", []byte("GIF89a\x01\x00"), "image/gif"},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), "image/webp"},
+
+		{"empty", nil, unknownContentType},
+		{"text", []byte("hello world"), unknownContentType},
+		{"truncated frame sync", []byte{0xFF}, unknownContentType},
+		{"truncated aac header", []byte{0xFF, 0xF1}, unknownContentType},
+		{"frame sync with reserved layer", []byte{0xFF, 0xE1, 0x50}, unknownContentType},
+		{"aac with reserved sampling frequency", []byte{0xFF, 0xF1, 0x3C, 0x80}, unknownContentType},
+		{"truncated riff", []byte("RIFF\x24\x00\x00\x00WAV"), unknownContentType},
+		{"riff of another format", []byte("RIFF\x24\x00\x00\x00AVI LIST"), unknownContentType},
+		{"truncated ftyp", []byte("\x00\x00\x00\x20ftypM4"), unknownContentType},
+		{"mp4 video", []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00"), unknownContentType},
+		{"truncated png", []byte("\x89PNG\r\n"), unknownContentType},
+		{"gif of unknown version", []byte("GIF88a\x01\x00"), unknownContentType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectContentType(tt.header); got != tt.want {
+				t.Errorf("DetectContentType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchesFileType(t *testing.T) {
+	tests := []struct {
+		fileType    domain.FileType
+		contentType string
+		want        bool
+	}{
+		{domain.FileTypeAudio, "audio/mpeg", true},
+		{domain.FileTypeAudio, "audio/aac", true},
+		{domain.FileTypeImage, "image/webp", true},
+		{domain.FileTypeAudio, "image/png", false},
+		{domain.FileTypeImage, "audio/ogg", false},
+		{domain.FileTypeAudio, unknownContentType, false},
+		{"video", "audio/mpeg", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.fileType)+" "+tt.contentType, func(t *testing.T) {
+			if got := MatchesFileType(tt.fileType, tt.contentType); got != tt.want {
+				t.Errorf("MatchesFileType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,25 +8,92 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// NewURLCache creates a cache implementation based on configuration
-func NewURLCache(cfg *config.CacheConfig, log *logger.Logger) (URLCache, error) {
-	if cfg.Type == "redis" {
-		redisClient := redis.NewClient(&redis.Options{
-			Addr:     cfg.Redis.Addr,
-			Password: cfg.Redis.Password,
-			DB:       cfg.Redis.DB,
-		})
+// NewRedisClient connects to Redis when it is the configured cache type.
+// For any other type it returns nil, which makes the factories below fall
+// back to in-memory implementations.
+func NewRedisClient(cfg *config.CacheConfig, log *logger.Logger) (*redis.Client, error) {
+	if cfg.Type != "redis" {
+		return nil, nil
+	}
 
-		// Test connection
-		if err := redisClient.Ping(context.Background()).Err(); err != nil {
-			return nil, err
-		}
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	})
+
+	// Test connection
+	if err := redisClient.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
 
-		log.Info().Str("type", "redis").Str("addr", cfg.Redis.Addr).Msg("Redis cache initialized")
-		return newRedisCache(redisClient, cfg.Redis.KeyPrefix), nil
+	log.Info().Str("type", "redis").Str("addr", cfg.Redis.Addr).Msg("Redis connection established")
+	return redisClient, nil
+}
+
+// NewURLCache creates a cache implementation based on configuration
+func NewURLCache(cfg *config.CacheConfig, redisClient *redis.Client, log *logger.Logger) URLCache {
+	if redisClient != nil {
+		log.Info().Str("type", "redis").Msg("Redis cache initialized")
+		return newRedisCache(redisClient, cfg.Redis.KeyPrefix)
 	}
 
 	// Default to in-memory cache
 	log.Info().Str("type", "memory").Msg("In-memory cache initialized")
-	return newMemoryCache(), nil
+	return newMemoryCache()
+}
+
+// NewUploadStore creates a resumable upload state store based on configuration
+func NewUploadStore(cfg *config.CacheConfig, redisClient *redis.Client, log *logger.Logger) UploadStore {
+	if redisClient != nil {
+		log.Info().Str("type", "redis").Msg("Redis upload store initialized")
+		return newRedisUploadStore(redisClient, cfg.Redis.UploadKeyPrefix)
+	}
+
+	log.Info().Str("type", "memory").Msg("In-memory upload store initialized")
+	return newMemoryUploadStore()
+}
+
+// NewUsageStore creates a per-owner storage usage store based on configuration
+func NewUsageStore(cfg *config.CacheConfig, redisClient *redis.Client, log *logger.Logger) UsageStore {
+	if redisClient != nil {
+		log.Info().Str("type", "redis").Msg("Redis usage store initialized")
+		return newRedisUsageStore(redisClient, cfg.Redis.UsageKeyPrefix)
+	}
+
+	log.Info().Str("type", "memory").Msg("In-memory usage store initialized")
+	return newMemoryUsageStore()
+}
+
+// NewFingerprintStore creates an audio fingerprint store based on configuration
+func NewFingerprintStore(cfg *config.CacheConfig, redisClient *redis.Client, log *logger.Logger) FingerprintStore {
+	if redisClient != nil {
+		log.Info().Str("type", "redis").Msg("Redis fingerprint store initialized")
+		return newRedisFingerprintStore(redisClient, cfg.Redis.FingerprintKeyPrefix)
+	}
+
+	log.Info().Str("type", "memory").Msg("In-memory fingerprint store initialized")
+	return newMemoryFingerprintStore()
+}
+
+// NewProcessingStore creates an upload processing status store based on configuration
+func NewProcessingStore(cfg *config.CacheConfig, redisClient *redis.Client, log *logger.Logger) ProcessingStore {
+	if redisClient != nil {
+		log.Info().Str("type", "redis").Msg("Redis processing store initialized")
+		return newRedisProcessingStore(redisClient, cfg.Redis.ProcessingKeyPrefix)
+	}
+
+	log.Info().Str("type", "memory").Msg("In-memory processing store initialized")
+	return newMemoryProcessingStore()
+}
+
+// NewMultipartStore creates a multipart upload owner store based on configuration
+func NewMultipartStore(cfg *config.CacheConfig, redisClient *redis.Client, log *logger.Logger) MultipartStore {
+	if redisClient != nil {
+		log.Info().Str("type", "redis").Msg("Redis multipart store initialized")
+		return newRedisMultipartStore(redisClient, cfg.Redis.MultipartKeyPrefix)
+	}
+
+	log.Info().Str("type", "memory").Msg("In-memory multipart store initialized")
+	return newMemoryMultipartStore()
 }
@@ -0,0 +1,19 @@
+package cache
+
+import (
+	"context"
+)
+
+// FingerprintStore defines the interface for keeping audio fingerprints with
+// an index of their lookup hashes
+type FingerprintStore interface {
+	// Add stores the fingerprint of the file and indexes the file under the hashes
+	Add(ctx context.Context, fileID string, fingerprint []uint32, hashes []uint32) error
+	// Get returns the fingerprint of the file, or nil if there is none
+	Get(ctx context.Context, fileID string) ([]uint32, error)
+	// Match returns up to limit files indexed under the most of the hashes,
+	// with the number of hashes each of them is indexed under
+	Match(ctx context.Context, hashes []uint32, limit int) (map[string]int, error)
+	// Remove deletes the fingerprint of the file and its index entries
+	Remove(ctx context.Context, fileID string, hashes []uint32) error
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+type memoryFingerprintStore struct {
+	mu           sync.RWMutex
+	fingerprints map[string][]uint32
+	index        map[uint32]map[string]struct{}
+}
+
+func newMemoryFingerprintStore() *memoryFingerprintStore {
+	return &memoryFingerprintStore{
+		fingerprints: make(map[string][]uint32),
+		index:        make(map[uint32]map[string]struct{}),
+	}
+}
+
+func (s *memoryFingerprintStore) Add(_ context.Context, fileID string, fingerprint []uint32, hashes []uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fingerprints[fileID] = slices.Clone(fingerprint)
+	for _, hash := range hashes {
+		files, exists := s.index[hash]
+		if !exists {
+			files = make(map[string]struct{})
+			s.index[hash] = files
+		}
+		files[fileID] = struct{}{}
+	}
+	return nil
+}
+
+func (s *memoryFingerprintStore) Get(_ context.Context, fileID string) ([]uint32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.fingerprints[fileID]), nil
+}
+
+func (s *memoryFingerprintStore) Match(_ context.Context, hashes []uint32, limit int) (map[string]int, error) {
+	s.mu.RLock()
+	counts := make(map[string]int)
+	for _, hash := range hashes {
+		for fileID := range s.index[hash] {
+			counts[fileID]++
+		}
+	}
+	s.mu.RUnlock()
+
+	fileIDs := make([]string, 0, len(counts))
+	for fileID := range counts {
+		fileIDs = append(fileIDs, fileID)
+	}
+	slices.SortFunc(fileIDs, func(a, b string) int {
+		return counts[b] - counts[a]
+	})
+
+	result := make(map[string]int, min(limit, len(fileIDs)))
+	for _, fileID := range fileIDs[:min(limit, len(fileIDs))] {
+		result[fileID] = counts[fileID]
+	}
+	return result, nil
+}
+
+func (s *memoryFingerprintStore) Remove(_ context.Context, fileID string, hashes []uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.fingerprints, fileID)
+	for _, hash := range hashes {
+		delete(s.index[hash], fileID)
+		if len(s.index[hash]) == 0 {
+			delete(s.index, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"file-service/internal/domain"
+)
+
+type memoryMultipartEntry struct {
+	upload    domain.MultipartUpload
+	expiresAt time.Time
+}
+
+type memoryMultipartStore struct {
+	mu      sync.Mutex
+	uploads map[string]*memoryMultipartEntry
+}
+
+func newMemoryMultipartStore() *memoryMultipartStore {
+	return &memoryMultipartStore{
+		uploads: make(map[string]*memoryMultipartEntry),
+	}
+}
+
+func (s *memoryMultipartStore) Get(_ context.Context, uploadID string) (*domain.MultipartUpload, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.uploads[uploadID]
+	if !exists {
+		return nil, false, nil
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(s.uploads, uploadID)
+		return nil, false, nil
+	}
+
+	upload := entry.upload
+	return &upload, true, nil
+}
+
+func (s *memoryMultipartStore) Save(_ context.Context, upload *domain.MultipartUpload, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.uploads[upload.UploadID] = &memoryMultipartEntry{
+		upload:    *upload,
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (s *memoryMultipartStore) Delete(_ context.Context, uploadID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.uploads, uploadID)
+	return nil
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"file-service/internal/domain"
+)
+
+type memoryProcessingEntry struct {
+	status    domain.ProcessingStatus
+	expiresAt time.Time
+}
+
+type memoryProcessingStore struct {
+	mu       sync.Mutex
+	statuses map[string]*memoryProcessingEntry
+	locks    map[string]time.Time
+}
+
+func newMemoryProcessingStore() *memoryProcessingStore {
+	return &memoryProcessingStore{
+		statuses: make(map[string]*memoryProcessingEntry),
+		locks:    make(map[string]time.Time),
+	}
+}
+
+func (s *memoryProcessingStore) Get(_ context.Context, fileID string) (*domain.ProcessingStatus, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.statuses[fileID]
+	if !exists {
+		return nil, false, nil
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(s.statuses, fileID)
+		return nil, false, nil
+	}
+
+	status := entry.status
+	return &status, true, nil
+}
+
+func (s *memoryProcessingStore) Save(_ context.Context, status *domain.ProcessingStatus, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.statuses[status.FileID] = &memoryProcessingEntry{
+		status:    *status,
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (s *memoryProcessingStore) Lock(_ context.Context, fileID string, ttl time.Duration) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if expiresAt, locked := s.locks[fileID]; locked && now.Before(expiresAt) {
+		return nil, ErrLocked
+	}
+
+	lockExpiresAt := now.Add(ttl)
+	s.locks[fileID] = lockExpiresAt
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// Only release the lock if it hasn't expired and been taken over
+		if s.locks[fileID] == lockExpiresAt {
+			delete(s.locks, fileID)
+		}
+	}, nil
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"file-service/internal/domain"
+)
+
+type memoryUploadEntry struct {
+	upload    domain.TusUpload
+	expiresAt time.Time
+}
+
+type memoryUploadStore struct {
+	mu      sync.Mutex
+	uploads map[string]*memoryUploadEntry
+	locks   map[string]time.Time
+}
+
+func newMemoryUploadStore() *memoryUploadStore {
+	return &memoryUploadStore{
+		uploads: make(map[string]*memoryUploadEntry),
+		locks:   make(map[string]time.Time),
+	}
+}
+
+func (s *memoryUploadStore) Get(_ context.Context, fileID string) (*domain.TusUpload, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.uploads[fileID]
+	if !exists {
+		return nil, false, nil
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(s.uploads, fileID)
+		return nil, false, nil
+	}
+
+	// Return a copy so callers can't mutate the stored state
+	upload := entry.upload
+	upload.Parts = append([]domain.CompletedPart(nil), entry.upload.Parts...)
+	return &upload, true, nil
+}
+
+func (s *memoryUploadStore) Save(_ context.Context, upload *domain.TusUpload, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *upload
+	stored.Parts = append([]domain.CompletedPart(nil), upload.Parts...)
+
+	s.uploads[upload.FileID] = &memoryUploadEntry{
+		upload:    stored,
+		expiresAt: time.Now().Add(ttl),
+	}
+	return nil
+}
+
+func (s *memoryUploadStore) Delete(_ context.Context, fileID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.uploads, fileID)
+	return nil
+}
+
+func (s *memoryUploadStore) Lock(_ context.Context, fileID string, ttl time.Duration) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if expiresAt, locked := s.locks[fileID]; locked && now.Before(expiresAt) {
+		return nil, ErrLocked
+	}
+
+	lockExpiresAt := now.Add(ttl)
+	s.locks[fileID] = lockExpiresAt
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		// Only release the lock if it hasn't expired and been taken over
+		if s.locks[fileID] == lockExpiresAt {
+			delete(s.locks, fileID)
+		}
+	}, nil
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"maps"
+	"sync"
+
+	"file-service/internal/domain"
+)
+
+type memoryUsageStore struct {
+	mu    sync.Mutex
+	usage map[string]map[domain.FileType]int64
+}
+
+func newMemoryUsageStore() *memoryUsageStore {
+	return &memoryUsageStore{
+		usage: make(map[string]map[domain.FileType]int64),
+	}
+}
+
+func (s *memoryUsageStore) Get(_ context.Context, ownerID string) (map[domain.FileType]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usage := make(map[domain.FileType]int64, len(s.usage[ownerID]))
+	maps.Copy(usage, s.usage[ownerID])
+	return usage, nil
+}
+
+func (s *memoryUsageStore) Add(_ context.Context, ownerID string, fileType domain.FileType, delta int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usage, exists := s.usage[ownerID]
+	if !exists {
+		usage = make(map[domain.FileType]int64)
+		s.usage[ownerID] = usage
+	}
+
+	usage[fileType] = max(usage[fileType]+delta, 0)
+	return usage[fileType], nil
+}
@@ -0,0 +1,15 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"file-service/internal/domain"
+)
+
+// MultipartStore persists who initiated multipart uploads, keyed by upload ID
+type MultipartStore interface {
+	Get(ctx context.Context, uploadID string) (*domain.MultipartUpload, bool, error)
+	Save(ctx context.Context, upload *domain.MultipartUpload, ttl time.Duration) error
+	Delete(ctx context.Context, uploadID string) error
+}
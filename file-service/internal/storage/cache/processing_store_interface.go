@@ -0,0 +1,18 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"file-service/internal/domain"
+)
+
+// ProcessingStore persists the processing status of uploaded files and
+// serializes their processing between replicas
+type ProcessingStore interface {
+	Get(ctx context.Context, fileID string) (*domain.ProcessingStatus, bool, error)
+	Save(ctx context.Context, status *domain.ProcessingStatus, ttl time.Duration) error
+	// Lock acquires an exclusive lock on the processing of the file and
+	// returns the function releasing it, it returns ErrLocked if it is held
+	Lock(ctx context.Context, fileID string, ttl time.Duration) (func(), error)
+}
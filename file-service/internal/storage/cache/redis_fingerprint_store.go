@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisFingerprintStore keeps each fingerprint as a binary string and indexes
+// files in a sorted set per hash. Every member scores 1, so the union of the
+// sets of a fingerprint's hashes scores files by the number of shared hashes.
+type RedisFingerprintStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+func newRedisFingerprintStore(client *redis.Client, keyPrefix string) *RedisFingerprintStore {
+	return &RedisFingerprintStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (r *RedisFingerprintStore) Add(ctx context.Context, fileID string, fingerprint []uint32, hashes []uint32) error {
+	data := make([]byte, 0, 4*len(fingerprint))
+	for _, value := range fingerprint {
+		data = binary.LittleEndian.AppendUint32(data, value)
+	}
+
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.fingerprintKey(fileID), data, 0)
+		for _, hash := range hashes {
+			pipe.ZAdd(ctx, r.hashKey(hash), redis.Z{Score: 1, Member: fileID})
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisFingerprintStore) Get(ctx context.Context, fileID string) ([]uint32, error) {
+	data, err := r.client.Get(ctx, r.fingerprintKey(fileID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := make([]uint32, len(data)/4)
+	for i := range fingerprint {
+		fingerprint[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	return fingerprint, nil
+}
+
+func (r *RedisFingerprintStore) Match(ctx context.Context, hashes []uint32, limit int) (map[string]int, error) {
+	if len(hashes) == 0 {
+		return map[string]int{}, nil
+	}
+
+	keys := make([]string, len(hashes))
+	for i, hash := range hashes {
+		keys[i] = r.hashKey(hash)
+	}
+
+	// The union is stored under a key of its own, deleted in the same transaction
+	unionKey := r.keyPrefix + "match:" + uuid.New().String()
+	var matches *redis.ZSliceCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZUnionStore(ctx, unionKey, &redis.ZStore{Keys: keys})
+		matches = pipe.ZRevRangeWithScores(ctx, unionKey, 0, int64(limit-1))
+		pipe.Del(ctx, unionKey)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int, len(matches.Val()))
+	for _, match := range matches.Val() {
+		result[match.Member.(string)] = int(match.Score)
+	}
+	return result, nil
+}
+
+func (r *RedisFingerprintStore) Remove(ctx context.Context, fileID string, hashes []uint32) error {
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, hash := range hashes {
+			pipe.ZRem(ctx, r.hashKey(hash), fileID)
+		}
+		pipe.Del(ctx, r.fingerprintKey(fileID))
+		return nil
+	})
+	return err
+}
+
+func (r *RedisFingerprintStore) fingerprintKey(fileID string) string {
+	return r.keyPrefix + "file:" + fileID
+}
+
+func (r *RedisFingerprintStore) hashKey(hash uint32) string {
+	return r.keyPrefix + "hash:" + strconv.FormatUint(uint64(hash), 16)
+}
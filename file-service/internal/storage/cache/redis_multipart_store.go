@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"file-service/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisMultipartStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+func newRedisMultipartStore(client *redis.Client, keyPrefix string) *RedisMultipartStore {
+	return &RedisMultipartStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (r *RedisMultipartStore) Get(ctx context.Context, uploadID string) (*domain.MultipartUpload, bool, error) {
+	val, err := r.client.Get(ctx, r.keyPrefix+uploadID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var upload domain.MultipartUpload
+	if err := json.Unmarshal([]byte(val), &upload); err != nil {
+		return nil, false, err
+	}
+
+	return &upload, true, nil
+}
+
+func (r *RedisMultipartStore) Save(ctx context.Context, upload *domain.MultipartUpload, ttl time.Duration) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, r.keyPrefix+upload.UploadID, data, ttl).Err()
+}
+
+func (r *RedisMultipartStore) Delete(ctx context.Context, uploadID string) error {
+	return r.client.Del(ctx, r.keyPrefix+uploadID).Err()
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"file-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type RedisProcessingStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+func newRedisProcessingStore(client *redis.Client, keyPrefix string) *RedisProcessingStore {
+	return &RedisProcessingStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (r *RedisProcessingStore) Get(ctx context.Context, fileID string) (*domain.ProcessingStatus, bool, error) {
+	val, err := r.client.Get(ctx, r.keyPrefix+fileID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var status domain.ProcessingStatus
+	if err := json.Unmarshal([]byte(val), &status); err != nil {
+		return nil, false, err
+	}
+
+	return &status, true, nil
+}
+
+func (r *RedisProcessingStore) Save(ctx context.Context, status *domain.ProcessingStatus, ttl time.Duration) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, r.keyPrefix+status.FileID, data, ttl).Err()
+}
+
+func (r *RedisProcessingStore) Lock(ctx context.Context, fileID string, ttl time.Duration) (func(), error) {
+	key := r.keyPrefix + fileID + ":lock"
+	token := uuid.New().String()
+
+	acquired, err := r.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrLocked
+	}
+
+	return func() {
+		// Use a fresh context: the request context may already be canceled
+		_ = unlockScript.Run(context.Background(), r.client, []string{key}, token).Err()
+	}, nil
+}
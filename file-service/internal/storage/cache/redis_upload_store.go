@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"file-service/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// unlockScript deletes the lock only if it is still owned by the caller
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type RedisUploadStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+func newRedisUploadStore(client *redis.Client, keyPrefix string) *RedisUploadStore {
+	return &RedisUploadStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (r *RedisUploadStore) Get(ctx context.Context, fileID string) (*domain.TusUpload, bool, error) {
+	val, err := r.client.Get(ctx, r.keyPrefix+fileID).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var upload domain.TusUpload
+	if err := json.Unmarshal([]byte(val), &upload); err != nil {
+		return nil, false, err
+	}
+
+	return &upload, true, nil
+}
+
+func (r *RedisUploadStore) Save(ctx context.Context, upload *domain.TusUpload, ttl time.Duration) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Set(ctx, r.keyPrefix+upload.FileID, data, ttl).Err()
+}
+
+func (r *RedisUploadStore) Delete(ctx context.Context, fileID string) error {
+	return r.client.Del(ctx, r.keyPrefix+fileID).Err()
+}
+
+func (r *RedisUploadStore) Lock(ctx context.Context, fileID string, ttl time.Duration) (func(), error) {
+	key := r.keyPrefix + fileID + ":lock"
+	token := uuid.New().String()
+
+	acquired, err := r.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrLocked
+	}
+
+	return func() {
+		// Use a fresh context: the request context may already be canceled
+		_ = unlockScript.Run(context.Background(), r.client, []string{key}, token).Err()
+	}, nil
+}
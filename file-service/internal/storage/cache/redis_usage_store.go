@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+
+	"file-service/internal/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// addUsageScript increments the usage field and clamps it at zero, so a
+// deletion of a file uploaded before tracking started can't go negative
+var addUsageScript = redis.NewScript(`
+local usage = redis.call("HINCRBY", KEYS[1], ARGV[1], ARGV[2])
+if usage < 0 then
+	redis.call("HSET", KEYS[1], ARGV[1], 0)
+	return 0
+end
+return usage
+`)
+
+// RedisUsageStore keeps the usage of each owner in a hash keyed by file type
+type RedisUsageStore struct {
+	client    *redis.Client
+	keyPrefix string
+}
+
+func newRedisUsageStore(client *redis.Client, keyPrefix string) *RedisUsageStore {
+	return &RedisUsageStore{
+		client:    client,
+		keyPrefix: keyPrefix,
+	}
+}
+
+func (r *RedisUsageStore) Get(ctx context.Context, ownerID string) (map[domain.FileType]int64, error) {
+	fields, err := r.client.HGetAll(ctx, r.keyPrefix+ownerID).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[domain.FileType]int64, len(fields))
+	for fileType, value := range fields {
+		used, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		usage[domain.FileType(fileType)] = used
+	}
+	return usage, nil
+}
+
+func (r *RedisUsageStore) Add(ctx context.Context, ownerID string, fileType domain.FileType, delta int64) (int64, error) {
+	return addUsageScript.Run(ctx, r.client, []string{r.keyPrefix + ownerID}, string(fileType), delta).Int64()
+}
@@ -0,0 +1,22 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"file-service/internal/domain"
+)
+
+// ErrLocked is returned when another request holds the upload lock
+var ErrLocked = errors.New("upload is locked")
+
+// UploadStore defines the interface for persisting resumable upload state
+// so that any replica can continue an upload
+type UploadStore interface {
+	Get(ctx context.Context, fileID string) (*domain.TusUpload, bool, error)
+	Save(ctx context.Context, upload *domain.TusUpload, ttl time.Duration) error
+	Delete(ctx context.Context, fileID string) error
+	// Lock acquires an exclusive lock on the upload and returns the function releasing it
+	Lock(ctx context.Context, fileID string, ttl time.Duration) (func(), error)
+}
@@ -0,0 +1,16 @@
+package cache
+
+import (
+	"context"
+
+	"file-service/internal/domain"
+)
+
+// UsageStore defines the interface for tracking the storage used by each owner
+type UsageStore interface {
+	// Get returns the bytes used per file type, missing file types are unused
+	Get(ctx context.Context, ownerID string) (map[domain.FileType]int64, error)
+	// Add adjusts the usage by delta, which is negative for deletions, and
+	// returns the new usage. Usage never drops below zero.
+	Add(ctx context.Context, ownerID string, fileType domain.FileType, delta int64) (int64, error)
+}
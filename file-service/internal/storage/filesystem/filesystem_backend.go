@@ -0,0 +1,378 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/pkg/logger"
+)
+
+const (
+	objectsDir  = "objects"
+	metadataDir = "meta"
+)
+
+// objectMetadata is stored in a JSON sidecar file next to each object
+type objectMetadata struct {
+	ContentType string            `json:"content_type"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// uploadPolicy restricts a signed form upload, like an S3 POST policy
+type uploadPolicy struct {
+	FileType          domain.FileType `json:"file_type"`
+	Key               string          `json:"key"`
+	Expires           int64           `json:"expires"`
+	MinSize           int64           `json:"min_size"`
+	MaxSize           int64           `json:"max_size"`
+	ContentType       string          `json:"content_type,omitempty"`
+	Owner             string          `json:"owner,omitempty"`
+	ContentTypePrefix string          `json:"content_type_prefix,omitempty"`
+}
+
+// Backend keeps files on the local filesystem, one directory per file type.
+// Uploads and downloads go through HMAC-signed URLs served by ServeHTTP.
+type Backend struct {
+	cfg           *config.FilesystemConfig
+	limits        *config.UploadLimitsConfig
+	presignExpiry time.Duration
+	logger        *logger.Logger
+}
+
+func NewBackend(
+	cfg *config.FilesystemConfig,
+	limits *config.UploadLimitsConfig,
+	presignExpiry time.Duration,
+	log *logger.Logger,
+) (*Backend, error) {
+	if cfg.SigningKey == "" {
+		return nil, errors.New("signing key is required for the filesystem storage")
+	}
+
+	for _, fileType := range []domain.FileType{domain.FileTypeImage, domain.FileTypeAudio, domain.FileTypeQuarantine} {
+		for _, dir := range []string{objectsDir, metadataDir} {
+			if err := os.MkdirAll(filepath.Join(cfg.Root, string(fileType), dir), 0o750); err != nil {
+				return nil, fmt.Errorf("failed to create storage directory: %w", err)
+			}
+		}
+	}
+
+	return &Backend{
+		cfg:           cfg,
+		limits:        limits,
+		presignExpiry: presignExpiry,
+		logger:        log.WithComponent("filesystem_storage"),
+	}, nil
+}
+
+func (b *Backend) PresignUpload(
+	_ context.Context,
+	req domain.UploadRequest,
+	fileID string,
+) (*domain.PresignedPostResponse, error) {
+	maxSize, contentTypes := b.limits.ForFileType(string(req.FileType))
+	if err := req.Validate(maxSize, contentTypes); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(b.presignExpiry)
+	policy := uploadPolicy{
+		FileType: req.FileType,
+		Key:      fileID,
+		Expires:  expiresAt.Unix(),
+		MinSize:  1,
+		MaxSize:  req.MaxSize(maxSize),
+		Owner:    req.OwnerID,
+	}
+
+	// Tighten the policy to the declared values when the client provided them
+	if req.Size > 0 {
+		policy.MinSize, policy.MaxSize = req.Size, req.Size
+	}
+	if req.ContentType != "" {
+		policy.ContentType = req.ContentType
+	} else {
+		policy.ContentTypePrefix = string(req.FileType) + "/"
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode upload policy: %w", err)
+	}
+	encodedPolicy := base64.RawURLEncoding.EncodeToString(policyJSON)
+
+	return &domain.PresignedPostResponse{
+		URL: b.cfg.PublicURL + "/" + string(req.FileType),
+		FormData: map[string]string{
+			"key":       fileID,
+			"policy":    encodedPolicy,
+			"signature": b.sign(encodedPolicy),
+		},
+		ExpiresAt: expiresAt,
+		FileID:    fileID,
+	}, nil
+}
+
+func (b *Backend) PresignDownload(
+	_ context.Context,
+	fileType domain.FileType,
+	fileID string,
+	isInternalRequest bool,
+) (*domain.PresignedURLResponse, error) {
+	if !filepath.IsLocal(fileID) {
+		return nil, domain.ErrFileNotFound
+	}
+
+	baseURL := b.cfg.PublicURL
+	if isInternalRequest {
+		baseURL = b.cfg.InternalURL
+	}
+
+	expiresAt := time.Now().Add(b.presignExpiry)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", b.sign(downloadStringToSign(fileType, fileID, expires)))
+
+	return &domain.PresignedURLResponse{
+		URL:       baseURL + "/" + string(fileType) + "/" + fileID + "?" + query.Encode(),
+		ExpiresAt: expiresAt,
+		FileID:    fileID,
+	}, nil
+}
+
+func (b *Backend) Stat(_ context.Context, fileType domain.FileType, fileID string) (*domain.FileInfo, error) {
+	objectPath, metaPath, err := b.paths(fileType, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(objectPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, domain.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	meta, err := readMetadata(metaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.FileInfo{
+		FileID:       fileID,
+		FileType:     fileType,
+		Size:         info.Size(),
+		ContentType:  meta.ContentType,
+		Checksum:     meta.Metadata[domain.MetadataChecksum],
+		LastModified: info.ModTime(),
+		Metadata:     meta.Metadata,
+	}, nil
+}
+
+func (b *Backend) Open(_ context.Context, fileType domain.FileType, fileID string) (io.ReadCloser, error) {
+	objectPath, _, err := b.paths(fileType, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(objectPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, domain.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return file, nil
+}
+
+func (b *Backend) Put(
+	_ context.Context,
+	fileType domain.FileType,
+	fileID string,
+	data io.Reader,
+	size int64,
+	contentType string,
+) error {
+	objectPath, metaPath, err := b.paths(fileType, fileID)
+	if err != nil {
+		return err
+	}
+
+	written, err := writeFileAtomic(objectPath, data)
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if size >= 0 && written != size {
+		_ = os.Remove(objectPath)
+		return fmt.Errorf("file size %d does not match declared size %d", written, size)
+	}
+
+	if err := writeMetadata(metaPath, &objectMetadata{ContentType: contentType}); err != nil {
+		return fmt.Errorf("failed to write file metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Backend) UpdateMetadata(
+	_ context.Context,
+	fileType domain.FileType,
+	fileID string,
+	contentType string,
+	metadata map[string]string,
+) error {
+	objectPath, metaPath, err := b.paths(fileType, fileID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(objectPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return domain.ErrFileNotFound
+		}
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	meta, err := readMetadata(metaPath)
+	if err != nil {
+		return err
+	}
+
+	if meta.Metadata == nil {
+		meta.Metadata = make(map[string]string, len(metadata))
+	}
+	maps.Copy(meta.Metadata, metadata)
+	if contentType != "" {
+		meta.ContentType = contentType
+	}
+
+	if err := writeMetadata(metaPath, meta); err != nil {
+		return fmt.Errorf("failed to write file metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Backend) Delete(_ context.Context, fileType domain.FileType, fileID string) error {
+	objectPath, metaPath, err := b.paths(fileType, fileID)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{objectPath, metaPath} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// paths returns the object and metadata paths, rejecting keys escaping the storage root
+func (b *Backend) paths(fileType domain.FileType, fileID string) (string, string, error) {
+	switch fileType {
+	case domain.FileTypeImage, domain.FileTypeAudio, domain.FileTypeQuarantine:
+	default:
+		return "", "", domain.ErrFileNotFound
+	}
+	if !filepath.IsLocal(fileID) {
+		return "", "", domain.ErrFileNotFound
+	}
+
+	dir := filepath.Join(b.cfg.Root, string(fileType))
+	return filepath.Join(dir, objectsDir, fileID), filepath.Join(dir, metadataDir, fileID+".json"), nil
+}
+
+func (b *Backend) sign(value string) string {
+	mac := hmac.New(sha256.New, []byte(b.cfg.SigningKey))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (b *Backend) verify(value string, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(b.cfg.SigningKey))
+	mac.Write([]byte(value))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func downloadStringToSign(fileType domain.FileType, fileID string, expires string) string {
+	return strings.Join([]string{"GET", string(fileType), fileID, expires}, "\n")
+}
+
+func readMetadata(path string) (*objectMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &objectMetadata{}, nil
+		}
+		return nil, fmt.Errorf("failed to read file metadata: %w", err)
+	}
+
+	var meta objectMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to decode file metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+func writeMetadata(path string, meta *objectMetadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	_, err = writeFileAtomic(path, strings.NewReader(string(data)))
+	return err
+}
+
+// writeFileAtomic writes to a temporary file renamed over the target, so
+// readers never observe a partially written file
+func writeFileAtomic(path string, data io.Reader) (int64, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, data)
+	if err != nil {
+		tmp.Close()
+		return written, err
+	}
+	if err := tmp.Close(); err != nil {
+		return written, err
+	}
+
+	return written, os.Rename(tmp.Name(), path)
+}
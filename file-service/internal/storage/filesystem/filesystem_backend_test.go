@@ -0,0 +1,291 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/pkg/logger"
+)
+
+func newTestBackend(t *testing.T) *Backend {
+	t.Helper()
+
+	backend, err := NewBackend(
+		&config.FilesystemConfig{
+			Root:        filepath.Join(t.TempDir(), "storage"),
+			PublicURL:   "http://localhost/storage",
+			InternalURL: "http://file-service/storage",
+			SigningKey:  "test-signing-key",
+		},
+		&config.UploadLimitsConfig{
+			AudioMaxSize:      1 << 20,
+			ImageMaxSize:      1 << 20,
+			AudioContentTypes: []string{"audio/mpeg"},
+			ImageContentTypes: []string{"image/png"},
+		},
+		time.Hour,
+		logger.New("file-service-test"),
+	)
+	if err != nil {
+		t.Fatalf("NewBackend() error = %v", err)
+	}
+	return backend
+}
+
+// signedDownload returns the path of a download signed to expire at the time
+func signedDownload(b *Backend, fileType domain.FileType, fileID string, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", b.sign(downloadStringToSign(fileType, fileID, expires)))
+	return "/" + string(fileType) + "/" + url.PathEscape(fileID) + "?" + query.Encode()
+}
+
+func encodePolicy(t *testing.T, policy uploadPolicy) string {
+	t.Helper()
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatalf("failed to encode policy: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(policyJSON)
+}
+
+// uploadRequest returns a form upload of the content with the encoded policy
+func uploadRequest(t *testing.T, key string, policy string, signature string, content string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	_ = form.WriteField("key", key)
+	_ = form.WriteField("policy", policy)
+	_ = form.WriteField("signature", signature)
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="file"; filename="upload"`)
+	header.Set("Content-Type", "audio/mpeg")
+	part, err := form.CreatePart(header)
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	_, _ = part.Write([]byte(content))
+	_ = form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/audio", body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestServeDownloadChecksSignature(t *testing.T) {
+	b := newTestBackend(t)
+	if err := b.Put(context.Background(), domain.FileTypeAudio, "song", strings.NewReader("ID3 audio"), -1, "audio/mpeg"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	valid := signedDownload(b, domain.FileTypeAudio, "song", time.Now().Add(time.Minute))
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"expired", signedDownload(b, domain.FileTypeAudio, "song", time.Now().Add(-time.Minute)), http.StatusForbidden},
+		{"tampered signature", strings.Replace(valid, "signature=", "signature=00", 1), http.StatusForbidden},
+		{"signed for another file", strings.Replace(valid, "/song?", "/other?", 1), http.StatusForbidden},
+		{"signed for another file type", strings.Replace(valid, "/audio/", "/image/", 1), http.StatusForbidden},
+		{"unsigned", "/audio/song", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			b.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && rec.Body.String() != "ID3 audio" {
+				t.Errorf("body = %q, want %q", rec.Body.String(), "ID3 audio")
+			}
+		})
+	}
+}
+
+func TestServeUploadChecksPolicy(t *testing.T) {
+	b := newTestBackend(t)
+
+	valid := uploadPolicy{
+		FileType:          domain.FileTypeAudio,
+		Key:               "song",
+		Expires:           time.Now().Add(time.Minute).Unix(),
+		MinSize:           1,
+		MaxSize:           16,
+		Owner:             "user-1",
+		ContentTypePrefix: "audio/",
+	}
+
+	expired := valid
+	expired.Expires = time.Now().Add(-time.Minute).Unix()
+
+	// The size limit raised without signing the policy again
+	raised := valid
+	raised.MaxSize = 1 << 20
+
+	validPolicy := encodePolicy(t, valid)
+	signature := b.sign(validPolicy)
+
+	tests := []struct {
+		name      string
+		key       string
+		policy    string
+		signature string
+		content   string
+		want      int
+	}{
+		{"valid", "song", validPolicy, signature, "ID3 audio", http.StatusNoContent},
+		{"expired", "song", encodePolicy(t, expired), b.sign(encodePolicy(t, expired)), "ID3 audio", http.StatusForbidden},
+		{"tampered policy", "song", encodePolicy(t, raised), signature, strings.Repeat("a", 17), http.StatusForbidden},
+		{"tampered signature", "song", validPolicy, "00" + signature[2:], "ID3 audio", http.StatusForbidden},
+		{"key of another file", "other", validPolicy, signature, "ID3 audio", http.StatusForbidden},
+		{"larger than the policy", "song", validPolicy, signature, strings.Repeat("a", 17), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			b.ServeHTTP(rec, uploadRequest(t, tt.key, tt.policy, tt.signature, tt.content))
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+
+			info, err := b.Stat(context.Background(), domain.FileTypeAudio, tt.key)
+			if tt.want != http.StatusNoContent {
+				if !errors.Is(err, domain.ErrFileNotFound) {
+					t.Errorf("Stat() of a rejected upload error = %v, want %v", err, domain.ErrFileNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Stat() error = %v", err)
+			}
+			if info.Size != int64(len(tt.content)) || info.Metadata[domain.MetadataOwner] != "user-1" {
+				t.Errorf("Stat() = %+v, want the upload of user-1", info)
+			}
+			_ = b.Delete(context.Background(), domain.FileTypeAudio, tt.key)
+		})
+	}
+}
+
+func TestPresignUploadLimitsToQuotaLeft(t *testing.T) {
+	b := newTestBackend(t)
+
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"within the quota", "ID3 audi", http.StatusNoContent},
+		{"exceeding the quota", "ID3 audio", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Without a declared size only the quota left bounds the upload
+			req := domain.UploadRequest{FileType: domain.FileTypeAudio, OwnerID: "user-1", QuotaLeft: 8}
+			resp, err := b.PresignUpload(context.Background(), req, "song")
+			if err != nil {
+				t.Fatalf("PresignUpload() error = %v", err)
+			}
+			t.Cleanup(func() { _ = b.Delete(context.Background(), domain.FileTypeAudio, "song") })
+
+			rec := httptest.NewRecorder()
+			b.ServeHTTP(rec, uploadRequest(t, "song", resp.FormData["policy"], resp.FormData["signature"], tt.content))
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestPathsStayInsideRoot(t *testing.T) {
+	b := newTestBackend(t)
+	ctx := context.Background()
+
+	// A file next to the storage root that escaping keys would reach
+	secret := filepath.Join(filepath.Dir(b.cfg.Root), "secret")
+	if err := os.WriteFile(secret, []byte("secret"), 0o600); err != nil {
+		t.Fatalf("failed to write secret: %v", err)
+	}
+
+	for _, fileID := range []string{"../../../secret", "../../objects/x", "/etc/passwd", "a/../../../../secret", ""} {
+		t.Run(fileID, func(t *testing.T) {
+			if _, err := b.Stat(ctx, domain.FileTypeAudio, fileID); !errors.Is(err, domain.ErrFileNotFound) {
+				t.Errorf("Stat() error = %v, want %v", err, domain.ErrFileNotFound)
+			}
+			if _, err := b.Open(ctx, domain.FileTypeAudio, fileID); !errors.Is(err, domain.ErrFileNotFound) {
+				t.Errorf("Open() error = %v, want %v", err, domain.ErrFileNotFound)
+			}
+			if err := b.Put(ctx, domain.FileTypeAudio, fileID, strings.NewReader("x"), 1, "audio/mpeg"); !errors.Is(err, domain.ErrFileNotFound) {
+				t.Errorf("Put() error = %v, want %v", err, domain.ErrFileNotFound)
+			}
+			if err := b.Delete(ctx, domain.FileTypeAudio, fileID); !errors.Is(err, domain.ErrFileNotFound) {
+				t.Errorf("Delete() error = %v, want %v", err, domain.ErrFileNotFound)
+			}
+			if _, err := b.PresignDownload(ctx, domain.FileTypeAudio, fileID, false); !errors.Is(err, domain.ErrFileNotFound) {
+				t.Errorf("PresignDownload() error = %v, want %v", err, domain.ErrFileNotFound)
+			}
+
+			// Even a validly signed URL doesn't reach outside the root
+			rec := httptest.NewRecorder()
+			b.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, signedDownload(b, domain.FileTypeAudio, fileID, time.Now().Add(time.Minute)), nil))
+			if rec.Code == http.StatusOK {
+				t.Errorf("download status = %d, want the file not to be served", rec.Code)
+			}
+		})
+	}
+
+	t.Run("upload key", func(t *testing.T) {
+		policy := uploadPolicy{
+			FileType: domain.FileTypeAudio,
+			Key:      "../../../escaped",
+			Expires:  time.Now().Add(time.Minute).Unix(),
+			MinSize:  1,
+			MaxSize:  16,
+		}
+		encoded := encodePolicy(t, policy)
+
+		rec := httptest.NewRecorder()
+		b.ServeHTTP(rec, uploadRequest(t, policy.Key, encoded, b.sign(encoded), "ID3 audio"))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(b.cfg.Root), "escaped")); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("upload escaped the storage root: %v", err)
+		}
+	})
+
+	if content, err := os.ReadFile(secret); err != nil || string(content) != "secret" {
+		t.Errorf("secret = %q, %v, want it untouched", content, err)
+	}
+}
@@ -0,0 +1,195 @@
+package filesystem
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"file-service/internal/domain"
+)
+
+// maxFormFieldSize bounds the size of non-file form fields
+const maxFormFieldSize = 8 << 10
+
+// ServeHTTP serves signed URLs issued by PresignUpload and PresignDownload:
+// POST /{file_type} accepts a form upload, GET /{file_type}/{file_id} downloads
+func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fileType, fileID, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.Method == http.MethodPost && fileID == "":
+		b.serveUpload(w, r, domain.FileType(fileType))
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && fileID != "":
+		b.serveDownload(w, r, domain.FileType(fileType), fileID)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func (b *Backend) serveDownload(w http.ResponseWriter, r *http.Request, fileType domain.FileType, fileID string) {
+	expires := r.URL.Query().Get("expires")
+	signature := r.URL.Query().Get("signature")
+
+	if !b.verify(downloadStringToSign(fileType, fileID, expires), signature) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if expired(expires) {
+		http.Error(w, "URL expired", http.StatusForbidden)
+		return
+	}
+
+	info, err := b.Stat(r.Context(), fileType, fileID)
+	if err != nil {
+		if errors.Is(err, domain.ErrFileNotFound) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		b.logger.Error().Err(err).Str("file_id", fileID).Msg("Failed to stat file")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	objectPath, _, _ := b.paths(fileType, fileID)
+	file, err := os.Open(objectPath)
+	if err != nil {
+		b.logger.Error().Err(err).Str("file_id", fileID).Msg("Failed to open file")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+	http.ServeContent(w, r, "", info.LastModified, file)
+}
+
+// serveUpload handles a multipart form upload. Like S3 POST uploads the
+// policy fields must precede the file, which is streamed to disk.
+func (b *Backend) serveUpload(w http.ResponseWriter, r *http.Request, fileType domain.FileType) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "expected multipart form", http.StatusBadRequest)
+		return
+	}
+
+	fields := make(map[string]string)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			http.Error(w, "missing file", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "malformed multipart form", http.StatusBadRequest)
+			return
+		}
+
+		if part.FormName() != "file" {
+			value, err := io.ReadAll(io.LimitReader(part, maxFormFieldSize))
+			if err != nil {
+				http.Error(w, "malformed multipart form", http.StatusBadRequest)
+				return
+			}
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+
+		policy, status, msg := b.checkPolicy(fields, fileType, mediaType)
+		if policy == nil {
+			http.Error(w, msg, status)
+			return
+		}
+
+		b.storeUpload(w, r, policy, mediaType, part)
+		return
+	}
+}
+
+func (b *Backend) checkPolicy(fields map[string]string, fileType domain.FileType, mediaType string) (*uploadPolicy, int, string) {
+	if !b.verify(fields["policy"], fields["signature"]) {
+		return nil, http.StatusForbidden, "invalid signature"
+	}
+
+	policyJSON, err := base64.RawURLEncoding.DecodeString(fields["policy"])
+	if err != nil {
+		return nil, http.StatusBadRequest, "malformed policy"
+	}
+
+	var policy uploadPolicy
+	if err := json.Unmarshal(policyJSON, &policy); err != nil {
+		return nil, http.StatusBadRequest, "malformed policy"
+	}
+
+	if time.Now().Unix() > policy.Expires {
+		return nil, http.StatusForbidden, "policy expired"
+	}
+	if policy.FileType != fileType || fields["key"] != policy.Key {
+		return nil, http.StatusForbidden, "policy does not match the upload"
+	}
+
+	if policy.ContentType != "" && mediaType != policy.ContentType {
+		return nil, http.StatusForbidden, "content type is not allowed by policy"
+	}
+	if policy.ContentTypePrefix != "" && !strings.HasPrefix(mediaType, policy.ContentTypePrefix) {
+		return nil, http.StatusForbidden, "content type is not allowed by policy"
+	}
+
+	return &policy, 0, ""
+}
+
+func (b *Backend) storeUpload(
+	w http.ResponseWriter,
+	r *http.Request,
+	policy *uploadPolicy,
+	mediaType string,
+	file io.Reader,
+) {
+	objectPath, metaPath, err := b.paths(policy.FileType, policy.Key)
+	if err != nil {
+		http.Error(w, "invalid key", http.StatusBadRequest)
+		return
+	}
+
+	// Read one byte past the limit to detect oversized uploads
+	written, err := writeFileAtomic(objectPath, io.LimitReader(file, policy.MaxSize+1))
+	if err != nil {
+		b.logger.Error().Err(err).Str("file_id", policy.Key).Msg("Failed to store upload")
+		http.Error(w, "failed to store upload", http.StatusInternalServerError)
+		return
+	}
+
+	if written < policy.MinSize || written > policy.MaxSize {
+		_ = b.Delete(r.Context(), policy.FileType, policy.Key)
+		http.Error(w, "file size is not allowed by policy", http.StatusBadRequest)
+		return
+	}
+
+	meta := &objectMetadata{ContentType: mediaType}
+	if policy.Owner != "" {
+		meta.Metadata = map[string]string{domain.MetadataOwner: policy.Owner}
+	}
+
+	if err := writeMetadata(metaPath, meta); err != nil {
+		b.logger.Error().Err(err).Str("file_id", policy.Key).Msg("Failed to store upload metadata")
+		http.Error(w, "failed to store upload", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func expired(expires string) bool {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	return err != nil || time.Now().Unix() > unix
+}
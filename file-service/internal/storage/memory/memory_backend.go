@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+	"time"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+)
+
+type object struct {
+	data        []byte
+	contentType string
+	metadata    map[string]string
+	modified    time.Time
+}
+
+// Backend keeps files in process memory. It's meant for tests and local
+// development: its presigned URLs only identify objects and can't be used.
+type Backend struct {
+	mu            sync.RWMutex
+	objects       map[string]*object
+	limits        *config.UploadLimitsConfig
+	presignExpiry time.Duration
+}
+
+func NewBackend(limits *config.UploadLimitsConfig, presignExpiry time.Duration) *Backend {
+	return &Backend{
+		objects:       make(map[string]*object),
+		limits:        limits,
+		presignExpiry: presignExpiry,
+	}
+}
+
+func (b *Backend) PresignUpload(
+	_ context.Context,
+	req domain.UploadRequest,
+	fileID string,
+) (*domain.PresignedPostResponse, error) {
+	if err := req.Validate(b.limits.ForFileType(string(req.FileType))); err != nil {
+		return nil, err
+	}
+
+	return &domain.PresignedPostResponse{
+		URL:       "memory://" + string(req.FileType),
+		FormData:  map[string]string{"key": fileID},
+		ExpiresAt: time.Now().Add(b.presignExpiry),
+		FileID:    fileID,
+	}, nil
+}
+
+func (b *Backend) PresignDownload(
+	_ context.Context,
+	fileType domain.FileType,
+	fileID string,
+	_ bool,
+) (*domain.PresignedURLResponse, error) {
+	return &domain.PresignedURLResponse{
+		URL:       "memory://" + objectKey(fileType, fileID),
+		ExpiresAt: time.Now().Add(b.presignExpiry),
+		FileID:    fileID,
+	}, nil
+}
+
+func (b *Backend) Stat(_ context.Context, fileType domain.FileType, fileID string) (*domain.FileInfo, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	obj, exists := b.objects[objectKey(fileType, fileID)]
+	if !exists {
+		return nil, domain.ErrFileNotFound
+	}
+
+	return &domain.FileInfo{
+		FileID:       fileID,
+		FileType:     fileType,
+		Size:         int64(len(obj.data)),
+		ContentType:  obj.contentType,
+		Checksum:     obj.metadata[domain.MetadataChecksum],
+		LastModified: obj.modified,
+		Metadata:     maps.Clone(obj.metadata),
+	}, nil
+}
+
+func (b *Backend) Open(_ context.Context, fileType domain.FileType, fileID string) (io.ReadCloser, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	obj, exists := b.objects[objectKey(fileType, fileID)]
+	if !exists {
+		return nil, domain.ErrFileNotFound
+	}
+
+	// Stored data is never modified in place, so it can be shared with readers
+	return objectReader{bytes.NewReader(obj.data)}, nil
+}
+
+func (b *Backend) Put(
+	_ context.Context,
+	fileType domain.FileType,
+	fileID string,
+	data io.Reader,
+	size int64,
+	contentType string,
+) error {
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if size >= 0 && int64(len(content)) != size {
+		return fmt.Errorf("file size %d does not match declared size %d", len(content), size)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.objects[objectKey(fileType, fileID)] = &object{
+		data:        content,
+		contentType: contentType,
+		metadata:    make(map[string]string),
+		modified:    time.Now(),
+	}
+	return nil
+}
+
+func (b *Backend) UpdateMetadata(
+	_ context.Context,
+	fileType domain.FileType,
+	fileID string,
+	contentType string,
+	metadata map[string]string,
+) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	obj, exists := b.objects[objectKey(fileType, fileID)]
+	if !exists {
+		return domain.ErrFileNotFound
+	}
+
+	updated := *obj
+	updated.metadata = maps.Clone(obj.metadata)
+	maps.Copy(updated.metadata, metadata)
+	if contentType != "" {
+		updated.contentType = contentType
+	}
+	b.objects[objectKey(fileType, fileID)] = &updated
+
+	return nil
+}
+
+func (b *Backend) Delete(_ context.Context, fileType domain.FileType, fileID string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.objects, objectKey(fileType, fileID))
+	return nil
+}
+
+// objectReader implements io.ReaderAt and io.Seeker like the readers of the other backends
+type objectReader struct {
+	*bytes.Reader
+}
+
+func (objectReader) Close() error {
+	return nil
+}
+
+func objectKey(fileType domain.FileType, fileID string) string {
+	return string(fileType) + "/" + fileID
+}
@@ -1,26 +1,34 @@
 package minio
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"maps"
+	"net/http"
 	"net/url"
-	"strings"
+	"slices"
+	"strconv"
 	"time"
 
 	"file-service/internal/config"
 	"file-service/internal/domain"
 	"file-service/internal/storage/cache"
 	"file-service/pkg/logger"
+	"file-service/pkg/urlrewrite"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
 type MinioClient struct {
-	client *minio.Client
-	config *config.MinioConfig
-	logger *logger.Logger
-	cache  cache.URLCache
+	client   *minio.Client
+	core     *minio.Core
+	config   *config.MinioConfig
+	logger   *logger.Logger
+	cache    cache.URLCache
+	rewriter *urlrewrite.Rewriter
 }
 
 func NewMinioClient(cfg *config.MinioConfig, cache cache.URLCache, log *logger.Logger) (*MinioClient, error) {
@@ -32,11 +40,30 @@ func NewMinioClient(cfg *config.MinioConfig, cache cache.URLCache, log *logger.L
 		return nil, fmt.Errorf("failed to create minio client: %w", err)
 	}
 
+	scheme := "http"
+	if cfg.UseSSL {
+		scheme = "https"
+	}
+
+	rewriter, err := urlrewrite.New(urlrewrite.Options{
+		Source:                 scheme + "://" + cfg.Endpoint,
+		PublicBaseURL:          cfg.URLs.PublicBaseURL,
+		InternalBaseURL:        cfg.URLs.InternalBaseURL,
+		BucketPublicBaseURLs:   cfg.URLs.BucketPublicBaseURLs,
+		BucketInternalBaseURLs: cfg.URLs.BucketInternalBaseURLs,
+		HostPublicBaseURLs:     cfg.URLs.HostPublicBaseURLs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create URL rewriter: %w", err)
+	}
+
 	mc := &MinioClient{
-		client: client,
-		config: cfg,
-		logger: log,
-		cache:  cache,
+		client:   client,
+		core:     &minio.Core{Client: client},
+		config:   cfg,
+		logger:   log,
+		cache:    cache,
+		rewriter: rewriter,
 	}
 
 	if err := mc.initializeBuckets(context.Background()); err != nil {
@@ -47,7 +74,7 @@ func NewMinioClient(cfg *config.MinioConfig, cache cache.URLCache, log *logger.L
 }
 
 func (m *MinioClient) initializeBuckets(ctx context.Context) error {
-	buckets := []string{m.config.ImageBucket, m.config.AudioBucket}
+	buckets := []string{m.config.ImageBucket, m.config.AudioBucket, m.config.QuarantineBucket}
 
 	for _, bucket := range buckets {
 		exists, err := m.client.BucketExists(ctx, bucket)
@@ -59,113 +86,472 @@ func (m *MinioClient) initializeBuckets(ctx context.Context) error {
 			if err := m.client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
 				return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
 			}
-
-			policy := fmt.Sprintf(`{
-				"Version": "2012-10-17",
-				"Statement": [
-					{
-						"Effect": "Allow",
-						"Principal": {"AWS": ["*"]},
-						"Action": ["s3:GetObject"],
-						"Resource": ["arn:aws:s3:::%s/*"]
-					}
-				]
-			}`, bucket)
-
-			if err := m.client.SetBucketPolicy(ctx, bucket, policy); err != nil {
-				m.logger.Warn().Err(err).Str("bucket", bucket).Msg("Failed to set bucket policy")
-			}
-
 			m.logger.Info().Str("bucket", bucket).Msg("Bucket created successfully")
+			continue
+		}
+
+		// Files are only downloaded through presigned URLs once processing
+		// accepted them, so anonymous reads granted before are revoked
+		if err := m.client.SetBucketPolicy(ctx, bucket, ""); err != nil {
+			m.logger.Warn().Err(err).Str("bucket", bucket).Msg("Failed to remove bucket policy")
 		}
 	}
 
 	return nil
 }
 
-func (m *MinioClient) GeneratePresignedPutURL(
+func (m *MinioClient) PresignUpload(
 	ctx context.Context,
 	req domain.UploadRequest,
 	fileID string,
-) (*domain.PresignedURLResponse, error) {
+) (*domain.PresignedPostResponse, error) {
 	bucket := m.getBucketByFileType(req.FileType)
+	maxSize, _ := m.getUploadLimits(req.FileType)
+
+	if err := req.Validate(m.getUploadLimits(req.FileType)); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(m.config.PresignExpiry)
+
+	policy := minio.NewPostPolicy()
+	if err := policy.SetBucket(bucket); err != nil {
+		return nil, fmt.Errorf("failed to set policy bucket: %w", err)
+	}
+	if err := policy.SetKey(fileID); err != nil {
+		return nil, fmt.Errorf("failed to set policy key: %w", err)
+	}
+	if err := policy.SetExpires(expiresAt.UTC()); err != nil {
+		return nil, fmt.Errorf("failed to set policy expiry: %w", err)
+	}
+
+	// Tighten the policy to the declared values when the client provided them
+	if req.Size > 0 {
+		err := policy.SetContentLengthRange(req.Size, req.Size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set policy content length: %w", err)
+		}
+	} else if err := policy.SetContentLengthRange(1, req.MaxSize(maxSize)); err != nil {
+		return nil, fmt.Errorf("failed to set policy content length: %w", err)
+	}
+
+	// POST policies can't express a list of values, so without a declared
+	// content type only the top-level media type is enforced
+	if req.ContentType != "" {
+		if err := policy.SetContentType(req.ContentType); err != nil {
+			return nil, fmt.Errorf("failed to set policy content type: %w", err)
+		}
+	} else if err := policy.SetContentTypeStartsWith(string(req.FileType) + "/"); err != nil {
+		return nil, fmt.Errorf("failed to set policy content type: %w", err)
+	}
+
+	if req.OwnerID != "" {
+		if err := policy.SetUserMetadata(domain.MetadataOwner, req.OwnerID); err != nil {
+			return nil, fmt.Errorf("failed to set policy owner: %w", err)
+		}
+	}
 
-	presignedUrl, err := m.client.PresignedPutObject(ctx, bucket, fileID, m.config.PresignExpiry)
+	postURL, formData, err := m.client.PresignedPostPolicy(ctx, policy)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate presigned put URL: %w", err)
+		return nil, fmt.Errorf("failed to generate presigned post policy: %w", err)
 	}
 
-	return &domain.PresignedURLResponse{
-		URL:       m.fixProxyDomain(presignedUrl.String(), false),
-		ExpiresAt: time.Now().Add(m.config.PresignExpiry),
+	publicURL, err := m.rewriteURL(ctx, postURL, bucket, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.PresignedPostResponse{
+		URL:       publicURL,
+		FormData:  formData,
+		ExpiresAt: expiresAt,
 		FileID:    fileID,
 	}, nil
 }
 
-func (m *MinioClient) GeneratePresignedGetURL(
+func (m *MinioClient) InitiateMultipartUpload(
+	ctx context.Context,
+	req domain.UploadRequest,
+	fileID string,
+) (*domain.MultipartUploadResponse, error) {
+	bucket := m.getBucketByFileType(req.FileType)
+
+	if err := req.Validate(m.getUploadLimits(req.FileType)); err != nil {
+		return nil, err
+	}
+
+	opts := minio.PutObjectOptions{ContentType: req.ContentType}
+	if req.OwnerID != "" {
+		opts.UserMetadata = map[string]string{domain.MetadataOwner: req.OwnerID}
+	}
+
+	uploadID, err := m.core.NewMultipartUpload(ctx, bucket, fileID, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	return &domain.MultipartUploadResponse{
+		FileID:   fileID,
+		UploadID: uploadID,
+		PartSize: m.config.Multipart.PartSize,
+	}, nil
+}
+
+// UploadPart uploads a single part of a multipart upload and returns its ETag
+func (m *MinioClient) UploadPart(
 	ctx context.Context,
 	fileType domain.FileType,
 	fileID string,
-	isInternalRequest bool,
-) (*domain.PresignedURLResponse, error) {
-	// Check cache first for external requests
-	if !isInternalRequest {
-		cacheKey := m.cache.GenerateKey(fileType, fileID)
-		if cached, found := m.cache.Get(ctx, cacheKey); found {
-			m.logger.Info().
-				Str("file_id", fileID).
-				Str("file_type", string(fileType)).
-				Msg("Presigned GET URL retrieved from cache")
-			return cached, nil
+	uploadID string,
+	partNumber int,
+	data []byte,
+) (string, error) {
+	bucket := m.getBucketByFileType(fileType)
+
+	part, err := m.core.PutObjectPart(ctx, bucket, fileID, uploadID, partNumber,
+		bytes.NewReader(data), int64(len(data)), minio.PutObjectPartOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchUpload" {
+			return "", domain.ErrUploadNotFound
 		}
+		return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
 	}
 
-	// Generate new presigned URL
+	return part.ETag, nil
+}
+
+func (m *MinioClient) PresignParts(
+	ctx context.Context,
+	fileType domain.FileType,
+	fileID string,
+	uploadID string,
+	partNumbers []int,
+) (*domain.PartURLsResponse, error) {
 	bucket := m.getBucketByFileType(fileType)
-	reqParams := make(url.Values)
 
-	presignedUrl, err := m.client.PresignedGetObject(ctx, bucket, fileID, m.config.PresignExpiry, reqParams)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate presigned get URL: %w", err)
+	parts := make([]domain.PartURL, 0, len(partNumbers))
+	for _, partNumber := range partNumbers {
+		reqParams := make(url.Values)
+		reqParams.Set("partNumber", strconv.Itoa(partNumber))
+		reqParams.Set("uploadId", uploadID)
+
+		partURL, err := m.client.Presign(ctx, http.MethodPut, bucket, fileID, m.config.PresignExpiry, reqParams)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate presigned part URL: %w", err)
+		}
+
+		publicURL, err := m.rewriteURL(ctx, partURL, bucket, false)
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, domain.PartURL{
+			PartNumber: partNumber,
+			URL:        publicURL,
+		})
 	}
 
-	response := &domain.PresignedURLResponse{
-		URL:       m.fixProxyDomain(presignedUrl.String(), isInternalRequest),
+	return &domain.PartURLsResponse{
+		Parts:     parts,
 		ExpiresAt: time.Now().Add(m.config.PresignExpiry),
-		FileID:    fileID,
+	}, nil
+}
+
+func (m *MinioClient) CompleteMultipartUpload(
+	ctx context.Context,
+	fileType domain.FileType,
+	fileID string,
+	uploadID string,
+	parts []domain.CompletedPart,
+) error {
+	bucket := m.getBucketByFileType(fileType)
+
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{
+			PartNumber: part.PartNumber,
+			ETag:       part.ETag,
+		})
+	}
+	slices.SortFunc(completeParts, func(a, b minio.CompletePart) int {
+		return a.PartNumber - b.PartNumber
+	})
+
+	_, err := m.core.CompleteMultipartUpload(ctx, bucket, fileID, uploadID, completeParts, minio.PutObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchUpload" {
+			return domain.ErrUploadNotFound
+		}
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	// Parts are uploaded through plain presigned URLs, so the total size
+	// can only be enforced once the object is assembled
+	info, err := m.client.StatObject(ctx, bucket, fileID, minio.StatObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to stat completed upload: %w", err)
+	}
+
+	if maxSize, _ := m.getUploadLimits(fileType); info.Size > maxSize {
+		if err := m.client.RemoveObject(ctx, bucket, fileID, minio.RemoveObjectOptions{}); err != nil {
+			m.logger.Warn().Err(err).Str("file_id", fileID).Msg("Failed to delete oversized upload")
+		}
+		return domain.ErrFileTooLarge
+	}
+
+	return nil
+}
+
+func (m *MinioClient) AbortMultipartUpload(ctx context.Context, fileType domain.FileType, fileID string, uploadID string) error {
+	bucket := m.getBucketByFileType(fileType)
+
+	if err := m.core.AbortMultipartUpload(ctx, bucket, fileID, uploadID); err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchUpload" {
+			return domain.ErrUploadNotFound
+		}
+		return fmt.Errorf("failed to abort multipart upload: %w", err)
+	}
+
+	return nil
+}
+
+// AbortStaleMultipartUploads aborts multipart uploads in all buckets that
+// were initiated before the given time, unless keep reports their key as in
+// use, and returns how many were aborted
+func (m *MinioClient) AbortStaleMultipartUploads(
+	ctx context.Context,
+	initiatedBefore time.Time,
+	keep func(key string) bool,
+) (int, error) {
+	aborted := 0
+
+	for _, bucket := range []string{m.config.ImageBucket, m.config.AudioBucket} {
+		for upload := range m.client.ListIncompleteUploads(ctx, bucket, "", true) {
+			if upload.Err != nil {
+				return aborted, fmt.Errorf("failed to list multipart uploads in bucket %s: %w", bucket, upload.Err)
+			}
+
+			if upload.Initiated.After(initiatedBefore) || keep(upload.Key) {
+				continue
+			}
+
+			if err := m.core.AbortMultipartUpload(ctx, bucket, upload.Key, upload.UploadID); err != nil {
+				m.logger.Warn().Err(err).
+					Str("bucket", bucket).
+					Str("file_id", upload.Key).
+					Str("upload_id", upload.UploadID).
+					Msg("Failed to abort stale multipart upload")
+				continue
+			}
+			aborted++
+		}
+	}
+
+	return aborted, nil
+}
+
+// DeleteStaleObjects removes objects under the prefix in all buckets that
+// were last modified before the given time, unless keep reports their key as
+// in use, and returns how many were removed
+func (m *MinioClient) DeleteStaleObjects(
+	ctx context.Context,
+	prefix string,
+	modifiedBefore time.Time,
+	keep func(key string) bool,
+) (int, error) {
+	deleted := 0
+
+	for _, bucket := range []string{m.config.ImageBucket, m.config.AudioBucket} {
+		for object := range m.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+			if object.Err != nil {
+				return deleted, fmt.Errorf("failed to list objects in bucket %s: %w", bucket, object.Err)
+			}
+
+			if object.LastModified.After(modifiedBefore) || keep(object.Key) {
+				continue
+			}
+
+			if err := m.client.RemoveObject(ctx, bucket, object.Key, minio.RemoveObjectOptions{}); err != nil {
+				m.logger.Warn().Err(err).
+					Str("bucket", bucket).
+					Str("object", object.Key).
+					Msg("Failed to delete stale object")
+				continue
+			}
+			deleted++
+		}
 	}
 
-	// Cache the response with TTL matching the presign expiry for external requests
-	if !isInternalRequest {
-		cacheKey := m.cache.GenerateKey(fileType, fileID)
-		if err := m.cache.Set(ctx, cacheKey, response, m.config.PresignExpiry); err != nil {
+	return deleted, nil
+}
+
+func (m *MinioClient) PresignDownload(
+	ctx context.Context,
+	fileType domain.FileType,
+	fileID string,
+	isInternalRequest bool,
+) (*domain.PresignedURLResponse, error) {
+	bucket := m.getBucketByFileType(fileType)
+
+	// Cached URLs are stored as signed by MinIO and rewritten per request,
+	// since the public base URL may depend on the request host
+	cacheKey := m.cache.GenerateKey(fileType, fileID)
+	signed, found := m.cache.Get(ctx, cacheKey)
+	if found {
+		m.logger.Info().
+			Str("file_id", fileID).
+			Str("file_type", string(fileType)).
+			Msg("Presigned GET URL retrieved from cache")
+	} else {
+		presignedUrl, err := m.client.PresignedGetObject(ctx, bucket, fileID, m.config.PresignExpiry, make(url.Values))
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate presigned get URL: %w", err)
+		}
+
+		signed = &domain.PresignedURLResponse{
+			URL:       presignedUrl.String(),
+			ExpiresAt: time.Now().Add(m.config.PresignExpiry),
+			FileID:    fileID,
+		}
+
+		// Cache the response with TTL matching the presign expiry
+		if err := m.cache.Set(ctx, cacheKey, signed, m.config.PresignExpiry); err != nil {
 			m.logger.Warn().Err(err).Str("file_id", fileID).Msg("Failed to cache presigned URL")
 		}
+
+		m.logger.Info().
+			Str("file_id", fileID).
+			Str("file_type", string(fileType)).
+			Msg("Presigned GET URL generated and cached")
 	}
 
-	m.logger.Info().
-		Str("file_id", fileID).
-		Str("file_type", string(fileType)).
-		Msg("Presigned GET URL generated and cached")
+	signedURL, err := url.Parse(signed.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse presigned get URL: %w", err)
+	}
+
+	rewrittenURL, err := m.rewriteURL(ctx, signedURL, bucket, isInternalRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.PresignedURLResponse{
+		URL:       rewrittenURL,
+		ExpiresAt: signed.ExpiresAt,
+		FileID:    fileID,
+	}, nil
+}
+
+func (m *MinioClient) Stat(ctx context.Context, fileType domain.FileType, fileID string) (*domain.FileInfo, error) {
+	bucket := m.getBucketByFileType(fileType)
+
+	info, err := m.client.StatObject(ctx, bucket, fileID, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, domain.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	return &domain.FileInfo{
+		FileID:       fileID,
+		FileType:     fileType,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		Checksum:     info.UserMetadata[domain.MetadataChecksum],
+		LastModified: info.LastModified,
+		Metadata:     info.UserMetadata,
+	}, nil
+}
+
+func (m *MinioClient) Put(
+	ctx context.Context,
+	fileType domain.FileType,
+	fileID string,
+	data io.Reader,
+	size int64,
+	contentType string,
+) error {
+	bucket := m.getBucketByFileType(fileType)
+
+	_, err := m.client.PutObject(ctx, bucket, fileID, data, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put file: %w", err)
+	}
+
+	return nil
+}
+
+func (m *MinioClient) Open(ctx context.Context, fileType domain.FileType, fileID string) (io.ReadCloser, error) {
+	bucket := m.getBucketByFileType(fileType)
+
+	object, err := m.client.GetObject(ctx, bucket, fileID, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
 
-	return response, nil
+	// GetObject is lazy, a missing object only shows on the first request
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, domain.ErrFileNotFound
+		}
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	return object, nil
 }
 
-func (m *MinioClient) CheckFileExists(ctx context.Context, fileType domain.FileType, fileID string) (bool, error) {
+// UpdateMetadata merges metadata into the object's user metadata and
+// optionally replaces its content type by copying the object onto itself
+func (m *MinioClient) UpdateMetadata(
+	ctx context.Context,
+	fileType domain.FileType,
+	fileID string,
+	contentType string,
+	metadata map[string]string,
+) error {
 	bucket := m.getBucketByFileType(fileType)
 
-	_, err := m.client.StatObject(ctx, bucket, fileID, minio.StatObjectOptions{})
+	info, err := m.client.StatObject(ctx, bucket, fileID, minio.StatObjectOptions{})
 	if err != nil {
 		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
-			return false, nil
+			return domain.ErrFileNotFound
 		}
-		return false, fmt.Errorf("failed to check file existence: %w", err)
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	userMetadata := make(map[string]string, len(info.UserMetadata)+len(metadata))
+	maps.Copy(userMetadata, info.UserMetadata)
+	maps.Copy(userMetadata, metadata)
+
+	if contentType == "" {
+		contentType = info.ContentType
+	}
+
+	_, err = m.client.CopyObject(ctx,
+		minio.CopyDestOptions{
+			Bucket:          bucket,
+			Object:          fileID,
+			UserMetadata:    userMetadata,
+			ReplaceMetadata: true,
+			ContentType:     contentType,
+		},
+		minio.CopySrcOptions{
+			Bucket: bucket,
+			Object: fileID,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update file metadata: %w", err)
 	}
 
-	return true, nil
+	return nil
 }
 
-func (m *MinioClient) DeleteFile(ctx context.Context, fileType domain.FileType, fileID string) error {
+func (m *MinioClient) Delete(ctx context.Context, fileType domain.FileType, fileID string) error {
 	bucket := m.getBucketByFileType(fileType)
 
 	if err := m.client.RemoveObject(ctx, bucket, fileID, minio.RemoveObjectOptions{}); err != nil {
@@ -193,18 +579,22 @@ func (m *MinioClient) getBucketByFileType(fileType domain.FileType) string {
 		return m.config.ImageBucket
 	case domain.FileTypeAudio:
 		return m.config.AudioBucket
+	case domain.FileTypeQuarantine:
+		return m.config.QuarantineBucket
 	default:
 		return m.config.ImageBucket
 	}
 }
 
-// Hardcoded: changing domain for localhost nginx proxy
-func (m *MinioClient) fixProxyDomain(url string, isInternalRequest bool) string {
-	var newDomain string
-	if isInternalRequest {
-		newDomain = "nginx/s3"
-	} else {
-		newDomain = "localhost/s3"
+func (m *MinioClient) getUploadLimits(fileType domain.FileType) (int64, []string) {
+	return m.config.Upload.ForFileType(string(fileType))
+}
+
+// rewriteURL maps a URL signed against the MinIO endpoint onto the configured public or internal base URL
+func (m *MinioClient) rewriteURL(ctx context.Context, signedURL *url.URL, bucket string, isInternalRequest bool) (string, error) {
+	rewritten, err := m.rewriter.Rewrite(ctx, signedURL.String(), bucket, isInternalRequest)
+	if err != nil {
+		return "", fmt.Errorf("failed to rewrite presigned URL: %w", err)
 	}
-	return strings.Replace(url, m.config.Endpoint, newDomain, 1)
+	return rewritten, nil
 }
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"file-service/internal/domain"
+)
+
+// Backend defines the interface of the object storage keeping uploaded files
+type Backend interface {
+	// PresignUpload returns a signed form upload restricted by the upload limits
+	PresignUpload(ctx context.Context, req domain.UploadRequest, fileID string) (*domain.PresignedPostResponse, error)
+	PresignDownload(
+		ctx context.Context,
+		fileType domain.FileType,
+		fileID string,
+		isInternalRequest bool,
+	) (*domain.PresignedURLResponse, error)
+	// Stat returns domain.ErrFileNotFound if the file doesn't exist
+	Stat(ctx context.Context, fileType domain.FileType, fileID string) (*domain.FileInfo, error)
+	// Open returns a reader of the file that also implements io.ReaderAt
+	Open(ctx context.Context, fileType domain.FileType, fileID string) (io.ReadCloser, error)
+	Put(ctx context.Context, fileType domain.FileType, fileID string, data io.Reader, size int64, contentType string) error
+	// UpdateMetadata merges metadata into the file's metadata, an empty
+	// content type keeps the current one
+	UpdateMetadata(
+		ctx context.Context,
+		fileType domain.FileType,
+		fileID string,
+		contentType string,
+		metadata map[string]string,
+	) error
+	// Delete removes the file, deleting a missing file is not an error
+	Delete(ctx context.Context, fileType domain.FileType, fileID string) error
+}
+
+// MultipartBackend is implemented by backends supporting multipart uploads
+type MultipartBackend interface {
+	Backend
+
+	InitiateMultipartUpload(ctx context.Context, req domain.UploadRequest, fileID string) (*domain.MultipartUploadResponse, error)
+	PresignParts(
+		ctx context.Context,
+		fileType domain.FileType,
+		fileID string,
+		uploadID string,
+		partNumbers []int,
+	) (*domain.PartURLsResponse, error)
+	UploadPart(
+		ctx context.Context,
+		fileType domain.FileType,
+		fileID string,
+		uploadID string,
+		partNumber int,
+		data []byte,
+	) (string, error)
+	CompleteMultipartUpload(
+		ctx context.Context,
+		fileType domain.FileType,
+		fileID string,
+		uploadID string,
+		parts []domain.CompletedPart,
+	) error
+	AbortMultipartUpload(ctx context.Context, fileType domain.FileType, fileID string, uploadID string) error
+	// AbortStaleMultipartUploads and DeleteStaleObjects leave uploads and
+	// objects alone whose key keep reports as still in use
+	AbortStaleMultipartUploads(ctx context.Context, initiatedBefore time.Time, keep func(key string) bool) (int, error)
+	DeleteStaleObjects(ctx context.Context, prefix string, modifiedBefore time.Time, keep func(key string) bool) (int, error)
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"file-service/internal/config"
+	"file-service/internal/storage/cache"
+	"file-service/internal/storage/filesystem"
+	"file-service/internal/storage/memory"
+	"file-service/internal/storage/minio"
+	"file-service/pkg/logger"
+)
+
+var (
+	_ MultipartBackend = (*minio.MinioClient)(nil)
+	_ Backend          = (*filesystem.Backend)(nil)
+	_ Backend          = (*memory.Backend)(nil)
+)
+
+// NewBackend creates a storage backend based on configuration
+func NewBackend(cfg *config.Config, urlCache cache.URLCache, log *logger.Logger) (Backend, error) {
+	switch cfg.Storage.Type {
+	case "filesystem":
+		backend, err := filesystem.NewBackend(&cfg.Storage.Filesystem, &cfg.Minio.Upload, cfg.Minio.PresignExpiry, log)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Str("type", "filesystem").Str("root", cfg.Storage.Filesystem.Root).Msg("Filesystem storage initialized")
+		return backend, nil
+	case "memory":
+		log.Info().Str("type", "memory").Msg("In-memory storage initialized")
+		return memory.NewBackend(&cfg.Minio.Upload, cfg.Minio.PresignExpiry), nil
+	}
+
+	// Default to MinIO
+	backend, err := minio.NewMinioClient(&cfg.Minio, urlCache, log)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("type", "minio").Str("endpoint", cfg.Minio.Endpoint).Msg("Minio storage initialized")
+	return backend, nil
+}
@@ -0,0 +1,258 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/storage"
+	"file-service/internal/storage/cache"
+	"file-service/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+// testConfig returns the configuration of a backend of the storage type.
+// MinIO is only tested against the server set by MINIO_TEST_ENDPOINT.
+func testConfig(t *testing.T, storageType string) *config.Config {
+	cfg := &config.Config{
+		Storage: config.StorageConfig{Type: storageType},
+		Minio: config.MinioConfig{
+			ImageBucket:      "file-service-test-images",
+			AudioBucket:      "file-service-test-audio",
+			QuarantineBucket: "file-service-test-quarantine",
+			PresignExpiry:    time.Hour,
+			Upload: config.UploadLimitsConfig{
+				AudioMaxSize:      1 << 20,
+				ImageMaxSize:      1 << 20,
+				AudioContentTypes: []string{"audio/mpeg", "audio/wav"},
+				ImageContentTypes: []string{"image/png"},
+			},
+		},
+	}
+
+	switch storageType {
+	case "filesystem":
+		cfg.Storage.Filesystem = config.FilesystemConfig{
+			Root:        t.TempDir(),
+			PublicURL:   "http://localhost/storage",
+			InternalURL: "http://file-service/storage",
+			SigningKey:  "test-signing-key",
+		}
+	case "minio":
+		endpoint := os.Getenv("MINIO_TEST_ENDPOINT")
+		if endpoint == "" {
+			t.Skip("MINIO_TEST_ENDPOINT is not set")
+		}
+		cfg.Minio.Endpoint = endpoint
+		cfg.Minio.AccessKeyID = os.Getenv("MINIO_TEST_ACCESS_KEY")
+		cfg.Minio.SecretAccessKey = os.Getenv("MINIO_TEST_SECRET_KEY")
+	}
+
+	return cfg
+}
+
+// TestBackends runs every backend through the same contract
+func TestBackends(t *testing.T) {
+	for _, storageType := range []string{"memory", "filesystem", "minio"} {
+		t.Run(storageType, func(t *testing.T) {
+			cfg := testConfig(t, storageType)
+			log := logger.New("file-service-test")
+
+			backend, err := storage.NewBackend(cfg, cache.NewURLCache(&cfg.Cache, nil, log), log)
+			if err != nil {
+				t.Fatalf("NewBackend() error = %v", err)
+			}
+
+			testBackend(t, backend)
+		})
+	}
+}
+
+func testBackend(t *testing.T, backend storage.Backend) {
+	ctx := context.Background()
+
+	// put stores a file that is deleted once the test ends
+	put := func(t *testing.T, fileType domain.FileType, content string, contentType string) string {
+		t.Helper()
+		fileID := uuid.New().String()
+		if err := backend.Put(ctx, fileType, fileID, strings.NewReader(content), int64(len(content)), contentType); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		t.Cleanup(func() { _ = backend.Delete(ctx, fileType, fileID) })
+		return fileID
+	}
+
+	t.Run("stores and reads files", func(t *testing.T) {
+		fileID := put(t, domain.FileTypeAudio, "ID3 audio", "audio/mpeg")
+
+		info, err := backend.Stat(ctx, domain.FileTypeAudio, fileID)
+		if err != nil {
+			t.Fatalf("Stat() error = %v", err)
+		}
+		if info.FileID != fileID || info.FileType != domain.FileTypeAudio || info.Size != 9 || info.ContentType != "audio/mpeg" {
+			t.Errorf("Stat() = %+v, want the stored file", info)
+		}
+
+		reader, err := backend.Open(ctx, domain.FileTypeAudio, fileID)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		defer reader.Close()
+
+		readerAt, ok := reader.(io.ReaderAt)
+		if !ok {
+			t.Fatalf("Open() reader %T doesn't implement io.ReaderAt", reader)
+		}
+		tail := make([]byte, 5)
+		if _, err := readerAt.ReadAt(tail, 4); err != nil && !errors.Is(err, io.EOF) {
+			t.Fatalf("ReadAt() error = %v", err)
+		}
+		if string(tail) != "audio" {
+			t.Errorf("ReadAt() = %q, want %q", tail, "audio")
+		}
+
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("ReadAll() error = %v", err)
+		}
+		if string(content) != "ID3 audio" {
+			t.Errorf("Open() content = %q, want %q", content, "ID3 audio")
+		}
+	})
+
+	t.Run("rejects a size mismatch", func(t *testing.T) {
+		fileID := uuid.New().String()
+		t.Cleanup(func() { _ = backend.Delete(ctx, domain.FileTypeAudio, fileID) })
+
+		if err := backend.Put(ctx, domain.FileTypeAudio, fileID, strings.NewReader("short"), 10, "audio/mpeg"); err == nil {
+			t.Fatal("Put() error = nil, want a size mismatch")
+		}
+	})
+
+	t.Run("keeps file types apart", func(t *testing.T) {
+		fileID := put(t, domain.FileTypeAudio, "ID3 audio", "audio/mpeg")
+
+		if _, err := backend.Stat(ctx, domain.FileTypeImage, fileID); !errors.Is(err, domain.ErrFileNotFound) {
+			t.Errorf("Stat() of another file type error = %v, want %v", err, domain.ErrFileNotFound)
+		}
+	})
+
+	t.Run("reports missing files", func(t *testing.T) {
+		fileID := uuid.New().String()
+
+		if _, err := backend.Stat(ctx, domain.FileTypeAudio, fileID); !errors.Is(err, domain.ErrFileNotFound) {
+			t.Errorf("Stat() error = %v, want %v", err, domain.ErrFileNotFound)
+		}
+		if _, err := backend.Open(ctx, domain.FileTypeAudio, fileID); !errors.Is(err, domain.ErrFileNotFound) {
+			t.Errorf("Open() error = %v, want %v", err, domain.ErrFileNotFound)
+		}
+		err := backend.UpdateMetadata(ctx, domain.FileTypeAudio, fileID, "", map[string]string{domain.MetadataPreview: "30"})
+		if !errors.Is(err, domain.ErrFileNotFound) {
+			t.Errorf("UpdateMetadata() error = %v, want %v", err, domain.ErrFileNotFound)
+		}
+		if err := backend.Delete(ctx, domain.FileTypeAudio, fileID); err != nil {
+			t.Errorf("Delete() error = %v, want nil", err)
+		}
+	})
+
+	t.Run("merges metadata", func(t *testing.T) {
+		fileID := put(t, domain.FileTypeAudio, "RIFF audio", "audio/mpeg")
+
+		err := backend.UpdateMetadata(ctx, domain.FileTypeAudio, fileID, "", map[string]string{domain.MetadataChecksum: "abc"})
+		if err != nil {
+			t.Fatalf("UpdateMetadata() error = %v", err)
+		}
+		err = backend.UpdateMetadata(ctx, domain.FileTypeAudio, fileID, "audio/wav", map[string]string{domain.MetadataPreview: "30"})
+		if err != nil {
+			t.Fatalf("UpdateMetadata() error = %v", err)
+		}
+
+		info, err := backend.Stat(ctx, domain.FileTypeAudio, fileID)
+		if err != nil {
+			t.Fatalf("Stat() error = %v", err)
+		}
+		if info.ContentType != "audio/wav" {
+			t.Errorf("ContentType = %q, want %q", info.ContentType, "audio/wav")
+		}
+		if info.Checksum != "abc" || info.Metadata[domain.MetadataPreview] != "30" {
+			t.Errorf("Metadata = %v, want both updates", info.Metadata)
+		}
+
+		// Metadata updates leave the content alone
+		reader, err := backend.Open(ctx, domain.FileTypeAudio, fileID)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		defer reader.Close()
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("ReadAll() error = %v", err)
+		}
+		if !bytes.Equal(content, []byte("RIFF audio")) {
+			t.Errorf("content = %q, want %q", content, "RIFF audio")
+		}
+	})
+
+	t.Run("deletes files", func(t *testing.T) {
+		fileID := put(t, domain.FileTypeImage, "PNG image", "image/png")
+
+		if err := backend.Delete(ctx, domain.FileTypeImage, fileID); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := backend.Stat(ctx, domain.FileTypeImage, fileID); !errors.Is(err, domain.ErrFileNotFound) {
+			t.Errorf("Stat() after Delete() error = %v, want %v", err, domain.ErrFileNotFound)
+		}
+	})
+
+	t.Run("enforces upload limits", func(t *testing.T) {
+		tests := []struct {
+			name string
+			req  domain.UploadRequest
+			want error
+		}{
+			{"allowed", domain.UploadRequest{FileType: domain.FileTypeAudio, ContentType: "audio/mpeg", Size: 1024}, nil},
+			{"too large", domain.UploadRequest{FileType: domain.FileTypeAudio, Size: 2 << 20}, domain.ErrFileTooLarge},
+			{
+				"content type not allowed",
+				domain.UploadRequest{FileType: domain.FileTypeImage, ContentType: "image/svg+xml"},
+				domain.ErrContentTypeNotAllowed,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				fileID := uuid.New().String()
+
+				resp, err := backend.PresignUpload(ctx, tt.req, fileID)
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("PresignUpload() error = %v, want %v", err, tt.want)
+				}
+				if err == nil && (resp.URL == "" || resp.FileID != fileID || !resp.ExpiresAt.After(time.Now())) {
+					t.Errorf("PresignUpload() = %+v, want a form for the file", resp)
+				}
+			})
+		}
+	})
+
+	t.Run("presigns downloads", func(t *testing.T) {
+		fileID := put(t, domain.FileTypeImage, "PNG image", "image/png")
+
+		for _, internal := range []bool{false, true} {
+			resp, err := backend.PresignDownload(ctx, domain.FileTypeImage, fileID, internal)
+			if err != nil {
+				t.Fatalf("PresignDownload() error = %v", err)
+			}
+			if !strings.Contains(resp.URL, fileID) || resp.FileID != fileID || !resp.ExpiresAt.After(time.Now()) {
+				t.Errorf("PresignDownload() = %+v, want a URL of the file", resp)
+			}
+		}
+	})
+}
@@ -0,0 +1,133 @@
+// Package waveform computes min/max peaks of audio for waveform displays.
+// Peaks are merged as they accumulate, so memory doesn't grow with the
+// length of the audio.
+package waveform
+
+import (
+	"math"
+)
+
+// minSamplesPerPeak is the finest resolution peaks are computed at
+const minSamplesPerPeak = 64
+
+// Waveform holds interleaved min/max pairs in the JSON format of the BBC
+// audiowaveform tool, which player libraries read directly
+type Waveform struct {
+	Version         int    `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"`
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"`
+	Data            []int8 `json:"data"`
+}
+
+// Builder collects the peaks of decoded audio, it implements audiodecode.Sink.
+// It keeps the min/max of every samplesPerPeak frames. Once it holds twice
+// its limit, neighbouring peaks are merged and samplesPerPeak doubles.
+type Builder struct {
+	sampleRate     int
+	channels       int
+	samplesPerPeak int
+	limit          int
+	lows, highs    []float32
+	low, high      float32
+	count          int
+}
+
+// NewBuilder returns a builder keeping enough peaks for waveforms of up to maxResolution points
+func NewBuilder(maxResolution int) *Builder {
+	return &Builder{
+		samplesPerPeak: minSamplesPerPeak,
+		limit:          max(maxResolution, 1) * 4,
+		low:            math.MaxFloat32,
+		high:           -math.MaxFloat32,
+	}
+}
+
+func (b *Builder) Format(sampleRate int, channels int) {
+	b.sampleRate = sampleRate
+	b.channels = channels
+}
+
+func (b *Builder) Write(samples []float32) {
+	for i := 0; i+b.channels <= len(samples); i += b.channels {
+		for _, sample := range samples[i : i+b.channels] {
+			b.low = min(b.low, sample)
+			b.high = max(b.high, sample)
+		}
+		b.count++
+
+		if b.count == b.samplesPerPeak {
+			b.flush()
+			if len(b.lows) >= 2*b.limit {
+				b.merge()
+			}
+		}
+	}
+}
+
+// flush ends the current peak
+func (b *Builder) flush() {
+	if b.count == 0 {
+		return
+	}
+	b.lows = append(b.lows, b.low)
+	b.highs = append(b.highs, b.high)
+	b.low, b.high, b.count = math.MaxFloat32, -math.MaxFloat32, 0
+}
+
+func (b *Builder) merge() {
+	n := len(b.lows) / 2
+	for i := range n {
+		b.lows[i] = min(b.lows[2*i], b.lows[2*i+1])
+		b.highs[i] = max(b.highs[2*i], b.highs[2*i+1])
+	}
+	b.lows, b.highs = b.lows[:n], b.highs[:n]
+	b.samplesPerPeak *= 2
+}
+
+// Waveform returns the waveform with at most resolution points, short audio may get fewer
+func (b *Builder) Waveform(resolution int) *Waveform {
+	b.flush()
+
+	perPoint := max((len(b.lows)+resolution-1)/resolution, 1)
+	length := (len(b.lows) + perPoint - 1) / perPoint
+
+	data := make([]int8, 0, 2*length)
+	for start := 0; start < len(b.lows); start += perPoint {
+		end := min(start+perPoint, len(b.lows))
+		data = append(data, quantize(minOf(b.lows[start:end])), quantize(maxOf(b.highs[start:end])))
+	}
+
+	return &Waveform{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      b.sampleRate,
+		SamplesPerPixel: perPoint * b.samplesPerPeak,
+		Bits:            8,
+		Length:          length,
+		Data:            data,
+	}
+}
+
+// quantize converts a sample to 8 bits
+func quantize(sample float32) int8 {
+	return int8(max(-128, min(math.Round(float64(sample)*128), 127)))
+}
+
+func minOf(values []float32) float32 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = min(result, v)
+	}
+	return result
+}
+
+func maxOf(values []float32) float32 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = max(result, v)
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"file-service/internal/config"
+	"file-service/internal/domain"
+	"file-service/internal/storage"
+	"file-service/internal/storage/cache"
+	"file-service/pkg/logger"
+)
+
+// MultipartSweeper periodically aborts multipart uploads that were never
+// completed and removes the tails of abandoned resumable uploads. Resumable
+// uploads may take longer than the stale age, they are kept while their
+// state exists, which expires once no chunk arrived for the upload TTL.
+type MultipartSweeper struct {
+	cfg     *config.MultipartConfig
+	storage storage.MultipartBackend
+	uploads cache.UploadStore
+	logger  *logger.Logger
+}
+
+func NewMultipartSweeper(
+	cfg *config.MultipartConfig,
+	backend storage.MultipartBackend,
+	uploads cache.UploadStore,
+	l *logger.Logger,
+) *MultipartSweeper {
+	return &MultipartSweeper{
+		cfg:     cfg,
+		storage: backend,
+		uploads: uploads,
+		logger:  l.WithComponent("multipart_sweeper"),
+	}
+}
+
+func (s *MultipartSweeper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.cfg.SweepInterval)
+	defer ticker.Stop()
+
+	s.logger.Info().
+		Dur("interval", s.cfg.SweepInterval).
+		Dur("stale_after", s.cfg.StaleAfter).
+		Msg("Multipart sweeper started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("Multipart sweeper shutting down")
+			return nil
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+func (s *MultipartSweeper) sweep(ctx context.Context) {
+	staleBefore := time.Now().Add(-s.cfg.StaleAfter)
+
+	active := func(key string) bool {
+		return s.resumable(ctx, strings.TrimPrefix(key, domain.TusTailPrefix))
+	}
+
+	aborted, err := s.storage.AbortStaleMultipartUploads(ctx, staleBefore, active)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to sweep stale multipart uploads")
+	}
+
+	if aborted > 0 {
+		s.logger.Info().Int("aborted", aborted).Msg("Stale multipart uploads aborted")
+	}
+
+	deleted, err := s.storage.DeleteStaleObjects(ctx, domain.TusTailPrefix, staleBefore, active)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to sweep stale resumable upload tails")
+	}
+
+	if deleted > 0 {
+		s.logger.Info().Int("deleted", deleted).Msg("Stale resumable upload tails deleted")
+	}
+}
+
+// resumable tells if the file has a resumable upload in progress. Uploads
+// are kept when that can't be told.
+func (s *MultipartSweeper) resumable(ctx context.Context, fileID string) bool {
+	_, found, err := s.uploads.Get(ctx, fileID)
+	if err != nil {
+		s.logger.Warn().Err(err).Str("file_id", fileID).Msg("Failed to get resumable upload")
+		return true
+	}
+	return found
+}
@@ -0,0 +1,24 @@
+package auth
+
+import "context"
+
+type claimsKey struct{}
+
+// WithClaims stores the validated token claims in the context
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the token claims of the authenticated request
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok
+}
+
+// UserIDFromContext returns the ID of the authenticated user or an empty string
+func UserIDFromContext(ctx context.Context) string {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return claims.SubjectID()
+	}
+	return ""
+}
@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformedToken      = errors.New("malformed token")
+	ErrUnsupportedAlg      = errors.New("unsupported signing algorithm")
+	ErrInvalidSignature    = errors.New("invalid token signature")
+	ErrTokenExpired        = errors.New("token expired")
+	ErrTokenNotValidYet    = errors.New("token not valid yet")
+	ErrInvalidIssuer       = errors.New("invalid token issuer")
+	ErrInvalidAudience     = errors.New("invalid token audience")
+	ErrNoVerificationKeys  = errors.New("no token verification keys configured")
+	errUnknownVerification = errors.New("no key matches the token")
+)
+
+// Claims holds the claims of the backend's access tokens
+type Claims struct {
+	UserID      string   `json:"userId"`
+	ModeratorID string   `json:"moderatorId"`
+	EntityType  string   `json:"entityType"`
+	Issuer      string   `json:"iss"`
+	Audience    audience `json:"aud"`
+	ExpiresAt   int64    `json:"exp"`
+	NotBefore   int64    `json:"nbf"`
+}
+
+// SubjectID returns the ID of the user or moderator the token was issued to
+func (c *Claims) SubjectID() string {
+	if c.UserID != "" {
+		return c.UserID
+	}
+	return c.ModeratorID
+}
+
+// audience accepts both forms of the "aud" claim: a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// VerifierOptions configures token validation. At least one key source must be set.
+type VerifierOptions struct {
+	Issuer   string // checked when not empty
+	Audience string // checked when not empty
+	// HMACSecret verifies HS256 tokens
+	HMACSecret string
+	// RSAPublicKeyFile is a PEM encoded public key verifying RS256 tokens
+	RSAPublicKeyFile string
+	// JWKSFile is a local JSON Web Key Set with RSA and symmetric keys
+	JWKSFile string
+	// Leeway tolerates clock skew when checking time based claims
+	Leeway time.Duration
+}
+
+// Verifier validates HS256 and RS256 signed JWTs
+type Verifier struct {
+	opts       VerifierOptions
+	hmacKeys   map[string][]byte
+	rsaKeys    map[string]*rsa.PublicKey
+	keyedByKid bool
+}
+
+func NewVerifier(opts VerifierOptions) (*Verifier, error) {
+	v := &Verifier{
+		opts:     opts,
+		hmacKeys: make(map[string][]byte),
+		rsaKeys:  make(map[string]*rsa.PublicKey),
+	}
+
+	// Keys without a key ID are stored under the empty kid
+	if opts.HMACSecret != "" {
+		v.hmacKeys[""] = []byte(opts.HMACSecret)
+	}
+
+	if opts.RSAPublicKeyFile != "" {
+		key, err := loadRSAPublicKey(opts.RSAPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKeys[""] = key
+	}
+
+	if opts.JWKSFile != "" {
+		if err := v.loadJWKS(opts.JWKSFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(v.hmacKeys) == 0 && len(v.rsaKeys) == 0 {
+		return nil, ErrNoVerificationKeys
+	}
+
+	return v, nil
+}
+
+// Verify checks the token signature and claims and returns the claims
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var hdr header
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	if err := v.verifySignature(hdr, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	if err := v.validateClaims(&claims); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
+
+func (v *Verifier) verifySignature(hdr header, signingInput string, signature []byte) error {
+	switch hdr.Alg {
+	case "HS256":
+		for _, key := range candidates(v.hmacKeys, hdr.Kid) {
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte(signingInput))
+			if hmac.Equal(mac.Sum(nil), signature) {
+				return nil
+			}
+		}
+	case "RS256":
+		digest := sha256.Sum256([]byte(signingInput))
+		for _, key := range candidates(v.rsaKeys, hdr.Kid) {
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return nil
+			}
+		}
+	default:
+		return ErrUnsupportedAlg
+	}
+
+	return ErrInvalidSignature
+}
+
+func (v *Verifier) validateClaims(claims *Claims) error {
+	now := time.Now()
+
+	// Access tokens must expire
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(v.opts.Leeway)) {
+		return ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0).Add(-v.opts.Leeway)) {
+		return ErrTokenNotValidYet
+	}
+	if v.opts.Issuer != "" && claims.Issuer != v.opts.Issuer {
+		return ErrInvalidIssuer
+	}
+	if v.opts.Audience != "" && !slices.Contains(claims.Audience, v.opts.Audience) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+// candidates returns the key matching the key ID, or all keys if the token has none
+func candidates[K any](keys map[string]K, kid string) []K {
+	if kid != "" {
+		if key, ok := keys[kid]; ok {
+			return []K{key}
+		}
+		return nil
+	}
+
+	all := make([]K, 0, len(keys))
+	for _, key := range keys {
+		all = append(all, key)
+	}
+	return all
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+func (v *Verifier) loadJWKS(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to parse JWKS file: %w", err)
+	}
+
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		switch key.Kty {
+		case "RSA":
+			publicKey, err := parseRSAJWK(key)
+			if err != nil {
+				return fmt.Errorf("invalid RSA key %q in JWKS: %w", key.Kid, err)
+			}
+			v.rsaKeys[key.Kid] = publicKey
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil {
+				return fmt.Errorf("invalid symmetric key %q in JWKS: %w", key.Kid, err)
+			}
+			v.hmacKeys[key.Kid] = secret
+		}
+	}
+
+	return nil
+}
+
+func parseRSAJWK(key jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 {
+		return nil, errors.New("invalid exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
+
+func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RSA public key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode RSA public key PEM")
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+	}
+
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+	return key, nil
+}
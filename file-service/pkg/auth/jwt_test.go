@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHMACSecret = "test-hmac-secret"
+
+// sign returns a token of the claims signed with the key, an RSA private key
+// for RS256 and a secret for HS256. Other algorithms get no signature.
+func sign(t *testing.T, alg string, kid string, key any, claims map[string]any) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to encode token segment: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	hdr := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		hdr["kid"] = kid
+	}
+	signingInput := encode(hdr) + "." + encode(claims)
+
+	var signature []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	case "RS256":
+		digest := sha256.Sum256([]byte(signingInput))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// claimsWith returns valid claims for the test verifiers with the overrides applied
+func claimsWith(overrides map[string]any) map[string]any {
+	claims := map[string]any{
+		"userId": "user-1",
+		"iss":    "backend",
+		"aud":    "file-service",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PUBLIC KEY",
+		Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey),
+	})
+
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA",
+			"kid": "rsa-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{
+			"kty": "oct",
+			"kid": "hmac-1",
+			"k":   base64.RawURLEncoding.EncodeToString([]byte(testHMACSecret)),
+		},
+	}})
+	if err != nil {
+		t.Fatalf("failed to encode JWKS: %v", err)
+	}
+
+	jwksVerifier, err := NewVerifier(VerifierOptions{
+		Issuer:   "backend",
+		Audience: "file-service",
+		JWKSFile: writeFile(t, "jwks.json", jwks),
+		Leeway:   time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	// Verifies RS256 only, its public key is no HS256 secret
+	rsaVerifier, err := NewVerifier(VerifierOptions{
+		RSAPublicKeyFile: writeFile(t, "public.pem", publicKeyPEM),
+	})
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+
+	secret := []byte(testHMACSecret)
+	valid := sign(t, "RS256", "rsa-1", rsaKey, claimsWith(nil))
+	otherClaims := sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"userId": "user-2"}))
+	segments := strings.Split(valid, ".")
+	tampered := segments[0] + "." + strings.Split(otherClaims, ".")[1] + "." + segments[2]
+
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		token    string
+		wantErr  error
+	}{
+		{"RS256", jwksVerifier, valid, nil},
+		{"HS256", jwksVerifier, sign(t, "HS256", "hmac-1", secret, claimsWith(nil)), nil},
+		{"RS256 without kid", rsaVerifier, sign(t, "RS256", "", rsaKey, claimsWith(nil)), nil},
+		{"audience array", jwksVerifier, sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"aud": []string{"webapi", "file-service"}})), nil},
+		{"alg none", jwksVerifier, sign(t, "none", "", nil, claimsWith(nil)), ErrUnsupportedAlg},
+		{"unsupported alg", jwksVerifier, sign(t, "ES256", "rsa-1", nil, claimsWith(nil)), ErrUnsupportedAlg},
+		{"HS256 signed with the RSA public key", rsaVerifier, sign(t, "HS256", "", publicKeyPEM, claimsWith(nil)), ErrInvalidSignature},
+		{"HS256 with the RSA kid", jwksVerifier, sign(t, "HS256", "rsa-1", secret, claimsWith(nil)), ErrInvalidSignature},
+		{"unknown kid", jwksVerifier, sign(t, "RS256", "rsa-2", rsaKey, claimsWith(nil)), ErrInvalidSignature},
+		{"tampered claims", jwksVerifier, tampered, ErrInvalidSignature},
+		{"malformed", jwksVerifier, "not.a-token", ErrMalformedToken},
+		{"expired", jwksVerifier, sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"exp": time.Now().Add(-2 * time.Minute).Unix()})), ErrTokenExpired},
+		{"expired within leeway", jwksVerifier, sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"exp": time.Now().Add(-30 * time.Second).Unix()})), nil},
+		{"without expiry", jwksVerifier, sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"exp": nil})), ErrTokenExpired},
+		{"not valid yet", jwksVerifier, sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"nbf": time.Now().Add(2 * time.Minute).Unix()})), ErrTokenNotValidYet},
+		{"not before within leeway", jwksVerifier, sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"nbf": time.Now().Add(30 * time.Second).Unix()})), nil},
+		{"other issuer", jwksVerifier, sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"iss": "other"})), ErrInvalidIssuer},
+		{"other audience", jwksVerifier, sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"aud": "webapi"})), ErrInvalidAudience},
+		{"audience array without the service", jwksVerifier, sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"aud": []string{"webapi"}})), ErrInvalidAudience},
+		{"without audience", jwksVerifier, sign(t, "RS256", "rsa-1", rsaKey, claimsWith(map[string]any{"aud": nil})), ErrInvalidAudience},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.verifier.Verify(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims.SubjectID() != "user-1" {
+				t.Errorf("Verify() subject = %q, want %q", claims.SubjectID(), "user-1")
+			}
+		})
+	}
+}
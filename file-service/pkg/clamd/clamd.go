@@ -0,0 +1,183 @@
+// Package clamd implements a client of the ClamAV daemon. Content is scanned
+// with the INSTREAM command, so clamd doesn't need access to the files.
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of the chunks content is streamed to clamd in
+const chunkSize = 64 << 10
+
+// ErrSizeLimitExceeded is returned if the content exceeds clamd's StreamMaxLength
+var ErrSizeLimitExceeded = errors.New("clamd stream size limit exceeded")
+
+// Result is the verdict of a scan
+type Result struct {
+	Infected  bool
+	Signature string // name of the detected malware
+}
+
+// Client connects to clamd for every command
+type Client struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// New returns a client of the clamd listening at the address, which is either
+// "unix:///path/to/clamd.sock", "tcp://host:port" or just "host:port". The
+// timeout limits each command.
+func New(address string, timeout time.Duration) (*Client, error) {
+	c := &Client{network: "tcp", address: address, timeout: timeout}
+
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		c.network, c.address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		c.address = strings.TrimPrefix(address, "tcp://")
+	case strings.Contains(address, "://"):
+		return nil, fmt.Errorf("unsupported clamd address %q", address)
+	}
+	if c.address == "" {
+		return nil, errors.New("clamd address is empty")
+	}
+
+	return c, nil
+}
+
+// Ping checks that clamd is responding
+func (c *Client) Ping(ctx context.Context) error {
+	reply, err := c.command(ctx, "PING", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+	return nil
+}
+
+// Scan streams the content to clamd and returns its verdict
+func (c *Client) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	reply, err := c.command(ctx, "INSTREAM", r)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Replies are "stream: OK", "stream: <signature> FOUND" or "<message> ERROR"
+	switch {
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return Result{Infected: true, Signature: signature}, nil
+	case strings.HasSuffix(reply, " OK"):
+		return Result{}, nil
+	case strings.HasPrefix(reply, "INSTREAM size limit exceeded"):
+		return Result{}, ErrSizeLimitExceeded
+	default:
+		return Result{}, fmt.Errorf("clamd scan failed: %s", reply)
+	}
+}
+
+// command sends the command, followed by the content as chunks if there is
+// any, and reads the reply. Commands are prefixed with "z" to delimit them
+// and their replies with a null character.
+func (c *Client) command(ctx context.Context, name string, content io.Reader) (string, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	// Closing the connection unblocks reads and writes once the context ends
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := io.WriteString(conn, "z"+name+"\x00"); err != nil {
+		return "", c.failed(ctx, err)
+	}
+	if content != nil {
+		if err := writeChunks(conn, content); err != nil {
+			var readErr *contentError
+			if errors.As(err, &readErr) {
+				return "", readErr.err
+			}
+			// clamd replies and closes the connection when a stream gets too large
+			if reply, replyErr := readReply(conn); replyErr == nil {
+				return reply, nil
+			}
+			return "", c.failed(ctx, err)
+		}
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return "", c.failed(ctx, err)
+	}
+	return reply, nil
+}
+
+// failed prefers the context error over the error of the closed connection
+func (c *Client) failed(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	}
+	return fmt.Errorf("failed to talk to clamd: %w", err)
+}
+
+// writeChunks writes the content as chunks prefixed by their length as 32-bit
+// big endian integer, a chunk of length zero terminates the stream
+func writeChunks(w io.Writer, content io.Reader) error {
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(content, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := w.Write(buf[:4+n]); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return &contentError{fmt.Errorf("failed to read content: %w", err)}
+		}
+	}
+
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// contentError tells errors reading the content from errors writing to clamd
+type contentError struct {
+	err error
+}
+
+func (e *contentError) Error() string {
+	return e.err.Error()
+}
+
+func readReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadBytes(0)
+	if err != nil && !(errors.Is(err, io.EOF) && len(reply) > 0) {
+		return "", err
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
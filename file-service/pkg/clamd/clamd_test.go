@@ -0,0 +1,216 @@
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// maxStreamLength is the StreamMaxLength of the fake clamd
+const maxStreamLength = 4 * chunkSize
+
+// fakeClamd accepts connections like clamd and answers every command with
+// what reply returns for it and the content streamed with it. An empty reply
+// leaves the connection open without answering. Like clamd it replies before
+// the end of streams exceeding maxStreamLength.
+func fakeClamd(t *testing.T, reply func(command string, content []byte) string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFake(conn.(*net.TCPConn), reply)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveFake(conn *net.TCPConn, reply func(command string, content []byte) string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	command = command[1 : len(command)-1]
+
+	var content []byte
+	for command == "INSTREAM" {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if len(content)+int(size) > maxStreamLength {
+			_, _ = io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+			// Drain the rest, closing with unread input would reset the connection
+			_ = conn.CloseWrite()
+			_, _ = io.Copy(io.Discard, r)
+			return
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return
+		}
+		content = append(content, chunk...)
+	}
+
+	answer := reply(command, content)
+	if answer == "" {
+		// Hang until the client gives up
+		_, _ = io.Copy(io.Discard, r)
+		return
+	}
+	_, _ = io.WriteString(conn, answer+"\x00")
+}
+
+func TestScan(t *testing.T) {
+	// Larger than a chunk, so it is streamed in several
+	content := bytes.Repeat([]byte("audio"), chunkSize/2)
+
+	tests := []struct {
+		name    string
+		content []byte
+		reply   string
+		want    Result
+		wantErr error
+	}{
+		{"clean", content, "stream: OK", Result{}, nil},
+		{"infected", content, "stream: Eicar-Test-Signature FOUND", Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil},
+		{"size limit exceeded", make([]byte, 4*maxStreamLength), "stream: OK", Result{}, ErrSizeLimitExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan []byte, 1)
+			address := fakeClamd(t, func(command string, streamed []byte) string {
+				if command != "INSTREAM" {
+					return "UNKNOWN COMMAND"
+				}
+				received <- streamed
+				return tt.reply
+			})
+
+			client, err := New("tcp://"+address, time.Second)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			result, err := client.Scan(context.Background(), bytes.NewReader(tt.content))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Scan() error = %v, want %v", err, tt.wantErr)
+			}
+			if result != tt.want {
+				t.Errorf("Scan() = %+v, want %+v", result, tt.want)
+			}
+			if tt.wantErr == nil && !bytes.Equal(<-received, tt.content) {
+				t.Errorf("clamd didn't receive the content")
+			}
+		})
+	}
+}
+
+func TestScanFailure(t *testing.T) {
+	address := fakeClamd(t, func(string, []byte) string {
+		return "Can't allocate memory ERROR"
+	})
+
+	client, err := New(address, time.Second)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := client.Scan(context.Background(), bytes.NewReader([]byte("audio"))); err == nil {
+		t.Fatal("Scan() error = nil, want the clamd error")
+	}
+}
+
+func TestScanTimeout(t *testing.T) {
+	address := fakeClamd(t, func(string, []byte) string {
+		return ""
+	})
+
+	client, err := New(address, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	start := time.Now()
+	_, err = client.Scan(context.Background(), bytes.NewReader([]byte("audio")))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Scan() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Scan() took %v, want it to give up after the timeout", elapsed)
+	}
+}
+
+func TestPing(t *testing.T) {
+	address := fakeClamd(t, func(command string, _ []byte) string {
+		if command != "PING" {
+			return "UNKNOWN COMMAND"
+		}
+		return "PONG"
+	})
+
+	client, err := New(address, time.Second)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		address     string
+		wantNetwork string
+		wantAddress string
+		wantErr     bool
+	}{
+		{"unix:///run/clamav/clamd.sock", "unix", "/run/clamav/clamd.sock", false},
+		{"tcp://clamav:3310", "tcp", "clamav:3310", false},
+		{"clamav:3310", "tcp", "clamav:3310", false},
+		{"http://clamav:3310", "", "", true},
+		{"tcp://", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			client, err := New(tt.address, time.Second)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("New() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			if client.network != tt.wantNetwork || client.address != tt.wantAddress {
+				t.Errorf("New() = %s %s, want %s %s", client.network, client.address, tt.wantNetwork, tt.wantAddress)
+			}
+		})
+	}
+}
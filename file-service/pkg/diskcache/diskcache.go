@@ -0,0 +1,209 @@
+// Package diskcache implements a size-capped least recently used cache of
+// files in a local directory. Entries found in the directory on startup are
+// adopted, ordered by modification time.
+package diskcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	name string
+	size int64
+}
+
+// Cache stores values under hashed keys as files in its directory
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get returns the cached value of the key
+func (c *Cache) Get(key string) ([]byte, bool) {
+	name := fileName(key)
+
+	c.mu.Lock()
+	element, exists := c.entries[name]
+	if exists {
+		c.order.MoveToFront(element)
+	}
+	c.mu.Unlock()
+
+	if !exists {
+		return nil, false
+	}
+
+	path := filepath.Join(c.dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// The file was evicted or removed behind our back
+		c.remove(name)
+		return nil, false
+	}
+
+	// Keep the modification time as the access time, so the order survives restarts
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return data, true
+}
+
+// Put stores the value and evicts least recently used entries above the size cap
+func (c *Cache) Put(key string, data []byte) error {
+	name := fileName(key)
+	size := int64(len(data))
+	if size > c.maxSize {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.dir, name)); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, exists := c.entries[name]; exists {
+		c.size -= element.Value.(*entry).size
+		c.order.Remove(element)
+	}
+	c.entries[name] = c.order.PushFront(&entry{name: name, size: size})
+	c.size += size
+
+	c.evict()
+	return nil
+}
+
+// evict removes entries from the back until the cache fits, c.mu must be held
+func (c *Cache) evict() {
+	for c.size > c.maxSize {
+		element := c.order.Back()
+		if element == nil {
+			return
+		}
+
+		evicted := element.Value.(*entry)
+		c.order.Remove(element)
+		delete(c.entries, evicted.name)
+		c.size -= evicted.size
+
+		_ = os.Remove(filepath.Join(c.dir, evicted.name))
+	}
+}
+
+func (c *Cache) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, exists := c.entries[name]; exists {
+		c.size -= element.Value.(*entry).size
+		c.order.Remove(element)
+		delete(c.entries, name)
+	}
+}
+
+func (c *Cache) load() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	type existing struct {
+		entry
+		modTime time.Time
+	}
+
+	var files []existing
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() {
+			continue
+		}
+
+		// Leftovers of interrupted writes
+		if strings.HasPrefix(dirEntry.Name(), ".tmp-") {
+			_ = os.Remove(filepath.Join(c.dir, dirEntry.Name()))
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		files = append(files, existing{
+			entry:   entry{name: dirEntry.Name(), size: info.Size()},
+			modTime: info.ModTime(),
+		})
+	}
+
+	// Oldest first, so the newest files end up at the front
+	slices.SortFunc(files, func(a, b existing) int {
+		return a.modTime.Compare(b.modTime)
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, file := range files {
+		stored := file.entry
+		c.entries[stored.name] = c.order.PushFront(&stored)
+		c.size += stored.size
+	}
+	c.evict()
+
+	return nil
+}
+
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
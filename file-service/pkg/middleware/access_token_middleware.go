@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"file-service/internal/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessTokenHeader carries the shared token of service-to-service requests
+const AccessTokenHeader = "X-Access-Token"
+
+// AccessTokenMiddleware rejects requests that don't present the shared access
+// token either in the X-Access-Token header or as a bearer token
+func AccessTokenMiddleware(token string) gin.HandlerFunc {
+	expected := []byte(token)
+
+	return func(c *gin.Context) {
+		presented := c.GetHeader(AccessTokenHeader)
+		if presented == "" {
+			presented = bearerToken(c.GetHeader("Authorization"))
+		}
+
+		if subtle.ConstantTimeCompare([]byte(presented), expected) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, domain.ErrorResponse{
+				Error: "Invalid access token",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
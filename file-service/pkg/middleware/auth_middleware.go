@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"file-service/internal/domain"
+	"file-service/pkg/auth"
+	"file-service/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accessTokenCookie is the cookie the backend stores the access token in
+const accessTokenCookie = "access_token"
+
+// AuthMiddleware rejects requests without a valid access token and stores
+// the token claims in the request context. The token is taken from the
+// Authorization header or from the backend's access token cookie.
+func AuthMiddleware(verifier *auth.Verifier, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c.GetHeader("Authorization"))
+		if token == "" {
+			token, _ = c.Cookie(accessTokenCookie)
+		}
+
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, domain.ErrorResponse{
+				Error: "Missing access token",
+			})
+			return
+		}
+
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			log.Debug().Err(err).Str("path", c.Request.URL.Path).Msg("Rejected access token")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, domain.ErrorResponse{
+				Error:   "Invalid access token",
+				Details: err.Error(),
+			})
+			return
+		}
+
+		c.Request = c.Request.WithContext(auth.WithClaims(c.Request.Context(), claims))
+		c.Next()
+	}
+}
+
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -14,11 +14,16 @@ func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
 			}
 		}
 
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, HEAD, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers",
+			"Content-Type, Authorization, X-API-Key, Tus-Resumable, Upload-Length, Upload-Offset, Upload-Metadata")
+		c.Writer.Header().Set("Access-Control-Expose-Headers",
+			"Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Expires, "+
+				"RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 
-		if c.Request.Method == "OPTIONS" {
+		// Only short-circuit CORS preflights, plain OPTIONS requests are used by tus discovery
+		if c.Request.Method == "OPTIONS" && c.GetHeader("Access-Control-Request-Method") != "" {
 			c.AbortWithStatus(204)
 			return
 		}
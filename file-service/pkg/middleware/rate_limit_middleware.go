@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"file-service/internal/domain"
+	"file-service/pkg/auth"
+	"file-service/pkg/logger"
+	"file-service/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware limits requests to a route per user, or per client IP
+// for anonymous requests. It must run after AuthMiddleware to key by user.
+// Requests are let through when the limiter fails, so a Redis outage doesn't
+// take the API down with it.
+func RateLimitMiddleware(limiter ratelimit.Limiter, route string, rule ratelimit.Rule, log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := route + ":ip:" + c.ClientIP()
+		if userID := auth.UserIDFromContext(c.Request.Context()); userID != "" {
+			key = route + ":user:" + userID
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), key, rule)
+		if err != nil {
+			log.Error().Err(err).Str("route", route).Msg("Rate limiter failed, allowing request")
+			c.Next()
+			return
+		}
+
+		reset := strconv.Itoa(int(math.Ceil(result.Reset.Seconds())))
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", reset)
+
+		if !result.Allowed {
+			c.Header("Retry-After", reset)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, domain.ErrorResponse{
+				Error: "Rate limit exceeded",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
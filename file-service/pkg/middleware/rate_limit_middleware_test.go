@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"file-service/pkg/logger"
+	"file-service/pkg/ratelimit"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRateLimitedRouter(limiter ratelimit.Limiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	rule := ratelimit.Rule{Requests: 2, Window: time.Minute}
+	router.GET("/", RateLimitMiddleware(limiter, "test", rule, logger.New("file-service-test")), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	router := newRateLimitedRouter(ratelimit.NewMemoryLimiter())
+
+	tests := []struct {
+		name          string
+		want          int
+		wantRemaining string
+	}{
+		{"first", http.StatusOK, "1"},
+		{"last allowed", http.StatusOK, "0"},
+		{"limited", http.StatusTooManyRequests, "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+				t.Errorf("RateLimit-Limit = %q, want %q", got, "2")
+			}
+			if got := rec.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("RateLimit-Remaining = %q, want %q", got, tt.wantRemaining)
+			}
+			if rec.Header().Get("RateLimit-Reset") == "" {
+				t.Error("RateLimit-Reset is missing")
+			}
+
+			retryAfter := rec.Header().Get("Retry-After")
+			if tt.want == http.StatusTooManyRequests && retryAfter != rec.Header().Get("RateLimit-Reset") {
+				t.Errorf("Retry-After = %q, want the reset %q", retryAfter, rec.Header().Get("RateLimit-Reset"))
+			}
+			if tt.want == http.StatusOK && retryAfter != "" {
+				t.Errorf("Retry-After = %q on an allowed request", retryAfter)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddlewareFailsOpen(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+
+	router := newRateLimitedRouter(ratelimit.NewRedisLimiter(client, "rate-limit:"))
+	server.Close()
+
+	// Beyond the limit, every request is let through while Redis is down
+	for range 3 {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		if got := rec.Header().Get("RateLimit-Limit"); got != "" {
+			t.Errorf("RateLimit-Limit = %q, want no headers without a count", got)
+		}
+	}
+}
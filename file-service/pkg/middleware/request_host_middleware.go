@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"file-service/pkg/urlrewrite"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestHostMiddleware records the host the client addressed in the request
+// context, so generated URLs can point at the matching public hostname
+func RequestHostMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(urlrewrite.WithHost(c.Request.Context(), c.Request.Host))
+		c.Next()
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TransferDeadlineMiddleware extends the read and write deadlines of the
+// connection for routes streaming request or response bodies, which can't
+// finish within the server-wide timeouts
+func TransferDeadlineMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deadline := time.Now().Add(timeout)
+		rc := http.NewResponseController(c.Writer)
+
+		if err := rc.SetReadDeadline(deadline); err != nil {
+			_ = c.Error(err)
+		}
+		if err := rc.SetWriteDeadline(deadline); err != nil {
+			_ = c.Error(err)
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+// Package ratelimit implements sliding window rate limiting. The window is
+// approximated by weighting the previous fixed window's count by its overlap
+// with the sliding window, which needs only two counters per key.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Rule allows Requests per Window
+type Rule struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Result describes the state of the limit after a request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the current window ends
+	Reset time.Duration
+}
+
+// Limiter counts requests per key
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// window splits now into the index of the current fixed window and the time elapsed in it
+func window(now time.Time, rule Rule) (int64, time.Duration) {
+	size := rule.Window.Nanoseconds()
+	index := now.UnixNano() / size
+	elapsed := time.Duration(now.UnixNano() - index*size)
+	return index, elapsed
+}
+
+// weightedCount estimates the requests in the sliding window ending now
+func weightedCount(previous, current int64, elapsed time.Duration, rule Rule) int64 {
+	overlap := float64(rule.Window-elapsed) / float64(rule.Window)
+	return int64(float64(previous)*overlap) + current
+}
+
+func result(allowed bool, count int64, elapsed time.Duration, rule Rule) Result {
+	return Result{
+		Allowed:   allowed,
+		Limit:     rule.Requests,
+		Remaining: max(rule.Requests-int(count), 0),
+		Reset:     rule.Window - elapsed,
+	}
+}
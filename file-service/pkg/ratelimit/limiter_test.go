@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestLimiters runs every limiter through the same sliding window behaviour
+func TestLimiters(t *testing.T) {
+	limiters := map[string]func(t *testing.T, now func() time.Time) Limiter{
+		"memory": func(_ *testing.T, now func() time.Time) Limiter {
+			limiter := NewMemoryLimiter()
+			limiter.now = now
+			return limiter
+		},
+		"redis": func(t *testing.T, now func() time.Time) Limiter {
+			server := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+			t.Cleanup(func() { client.Close() })
+
+			limiter := NewRedisLimiter(client, "rate-limit:")
+			limiter.now = now
+			return limiter
+		},
+	}
+
+	for name, newLimiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			// Starts at the beginning of a window
+			clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			testLimiter(t, newLimiter(t, func() time.Time { return clock }), func(d time.Duration) {
+				clock = clock.Add(d)
+			})
+		})
+	}
+}
+
+func testLimiter(t *testing.T, limiter Limiter, advance func(time.Duration)) {
+	ctx := context.Background()
+	rule := Rule{Requests: 3, Window: time.Minute}
+
+	// allow makes the requests and returns the remaining counts, -1 for rejections
+	allow := func(t *testing.T, key string, requests int) []int {
+		t.Helper()
+		var remaining []int
+		for range requests {
+			result, err := limiter.Allow(ctx, key, rule)
+			if err != nil {
+				t.Fatalf("Allow() error = %v", err)
+			}
+			if result.Limit != rule.Requests {
+				t.Errorf("Limit = %d, want %d", result.Limit, rule.Requests)
+			}
+			if !result.Allowed {
+				remaining = append(remaining, -1)
+				continue
+			}
+			remaining = append(remaining, result.Remaining)
+		}
+		return remaining
+	}
+
+	want := func(t *testing.T, got []int, want ...int) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("remaining = %v, want %v", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("remaining = %v, want %v", got, want)
+			}
+		}
+	}
+
+	want(t, allow(t, "user-1", 4), 2, 1, 0, -1)
+
+	result, err := limiter.Allow(ctx, "user-1", rule)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	if result.Allowed || result.Remaining != 0 || result.Reset != time.Minute {
+		t.Errorf("Allow() = %+v, want a rejection resetting in a minute", result)
+	}
+
+	// Keys are counted apart
+	want(t, allow(t, "user-2", 1), 2)
+
+	// Halfway into the next window half of the previous one still counts
+	advance(90 * time.Second)
+	want(t, allow(t, "user-1", 3), 1, 0, -1)
+
+	// Once the window slid past both, the count starts over
+	advance(2 * time.Minute)
+	want(t, allow(t, "user-1", 4), 2, 1, 0, -1)
+}
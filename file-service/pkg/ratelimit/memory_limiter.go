@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneEvery is the number of requests between removals of idle keys
+const pruneEvery = 1024
+
+type memoryCounter struct {
+	index    int64
+	previous int64
+	current  int64
+	window   time.Duration
+}
+
+// MemoryLimiter keeps counters in process, limits apply per replica
+type MemoryLimiter struct {
+	mu       sync.Mutex
+	counters map[string]*memoryCounter
+	requests int
+	now      func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		counters: make(map[string]*memoryCounter),
+		now:      time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(_ context.Context, key string, rule Rule) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	index, elapsed := window(now, rule)
+
+	l.requests++
+	if l.requests%pruneEvery == 0 {
+		l.prune(now)
+	}
+
+	counter, exists := l.counters[key]
+	if !exists {
+		counter = &memoryCounter{index: index, window: rule.Window}
+		l.counters[key] = counter
+	}
+
+	// Roll the windows forward, anything older than the previous window is dropped
+	switch index - counter.index {
+	case 0:
+	case 1:
+		counter.previous, counter.current = counter.current, 0
+	default:
+		counter.previous, counter.current = 0, 0
+	}
+	counter.index = index
+
+	count := weightedCount(counter.previous, counter.current, elapsed, rule)
+	if count >= int64(rule.Requests) {
+		return result(false, count, elapsed, rule), nil
+	}
+
+	counter.current++
+	return result(true, count+1, elapsed, rule), nil
+}
+
+// prune removes counters that no longer affect any sliding window
+func (l *MemoryLimiter) prune(now time.Time) {
+	for key, counter := range l.counters {
+		index, _ := window(now, Rule{Window: counter.window})
+		if index-counter.index > 1 {
+			delete(l.counters, key)
+		}
+	}
+}
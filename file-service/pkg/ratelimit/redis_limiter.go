@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// allowScript counts the request in the current window unless the weighted
+// count of both windows already reached the limit. It returns whether the
+// request was allowed and the count including it.
+var allowScript = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local previous = tonumber(redis.call("GET", KEYS[2]) or "0")
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+
+local count = math.floor(previous * (window - elapsed) / window) + current
+if count >= limit then
+	return {0, count}
+end
+
+if redis.call("INCR", KEYS[1]) == 1 then
+	redis.call("PEXPIRE", KEYS[1], window * 2)
+end
+return {1, count + 1}
+`)
+
+// RedisLimiter shares counters between replicas
+type RedisLimiter struct {
+	client    *redis.Client
+	keyPrefix string
+	now       func() time.Time
+}
+
+func NewRedisLimiter(client *redis.Client, keyPrefix string) *RedisLimiter {
+	return &RedisLimiter{
+		client:    client,
+		keyPrefix: keyPrefix,
+		now:       time.Now,
+	}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	index, elapsed := window(l.now(), rule)
+
+	keys := []string{
+		l.keyPrefix + key + ":" + strconv.FormatInt(index, 10),
+		l.keyPrefix + key + ":" + strconv.FormatInt(index-1, 10),
+	}
+
+	values, err := allowScript.Run(ctx, l.client, keys,
+		rule.Requests, rule.Window.Milliseconds(), elapsed.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result(values[0] == 1, values[1], elapsed, rule), nil
+}
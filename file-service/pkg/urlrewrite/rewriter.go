@@ -0,0 +1,177 @@
+package urlrewrite
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+type hostKey struct{}
+
+// WithHost stores the host the client addressed the request to, used to pick a public base URL
+func WithHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, hostKey{}, host)
+}
+
+func hostFromContext(ctx context.Context) string {
+	host, _ := ctx.Value(hostKey{}).(string)
+	return host
+}
+
+// Options configures a Rewriter. Base URLs consist of scheme, host and an
+// optional path prefix, e.g. "https://example.com/s3".
+type Options struct {
+	// Origin of the URLs being rewritten, e.g. "http://minio:9000"
+	Source string
+	// Base URLs replacing the source origin, the bucket path is kept
+	PublicBaseURL   string
+	InternalBaseURL string
+	// Base URLs replacing the source origin and the bucket path segment,
+	// for buckets served from their own location
+	BucketPublicBaseURLs   map[string]string
+	BucketInternalBaseURLs map[string]string
+	// Public base URLs chosen by the host the client addressed, replacing
+	// the source origin like PublicBaseURL
+	HostPublicBaseURLs map[string]string
+}
+
+// Rewriter maps URLs signed against the storage endpoint onto the addresses
+// clients reach it through. The proxy behind a base URL must strip the path
+// prefix (and restore the bucket segment for bucket base URLs) and forward
+// the original endpoint host, otherwise signatures won't match.
+type Rewriter struct {
+	source         *url.URL
+	publicBase     *url.URL
+	internalBase   *url.URL
+	bucketPublic   map[string]*url.URL
+	bucketInternal map[string]*url.URL
+	hostPublic     map[string]*url.URL
+}
+
+func New(opts Options) (*Rewriter, error) {
+	source, err := parseBaseURL(opts.Source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source URL: %w", err)
+	}
+
+	r := &Rewriter{source: source}
+
+	if r.publicBase, err = parseBaseURL(opts.PublicBaseURL); err != nil {
+		return nil, fmt.Errorf("invalid public base URL: %w", err)
+	}
+	if r.internalBase, err = parseBaseURL(opts.InternalBaseURL); err != nil {
+		return nil, fmt.Errorf("invalid internal base URL: %w", err)
+	}
+	if r.bucketPublic, err = parseBaseURLs(opts.BucketPublicBaseURLs); err != nil {
+		return nil, fmt.Errorf("invalid bucket public base URL: %w", err)
+	}
+	if r.bucketInternal, err = parseBaseURLs(opts.BucketInternalBaseURLs); err != nil {
+		return nil, fmt.Errorf("invalid bucket internal base URL: %w", err)
+	}
+
+	// Hosts are case-insensitive
+	hostURLs := make(map[string]string, len(opts.HostPublicBaseURLs))
+	for host, rawURL := range opts.HostPublicBaseURLs {
+		hostURLs[strings.ToLower(host)] = rawURL
+	}
+	if r.hostPublic, err = parseBaseURLs(hostURLs); err != nil {
+		return nil, fmt.Errorf("invalid host public base URL: %w", err)
+	}
+
+	return r, nil
+}
+
+// Rewrite replaces the source origin of rawURL with the base URL configured
+// for the bucket and audience. Public URLs first match the request host from
+// the context, then the bucket, then fall back to the default public base URL.
+func (r *Rewriter) Rewrite(ctx context.Context, rawURL string, bucket string, internal bool) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse URL: %w", err)
+	}
+
+	if u.Scheme != r.source.Scheme || u.Host != r.source.Host {
+		return rawURL, nil
+	}
+
+	base, replacesBucket := r.resolve(ctx, bucket, internal)
+	if base == nil {
+		return rawURL, nil
+	}
+
+	path := u.Path
+	if replacesBucket {
+		path = strings.TrimPrefix(path, "/"+bucket)
+	}
+
+	rewritten := *u
+	rewritten.Scheme = base.Scheme
+	rewritten.Host = base.Host
+	rewritten.Path = base.Path + path
+	rewritten.RawPath = ""
+
+	return rewritten.String(), nil
+}
+
+// resolve returns the base URL to use and whether it replaces the bucket path segment
+func (r *Rewriter) resolve(ctx context.Context, bucket string, internal bool) (*url.URL, bool) {
+	if internal {
+		if base, ok := r.bucketInternal[bucket]; ok {
+			return base, true
+		}
+		return r.internalBase, false
+	}
+
+	if host := hostFromContext(ctx); host != "" {
+		if base, ok := r.hostPublic[strings.ToLower(stripPort(host))]; ok {
+			return base, false
+		}
+	}
+	if base, ok := r.bucketPublic[bucket]; ok {
+		return base, true
+	}
+	return r.publicBase, false
+}
+
+func parseBaseURLs(rawURLs map[string]string) (map[string]*url.URL, error) {
+	urls := make(map[string]*url.URL, len(rawURLs))
+	for key, rawURL := range rawURLs {
+		u, err := parseBaseURL(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		urls[key] = u
+	}
+	return urls, nil
+}
+
+// parseBaseURL parses an absolute URL without query, returning nil for an empty string
+func parseBaseURL(rawURL string) (*url.URL, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%q must be an absolute URL", rawURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("%q must not have a query or fragment", rawURL)
+	}
+
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u, nil
+}
+
+func stripPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
@@ -3,8 +3,10 @@ import { CLIENT_FILES_URL } from "@/shared/config/api";
 
 export type FileUploadType = "image" | "audio";
 
+// S3 POST policy upload: the form fields must precede the file
 type PresignedUrlResponse = {
   url: string;
+  form_data: Record<string, string>;
   file_id: string;
 };
 
@@ -112,19 +114,46 @@ export async function getPresignedUrls(): Promise<
 }
 
 export async function uploadFileToS3(
-  url: string,
+  presigned: PresignedUrlResponse,
   file: File,
-  contentType: FileUploadType
+  type: FileUploadType
 ): Promise<boolean> {
   try {
-    const response = await fetch(url, {
-      method: "PUT",
-      headers: { "content-type": contentType },
-      body: file,
+    const body = new FormData();
+    for (const [key, value] of Object.entries(presigned.form_data)) {
+      body.append(key, value);
+    }
+    // The policy requires a content type of the file type, browsers leave
+    // it empty for extensions they don't know. S3 reads it from the field,
+    // the filesystem storage from the file part.
+    const contentType =
+      presigned.form_data["Content-Type"] ??
+      (file.type.startsWith(`${type}/`) ? file.type : `${type}/octet-stream`);
+    if (!("Content-Type" in presigned.form_data)) {
+      body.append("Content-Type", contentType);
+    }
+    body.append("file", new File([file], file.name, { type: contentType }));
+
+    const response = await fetch(presigned.url, {
+      method: "POST",
+      body,
     });
-    return response.ok;
+    if (!response.ok) {
+      return false;
+    }
+
+    // The file is only downloadable once processing accepted it
+    const completeResponse = await fetch(
+      `${CLIENT_FILES_URL}/files/${presigned.file_id}/complete`,
+      {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ file_type: type }),
+      }
+    );
+    return completeResponse.ok;
   } catch (error) {
     console.error("Error uploading file to S3:", error);
     return false;
   }
-}
\ No newline at end of file
+}
@@ -31,7 +31,7 @@ export function MediaItem ({
           fill
           src={
             imagePath
-              ? `${CLIENT_FILES_URL}/download-url?type=image&file_id=${imagePath}`
+              ? `${CLIENT_FILES_URL}/download-url?type=image&size=64&file_id=${imagePath}`
               : "/images/playlist.webp"
           }
           alt={title}
@@ -130,7 +130,7 @@ const ChangeUserInfoForm = ({
           }
 
           const imageUploadSuccess = await uploadFileToS3(
-            presignedUrlImage.url,
+            presignedUrlImage,
             formData.file,
             "image"
           );
@@ -98,8 +98,8 @@ const SongUploadModal = () => {
       const [presignedUrlImage, presignedUrlAudio] = urls;
 
       const [imageUploadSuccess, audioUploadSuccess] = await Promise.all([
-        uploadFileToS3(presignedUrlImage.url, formData.imageFile, "image"),
-        uploadFileToS3(presignedUrlAudio.url, formData.audioFile, "audio"),
+        uploadFileToS3(presignedUrlImage, formData.imageFile, "image"),
+        uploadFileToS3(presignedUrlAudio, formData.audioFile, "audio"),
       ]);
 
       if (!imageUploadSuccess || !audioUploadSuccess) {
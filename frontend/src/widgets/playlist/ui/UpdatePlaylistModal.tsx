@@ -118,7 +118,7 @@ const UpdatePlaylistModal = () => {
         }
 
         const imageUploadSuccess = await uploadFileToS3(
-          presignedUrlImage.url,
+          presignedUrlImage,
           formData.imageFile,
           "image"
         );